@@ -0,0 +1,363 @@
+package add
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/azuredevops"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/bitbucket"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/gitea"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/github"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/gitlab"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/splitter"
+)
+
+// Supported comment backends
+const (
+	backendGitHub      = "github"
+	backendGitLab      = "gitlab"
+	backendBitbucket   = "bitbucket"
+	backendGitea       = "gitea"
+	backendAzureDevOps = "azuredevops"
+)
+
+// validBackends returns all valid backends
+func validBackends() []string {
+	return []string{backendGitHub, backendGitLab, backendBitbucket, backendGitea, backendAzureDevOps}
+}
+
+// target is a resolved destination for the diff comments
+type target struct {
+	poster      comments.Poster
+	description string
+	// number is the pull or merge request number
+	number int
+	// maxLength is the backend's limit for a single comment
+	maxLength int
+	// sizeUnit is what the backend measures maxLength in
+	sizeUnit splitter.SizeUnit
+	// formatGrowth is how much longer the backend's formatting can make a
+	// comment, kept free when splitting
+	formatGrowth int
+}
+
+// newTarget validates the credentials and reference for the selected backend
+// and returns where the comments will be posted
+func newTarget() (*target, error) {
+	switch strings.ToLower(backend) {
+	case backendGitHub:
+		return newGitHubTarget()
+	case backendGitLab:
+		return newGitLabTarget()
+	case backendBitbucket:
+		return newBitbucketTarget()
+	case backendGitea:
+		return newGiteaTarget()
+	case backendAzureDevOps:
+		return newAzureDevOpsTarget()
+	default:
+		return nil, fmt.Errorf("invalid backend: %s (valid backends: %s)", backend, strings.Join(validBackends(), ", "))
+	}
+}
+
+// githubPR is a GitHub pull request together with a client to access it
+type githubPR struct {
+	client *github.Client
+	config github.Config
+	owner  string
+	repo   string
+	number int
+	host   string
+}
+
+// newGitHubPR validates the GitHub credentials and PR reference
+func newGitHubPR() (*githubPR, error) {
+	// GitHub Actions sets GITHUB_API_URL, which points at the GHES instance
+	// when running there
+	apiURL := firstNonEmpty(githubAPIURL, os.Getenv("GITHUB_API_URL"))
+	host, err := github.WebHost(apiURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ghConfig := github.Config{
+		APIURL:         apiURL,
+		UploadURL:      githubUploadURL,
+		MaxRetries:     maxRetries,
+		RetryDelay:     retryDelay,
+		BackoffFactor:  backoffFactor,
+		RequestTimeout: requestTimeout,
+	}
+
+	owner, repo, prNumber, err := github.ValidatePRReferenceForHost(prRef, host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PR reference: %w", err)
+	}
+
+	ghConfig.Credentials, err = newGitHubCredentials(owner, repo, ghConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := github.NewClient(ghConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &githubPR{
+		client: client,
+		config: ghConfig,
+		owner:  owner,
+		repo:   repo,
+		number: prNumber,
+		host:   host,
+	}, nil
+}
+
+func newGitHubTarget() (*target, error) {
+	pr, err := newGitHubPR()
+	if err != nil {
+		return nil, err
+	}
+
+	return &target{
+		poster:      github.NewPRPoster(pr.client, pr.owner, pr.repo, pr.number, pr.config),
+		description: fmt.Sprintf("PR: %s/%s#%d (%s)", pr.owner, pr.repo, pr.number, pr.host),
+		number:      pr.number,
+		maxLength:   github.MaxCommentLength,
+		sizeUnit:    splitter.SizeRunes,
+	}, nil
+}
+
+// newGitHubCredentials returns the credential provider for the configured
+// authentication method: a GitHub App when an app ID is set, otherwise a token
+func newGitHubCredentials(owner, repo string, config github.Config) (github.CredentialProvider, error) {
+	appIDValue := firstNonEmpty(githubAppID, os.Getenv("GITHUB_APP_ID"))
+	if appIDValue == "" {
+		token := firstNonEmpty(githubToken, os.Getenv("GH_TOKEN"), os.Getenv("GITHUB_TOKEN"))
+		if token == "" {
+			return nil, fmt.Errorf("GitHub token is required. Provide it via --github-token flag, GH_TOKEN, or GITHUB_TOKEN environment variable")
+		}
+		return github.StaticToken(token), nil
+	}
+
+	appID, err := strconv.ParseInt(appIDValue, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub App ID: %s", appIDValue)
+	}
+
+	var installationID int64
+	if value := firstNonEmpty(githubAppInstallationID, os.Getenv("GITHUB_APP_INSTALLATION_ID")); value != "" {
+		installationID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GitHub App installation ID: %s", value)
+		}
+	}
+
+	privateKey := []byte(os.Getenv("GITHUB_APP_PRIVATE_KEY"))
+	if githubAppPrivateKey != "" {
+		privateKey, err = os.ReadFile(githubAppPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+		}
+	}
+	if len(privateKey) == 0 {
+		return nil, fmt.Errorf("GitHub App private key is required. Provide it via --github-app-private-key flag or GITHUB_APP_PRIVATE_KEY environment variable")
+	}
+
+	app := github.AppConfig{
+		AppID:          appID,
+		InstallationID: installationID,
+		PrivateKey:     privateKey,
+		Owner:          owner,
+		Repo:           repo,
+	}
+	return github.NewAppCredentials(app, config)
+}
+
+func newGitLabTarget() (*target, error) {
+	token := firstNonEmpty(gitlabToken, os.Getenv("GITLAB_TOKEN"))
+	if token == "" {
+		return nil, fmt.Errorf("GitLab token is required. Provide it via --gitlab-token flag or GITLAB_TOKEN environment variable")
+	}
+
+	baseURL, err := gitlabBaseURL(prRef)
+	if err != nil {
+		return nil, err
+	}
+
+	project, mrIID, err := gitlab.ValidateMRReference(prRef, baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MR reference: %w", err)
+	}
+
+	glConfig := gitlab.Config{
+		Token:          token,
+		BaseURL:        baseURL,
+		MaxRetries:     maxRetries,
+		RetryDelay:     retryDelay,
+		BackoffFactor:  backoffFactor,
+		RequestTimeout: requestTimeout,
+	}
+	client := gitlab.NewClient(glConfig)
+
+	return &target{
+		poster:      gitlab.NewMRPoster(client, project, mrIID),
+		description: fmt.Sprintf("MR: %s!%d (%s)", project, mrIID, glConfig.BaseURL),
+		number:      mrIID,
+		maxLength:   gitlab.MaxNoteLength,
+		sizeUnit:    splitter.SizeRunes,
+	}, nil
+}
+
+// gitlabBaseURL returns the GitLab instance to talk to. An MR URL decides
+// its host, so the token is only ever sent to the host the MR lives on
+func gitlabBaseURL(ref string) (string, error) {
+	refURL := gitlab.ReferenceBaseURL(ref)
+	if refURL == "" {
+		return firstNonEmpty(gitlabURL, os.Getenv("CI_SERVER_URL"), gitlab.DefaultBaseURL), nil
+	}
+
+	// --gitlab-url may add the path GitLab is served under, but not point
+	// at another instance
+	if gitlabURL != "" {
+		configured, err := url.Parse(gitlabURL)
+		if err != nil {
+			return "", fmt.Errorf("invalid GitLab URL: %w", err)
+		}
+		if !strings.EqualFold(configured.Scheme+"://"+configured.Host, refURL) {
+			return "", fmt.Errorf("MR URL %s is not on the GitLab instance set by --gitlab-url (%s)", ref, gitlabURL)
+		}
+		return gitlabURL, nil
+	}
+
+	// In GitLab CI, CI_SERVER_URL has the path of an instance served under
+	// one
+	if ci, err := url.Parse(os.Getenv("CI_SERVER_URL")); err == nil && strings.EqualFold(ci.Scheme+"://"+ci.Host, refURL) {
+		return os.Getenv("CI_SERVER_URL"), nil
+	}
+	return refURL, nil
+}
+
+func newBitbucketTarget() (*target, error) {
+	token := firstNonEmpty(bitbucketToken, os.Getenv("BITBUCKET_TOKEN"))
+	if token == "" {
+		return nil, fmt.Errorf("Bitbucket token is required. Provide it via --bitbucket-token flag or BITBUCKET_TOKEN environment variable")
+	}
+
+	pr, err := bitbucket.ValidatePRReference(prRef, bitbucketURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PR reference: %w", err)
+	}
+
+	bbConfig := bitbucket.Config{
+		Token:          token,
+		Username:       firstNonEmpty(bitbucketUsername, os.Getenv("BITBUCKET_USERNAME")),
+		BaseURL:        firstNonEmpty(bitbucketURL, pr.BaseURL),
+		MaxRetries:     maxRetries,
+		RetryDelay:     retryDelay,
+		BackoffFactor:  backoffFactor,
+		RequestTimeout: requestTimeout,
+	}
+
+	if pr.Flavor == bitbucket.Server {
+		return &target{
+			poster:       bitbucket.NewServerPRPoster(bitbucket.NewServerClient(bbConfig), pr),
+			description:  fmt.Sprintf("PR: %s/%s#%d (Bitbucket Server %s)", pr.Owner, pr.Repo, pr.ID, bbConfig.BaseURL),
+			number:       pr.ID,
+			maxLength:    bitbucket.MaxCommentLength,
+			sizeUnit:     splitter.SizeBytes,
+			formatGrowth: bitbucket.FormatGrowth,
+		}, nil
+	}
+
+	return &target{
+		poster:       bitbucket.NewCloudPRPoster(bitbucket.NewCloudClient(bbConfig), pr),
+		description:  fmt.Sprintf("PR: %s/%s#%d (Bitbucket Cloud)", pr.Owner, pr.Repo, pr.ID),
+		number:       pr.ID,
+		maxLength:    bitbucket.MaxCommentLength,
+		sizeUnit:     splitter.SizeBytes,
+		formatGrowth: bitbucket.FormatGrowth,
+	}, nil
+}
+
+func newGiteaTarget() (*target, error) {
+	token := firstNonEmpty(giteaToken, os.Getenv("GITEA_TOKEN"))
+	if token == "" {
+		return nil, fmt.Errorf("Gitea token is required. Provide it via --gitea-token flag or GITEA_TOKEN environment variable")
+	}
+
+	pr, err := gitea.ValidatePRReference(prRef)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PR reference: %w", err)
+	}
+
+	baseURL := firstNonEmpty(giteaURL, pr.BaseURL)
+	if baseURL == "" {
+		return nil, fmt.Errorf("Gitea URL is required. Provide it via --gitea-url flag or use a PR URL")
+	}
+
+	gtConfig := gitea.Config{
+		Token:          token,
+		BaseURL:        baseURL,
+		MaxRetries:     maxRetries,
+		RetryDelay:     retryDelay,
+		BackoffFactor:  backoffFactor,
+		RequestTimeout: requestTimeout,
+	}
+	client := gitea.NewClient(gtConfig)
+
+	return &target{
+		poster:      gitea.NewPRPoster(client, pr),
+		description: fmt.Sprintf("PR: %s/%s#%d (%s)", pr.Owner, pr.Repo, pr.Number, baseURL),
+		number:      pr.Number,
+		maxLength:   gitea.MaxCommentLength,
+		sizeUnit:    splitter.SizeBytes,
+	}, nil
+}
+
+func newAzureDevOpsTarget() (*target, error) {
+	// A personal access token takes precedence over the pipeline's job token
+	adoConfig := azuredevops.Config{
+		PAT:            firstNonEmpty(azureDevOpsToken, os.Getenv("AZURE_DEVOPS_EXT_PAT")),
+		MaxRetries:     maxRetries,
+		RetryDelay:     retryDelay,
+		BackoffFactor:  backoffFactor,
+		RequestTimeout: requestTimeout,
+	}
+	if adoConfig.PAT == "" {
+		adoConfig.AccessToken = os.Getenv("SYSTEM_ACCESSTOKEN")
+	}
+	if adoConfig.PAT == "" && adoConfig.AccessToken == "" {
+		return nil, fmt.Errorf("Azure DevOps token is required. Provide it via --azure-devops-token flag, AZURE_DEVOPS_EXT_PAT, or SYSTEM_ACCESSTOKEN environment variable")
+	}
+
+	pr, err := azuredevops.ValidatePRReference(prRef, firstNonEmpty(azureDevOpsURL, os.Getenv("SYSTEM_COLLECTIONURI")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PR reference: %w", err)
+	}
+
+	client := azuredevops.NewClient(pr.CollectionURL, adoConfig)
+
+	return &target{
+		poster:      azuredevops.NewPRPoster(client, pr),
+		description: fmt.Sprintf("PR: %s/%s/%s#%d", pr.CollectionURL, pr.Project, pr.Repo, pr.ID),
+		number:      pr.ID,
+		maxLength:   azuredevops.MaxCommentLength,
+		sizeUnit:    splitter.SizeRunes,
+	}, nil
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
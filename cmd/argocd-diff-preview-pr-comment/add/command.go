@@ -3,67 +3,287 @@ package add
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/apistats"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
 	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/github"
 	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/prepare"
 	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/splitter"
 	"github.com/spf13/cobra"
 )
 
 var (
-	diffFile  string
+	diffFiles []string
 	maxLength int
+	sizeUnit  string
+	perApp    bool
 
-	githubToken string
-	prRef       string
+	longLines string
+
+	templateFile string
+	commitSHA    string
+
+	includeApps  []string
+	excludeApps  []string
+	includePaths []string
+	excludePaths []string
+
+	ignoreRulesFile string
+	ignoreLines     []string
+	ignoreKeys      []string
+
+	redactValues   bool
+	redactPatterns []string
+
+	backend string
+	prRef   string
+
+	githubToken     string
+	githubAPIURL    string
+	githubUploadURL string
+
+	githubAppID             string
+	githubAppInstallationID string
+	githubAppPrivateKey     string
+
+	gitlabToken string
+	gitlabURL   string
+
+	bitbucketToken    string
+	bitbucketUsername string
+	bitbucketURL      string
+
+	giteaToken string
+	giteaURL   string
+
+	azureDevOpsToken string
+	azureDevOpsURL   string
 
 	maxRetries     int
 	retryDelay     time.Duration
 	backoffFactor  float64
 	requestTimeout time.Duration
 
+	strategy string
+
+	outputs         []string
+	stepSummaryFile string
+	reportFile      string
+	reportFormat    string
+
 	dryRun bool
 )
 
 func NewAddCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "add",
-		Short: "Post ArgoCD diff to GitHub PR as comments",
-		Long: `Process ArgoCD diff files and post them as comments to GitHub Pull Requests.
+		Short: "Post ArgoCD diff to a pull or merge request as comments",
+		Long: `Process ArgoCD diff files and post them as comments to GitHub Pull Requests,
+GitLab Merge Requests, Bitbucket Pull Requests, Gitea / Forgejo Pull Requests
+or Azure Repos Pull Requests.
+
+--file - reads the diff from stdin, e.g. piped from argocd-diff-preview;
+gzip and zstd compressed diff files are decompressed transparently.
+The diffs of several environments, e.g. one per cluster, are combined into
+a single report by repeating --file with a label for each, as in
+--file dev=dev.md --file prod=prod.md. Every environment gets a heading and
+a row in a summary table, and the combined report is split and posted as
+one set of comments, updated together on reruns.
 
 If the diff file exceeds the specified max length, it will be split into
-multiple comments. The tool automatically handles GitHub rate limiting with
-configurable retry logic.
+multiple comments. Unless --max-length is set, the limit of the selected
+backend is used, measured in the unit it enforces (--size-unit):
+characters for GitHub, GitLab and Azure DevOps, bytes for Bitbucket and
+Gitea. With --per-app, every application gets its own comment(s) instead,
+so each can be reviewed and resolved independently; the summary and stats
+are only in the first. No comment ever exceeds the limit.
+--long-lines selects what happens to a single diff line too long to fit:
+  - wrap:     continue it on the following lines, marked with ↪ (default)
+  - truncate: cut it short with a note of how much was elided, in
+              --size-unit
+  - error:    fail without posting
+The layout of the comments can be customised with --template, a Go
+text/template file defining any of these templates with {{define "name"}}:
+  - header:       top of the first comment (title and summary)
+  - app:          wraps the diff of an application, must output {{.Diff}} once
+  - continuation: title of an application continued from a previous comment
+  - part:         part indicator at the end of every comment of a split diff
+  - footer:       end of the first comment's diff (stats)
+Templates that aren't defined keep the default layout. Their output counts
+towards the comment length limit.
+Applications can be left out with glob filters on their name
+(--include-app, --exclude-app) or source path (--include-path,
+--exclude-path). * and ? don't match "/", ** matches any number of
+directories. When includes are set, only applications matching one of them
+are kept; excludes always win. The summary is regenerated to list only the
+applications left, for every output.
+Noisy changes, e.g. helm.sh/chart labels bumped with every chart release,
+can be hidden with ignore rules: regular expressions matching the text of
+changed lines (--ignore-line) or their dotted YAML key path (--ignore-key),
+or a --ignore-rules file with one "line: regex" or "key: regex" rule per
+line. Hunks whose changes all match a rule are collapsed into a
+"N noise-only hunks hidden" note, and the rules are listed in the footer.
+Sensitive values are masked before anything is posted: the data and
+stringData of Secrets, env vars with names such as DB_PASSWORD, private
+keys, well-known tokens, passwords in connection strings and high-entropy
+strings. Each is replaced with <redacted:hash>, the hash changing with the
+value, so reviewers can still see that a value changed. Hashes are keyed
+with a random key for each run, so they can't be checked against guessed
+values. Add patterns with --redact-pattern; --redact=false turns redaction
+off.
+The tool automatically handles rate limiting with configurable retry logic.
+
+Backends (--backend):
+  - github: GitHub pull request comments (default)
+  - gitlab: GitLab merge request notes
+  - bitbucket: Bitbucket Cloud or Server pull request comments. Bitbucket
+    doesn't render <details> blocks, so each application is shown under a
+    heading instead
+  - gitea: Gitea or Forgejo pull request comments
+  - azuredevops: Azure Repos pull request threads
+
+Outputs (--output):
+  - comment: post the diff to the pull or merge request (default)
+  - step-summary: write the diff to the GitHub Actions job summary
+    ($GITHUB_STEP_SUMMARY), e.g. for runs without a PR. The summary is limited
+    to 1 MiB per step; larger diffs are truncated with a notice
+  - check-run: publish the diff as the "ArgoCD Diff" check run on the PR's
+    head commit, with a notice annotation per changed application (GitHub
+    only). The conclusion is neutral when applications changed and success
+    otherwise. Creating check runs requires a GitHub App or Actions token
+Several can be selected, e.g. --output comment,step-summary. --pr is
+required for the comment and check-run outputs.
+
+Comment Strategies:
+Every comment carries a hidden part marker, used to find the comments this
+tool posted on previous runs. --strategy selects what happens to them:
+  - update:   edit previous parts in place, create extra parts and delete
+              parts no longer needed (default)
+  - minimize: hide previous comments as outdated, then post a fresh set
+              (GitHub only)
+  - delete:   delete previous comments, then post a fresh set
+  - append:   leave previous comments alone and post a fresh set
 
 GitHub Token:
 The GitHub token can be provided via:
   - --github-token flag
   - GH_TOKEN environment variable
   - GITHUB_TOKEN environment variable
+To authenticate as a GitHub App instead, set --github-app-id (or
+GITHUB_APP_ID) and the app's private key via --github-app-private-key (a file)
+or GITHUB_APP_PRIVATE_KEY (the PEM contents). The installation is found from
+the PR's repository unless --github-app-installation-id is set. Installation
+tokens are renewed automatically before they expire.
+For GitHub Enterprise Server, set --github-api-url (e.g.
+https://ghe.example.com/api/v3). In GitHub Actions it is taken from
+GITHUB_API_URL automatically.
+
+GitLab Token:
+The GitLab token can be provided via:
+  - --gitlab-token flag
+  - GITLAB_TOKEN environment variable
+The GitLab URL defaults to CI_SERVER_URL when set, or https://gitlab.com.
+
+Bitbucket Token:
+The Bitbucket token can be provided via:
+  - --bitbucket-token flag
+  - BITBUCKET_TOKEN environment variable
+It is sent as a bearer token (access tokens), or as the password together
+with --bitbucket-username / BITBUCKET_USERNAME (Cloud app passwords).
+Bitbucket Server is used when --bitbucket-url is set or the PR URL is a
+Bitbucket Server URL.
+
+Gitea Token:
+The Gitea / Forgejo token can be provided via:
+  - --gitea-token flag
+  - GITEA_TOKEN environment variable
+The instance URL is taken from --gitea-url, or from the PR URL.
+
+Azure DevOps Token:
+The Azure DevOps token can be provided via:
+  - --azure-devops-token flag (personal access token)
+  - AZURE_DEVOPS_EXT_PAT environment variable (personal access token)
+  - SYSTEM_ACCESSTOKEN environment variable (Azure Pipelines job token)
 
 PR Reference:
 Accepts the following formats:
-  - owner/repo#123
-  - https://github.com/owner/repo/pull/123`,
+  - owner/repo#123 (GitHub)
+  - https://github.com/owner/repo/pull/123 (GitHub)
+  - https://ghe.example.com/owner/repo/pull/123 (GitHub Enterprise Server)
+  - group/subgroup/project!123 (GitLab)
+  - https://gitlab.com/group/project/-/merge_requests/123 (GitLab)
+  - workspace/repo#123 or PROJECT/repo#123 (Bitbucket)
+  - https://bitbucket.org/workspace/repo/pull-requests/123 (Bitbucket Cloud)
+  - https://bitbucket.example.com/projects/PROJ/repos/repo/pull-requests/123 (Bitbucket Server)
+  - owner/repo#123 (Gitea, with --gitea-url)
+  - https://git.example.com/owner/repo/pulls/123 (Gitea)
+  - project/repo#123 (Azure DevOps, with --azure-devops-url or SYSTEM_COLLECTIONURI)
+  - https://dev.azure.com/org/project/_git/repo/pullrequest/123 (Azure DevOps)`,
 		RunE: runAdd,
 	}
 
-	cmd.Flags().StringVarP(&diffFile, "file", "f", "", "Path to the diff markdown file (required)")
-	cmd.Flags().IntVarP(&maxLength, "max-length", "m", 65536, "Maximum length in bytes for a single comment (default: 65536, GitHub's limit)")
+	cmd.Flags().StringArrayVarP(&diffFiles, "file", "f", nil, "Path to the diff markdown file, optionally gzip or zstd compressed, or - to read it from stdin. Repeat as label=path to combine the diffs of several environments (required)")
+	cmd.Flags().IntVarP(&maxLength, "max-length", "m", github.MaxCommentLength, "Maximum length for a single comment, in --size-unit (defaults to the backend's limit: 65536 for GitHub, 1000000 for GitLab, 32768 for Bitbucket, 65536 for Gitea, 150000 for Azure DevOps)")
+
+	cmd.Flags().StringVar(&sizeUnit, "size-unit", string(splitter.SizeRunes), "Unit --max-length is measured in ("+strings.Join(splitter.ValidSizeUnits(), ", ")+") (defaults to the backend's unit)")
+	cmd.Flags().StringVar(&longLines, "long-lines", string(splitter.LongLinesWrap), "How to handle diff lines too long to fit in a comment ("+strings.Join(splitter.ValidLongLineModes(), ", ")+")")
+	cmd.Flags().BoolVar(&perApp, "per-app", false, "Post every application in its own comment, split further only if it exceeds --max-length")
+	cmd.Flags().StringVar(&templateFile, "template", "", "Path to a Go text/template file customising the comment layout ("+strings.Join(splitter.ValidTemplateNames(), ", ")+")")
+	cmd.Flags().StringSliceVar(&includeApps, "include-app", nil, "Only show applications whose name matches one of these globs")
+	cmd.Flags().StringSliceVar(&excludeApps, "exclude-app", nil, "Leave out applications whose name matches one of these globs")
+	cmd.Flags().StringSliceVar(&includePaths, "include-path", nil, "Only show applications whose source path matches one of these globs")
+	cmd.Flags().StringSliceVar(&excludePaths, "exclude-path", nil, "Leave out applications whose source path matches one of these globs")
+	cmd.Flags().StringVar(&ignoreRulesFile, "ignore-rules", "", "Path to a file of ignore rules, one \"line: regex\" or \"key: regex\" per line")
+	cmd.Flags().StringArrayVar(&ignoreLines, "ignore-line", nil, "Regex matching changed lines to treat as noise (can be repeated)")
+	cmd.Flags().StringArrayVar(&ignoreKeys, "ignore-key", nil, "Regex matching the dotted YAML key path of changed lines to treat as noise, e.g. 'labels\\.helm\\.sh/chart$' (can be repeated)")
+	cmd.Flags().BoolVar(&redactValues, "redact", true, "Mask Secret data, credentials and high-entropy strings before posting")
+	cmd.Flags().StringArrayVar(&redactPatterns, "redact-pattern", nil, "Extra regex of values to mask; only the first capture group is masked if it has one (can be repeated)")
+	cmd.Flags().StringVar(&commitSHA, "commit-sha", "", "Commit SHA made available to templates (default: GITHUB_SHA, CI_COMMIT_SHA, BITBUCKET_COMMIT or BUILD_SOURCEVERSION env vars)")
+
+	cmd.Flags().StringVar(&backend, "backend", backendGitHub, "Where to post comments ("+strings.Join(validBackends(), ", ")+")")
+	cmd.Flags().StringVarP(&prRef, "pr", "p", "", "Pull/merge request reference (e.g., owner/repo#123, group/project!123 or PR/MR URL) (required for the comment and check-run outputs)")
 
 	cmd.Flags().StringVarP(&githubToken, "github-token", "t", "", "GitHub personal access token (can also use GH_TOKEN or GITHUB_TOKEN env vars)")
-	cmd.Flags().StringVarP(&prRef, "pr", "p", "", "Pull request reference (e.g., owner/repo#123 or PR URL) (required)")
+	cmd.Flags().StringVar(&githubAPIURL, "github-api-url", "", "GitHub API URL for GitHub Enterprise Server, e.g. https://ghe.example.com/api/v3 (default: GITHUB_API_URL env var or https://api.github.com)")
+	cmd.Flags().StringVar(&githubUploadURL, "github-upload-url", "", "GitHub uploads API URL (default: derived from the API URL)")
+	cmd.Flags().StringVar(&githubAppID, "github-app-id", "", "GitHub App ID, to authenticate as a GitHub App instead of with a token (can also use GITHUB_APP_ID env var)")
+	cmd.Flags().StringVar(&githubAppInstallationID, "github-app-installation-id", "", "GitHub App installation ID (default: GITHUB_APP_INSTALLATION_ID env var or discovered from the repository)")
+	cmd.Flags().StringVar(&githubAppPrivateKey, "github-app-private-key", "", "Path to the GitHub App private key (can also use GITHUB_APP_PRIVATE_KEY env var with the PEM contents)")
+
+	cmd.Flags().StringVar(&gitlabToken, "gitlab-token", "", "GitLab personal, project or group access token (can also use GITLAB_TOKEN env var)")
+	cmd.Flags().StringVar(&gitlabURL, "gitlab-url", "", "GitLab instance URL (default: the host of an MR URL, CI_SERVER_URL env var or https://gitlab.com)")
+
+	cmd.Flags().StringVar(&bitbucketToken, "bitbucket-token", "", "Bitbucket access token or app password (can also use BITBUCKET_TOKEN env var)")
+	cmd.Flags().StringVar(&bitbucketUsername, "bitbucket-username", "", "Bitbucket username for app password / basic authentication (can also use BITBUCKET_USERNAME env var)")
+	cmd.Flags().StringVar(&bitbucketURL, "bitbucket-url", "", "Bitbucket Server / Data Center URL (leave empty for Bitbucket Cloud)")
+
+	cmd.Flags().StringVar(&giteaToken, "gitea-token", "", "Gitea / Forgejo access token (can also use GITEA_TOKEN env var)")
+	cmd.Flags().StringVar(&giteaURL, "gitea-url", "", "Gitea / Forgejo instance URL (default: taken from the PR URL)")
+
+	cmd.Flags().StringVar(&azureDevOpsToken, "azure-devops-token", "", "Azure DevOps personal access token (can also use AZURE_DEVOPS_EXT_PAT or SYSTEM_ACCESSTOKEN env vars)")
+	cmd.Flags().StringVar(&azureDevOpsURL, "azure-devops-url", "", "Azure DevOps organization URL, e.g. https://dev.azure.com/org (default: SYSTEM_COLLECTIONURI env var or taken from the PR URL)")
 
 	cmd.Flags().IntVar(&maxRetries, "max-retries", 3, "Maximum number of retry attempts for failed requests")
 	cmd.Flags().DurationVar(&retryDelay, "retry-delay", 2*time.Second, "Initial delay between retries")
 	cmd.Flags().Float64Var(&backoffFactor, "backoff-factor", 2.0, "Exponential backoff multiplier for retries")
 	cmd.Flags().DurationVar(&requestTimeout, "request-timeout", 30*time.Second, "HTTP request timeout")
 
+	cmd.Flags().StringVar(&strategy, "strategy", string(comments.StrategyUpdate),
+		"How to handle diff comments from previous runs ("+strings.Join(comments.ValidStrategies(), ", ")+")")
+
+	cmd.Flags().StringSliceVar(&outputs, "output", []string{outputComment},
+		"Where to write the diff ("+strings.Join(validOutputs(), ", ")+"), comma separated")
+	cmd.Flags().StringVar(&reportFile, "report-file", "", "Write a report of the run: target, parts with their apps and comment IDs and URLs, API retries and rate limit state")
+	cmd.Flags().StringVar(&reportFormat, "report-format", "", "Format of the --report-file report ("+strings.Join(validReportFormats(), ", ")+") (default: yaml for a .yaml or .yml file, json otherwise)")
+	cmd.Flags().StringVar(&stepSummaryFile, "step-summary-file", "", "Step summary file for the step-summary output (default: GITHUB_STEP_SUMMARY env var)")
+
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be done without actually posting comments")
 
 	cmd.MarkFlagRequired("file")
-	cmd.MarkFlagRequired("pr")
 
 	return cmd
 }
@@ -71,80 +291,191 @@ Accepts the following formats:
 func runAdd(cmd *cobra.Command, args []string) error {
 	log := logger.GetLogger()
 
-	token := githubToken
-	if token == "" {
-		token = os.Getenv("GH_TOKEN")
+	start := time.Now()
+	apistats.Reset()
+
+	if reportFile == "" && cmd.Flags().Changed("report-format") {
+		return fmt.Errorf("--report-format only applies with --report-file")
+	}
+	format, err := parseReportFormat(reportFormat, reportFile)
+	if err != nil {
+		return err
+	}
+
+	report := &runReport{Backend: strings.ToLower(backend), PR: prRef, Strategy: strategy, DryRun: dryRun}
+	err = run(cmd, report)
+
+	if reportFile != "" {
+		report.finish(start, err)
+		if writeErr := report.write(reportFile, format); writeErr != nil {
+			if err != nil {
+				log.Errorf("Could not write report: %v", writeErr)
+				return err
+			}
+			return writeErr
+		}
+		log.Infof("Wrote report: %s", reportFile)
+	}
+
+	return err
+}
+
+// run runs the add command, recording what it does in the report
+func run(cmd *cobra.Command, report *runReport) error {
+	log := logger.GetLogger()
+
+	commentStrategy, err := comments.ParseStrategy(strategy)
+	if err != nil {
+		return err
+	}
+
+	inputs, err := prepare.ParseInputs(diffFiles)
+	if err != nil {
+		return err
+	}
+
+	selectedOutputs, err := parseOutputs(outputs)
+	if err != nil {
+		return err
+	}
+
+	report.Outputs = selectedOutputs
+
+	postComments := slices.Contains(selectedOutputs, outputComment)
+	publishCheck := slices.Contains(selectedOutputs, outputCheckRun)
+	if (postComments || publishCheck) && prRef == "" {
+		return fmt.Errorf(`required flag(s) "pr" not set`)
+	}
+
+	if publishCheck && !strings.EqualFold(backend, backendGitHub) {
+		return fmt.Errorf("the %s output is only supported by the %s backend", outputCheckRun, backendGitHub)
+	}
+
+	var commentTarget *target
+	if postComments {
+		commentTarget, err = newTarget()
+		if err != nil {
+			return err
+		}
+
+		// Without an explicit --max-length or --size-unit, use the selected
+		// backend's limit and unit
+		if !cmd.Flags().Changed("max-length") {
+			maxLength = commentTarget.maxLength
+		}
+		if !cmd.Flags().Changed("size-unit") {
+			sizeUnit = string(commentTarget.sizeUnit)
+		}
+
+		log.Infof("Target %s", commentTarget.description)
+		report.Target = commentTarget.description
+		report.PRNumber = commentTarget.number
+	}
+
+	unit, err := splitter.ParseSizeUnit(sizeUnit)
+	if err != nil {
+		return err
 	}
-	if token == "" {
-		token = os.Getenv("GITHUB_TOKEN")
+
+	// Formatting for the backend needs room of its own
+	minLength := 1
+	if commentTarget != nil {
+		minLength += commentTarget.formatGrowth
 	}
-	if token == "" {
-		return fmt.Errorf("GitHub token is required. Provide it via --github-token flag, GH_TOKEN, or GITHUB_TOKEN environment variable")
+	if maxLength < minLength {
+		return fmt.Errorf("invalid max length: %d (must be at least %d)", maxLength, minLength)
 	}
 
-	owner, repo, prNumber, err := github.ValidatePRReference(prRef)
+	longLineMode, err := splitter.ParseLongLineMode(longLines)
 	if err != nil {
-		return fmt.Errorf("invalid PR reference: %w", err)
+		return err
 	}
 
-	log.Infof("Target PR: %s/%s#%d", owner, repo, prNumber)
-	log.Infof("Processing diff file: %s", diffFile)
-	log.Infof("Max comment length: %d bytes", maxLength)
+	var templates *splitter.Templates
+	if templateFile != "" {
+		templates, err = splitter.LoadTemplates(templateFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	prep, err := newPreparation()
+	if err != nil {
+		return err
+	}
 
 	if dryRun {
 		log.Info("DRY RUN MODE - No comments will be posted")
 	}
 
-	size, err := splitter.CountFileSize(diffFile)
+	if err := prepare.ReadInputs(inputs); err != nil {
+		return err
+	}
+	report.setInputs(inputs)
+
+	prepared, err := prepare.DiffFile(inputs, prep)
 	if err != nil {
-		return fmt.Errorf("failed to read input file: %w", err)
+		return err
+	}
+	defer os.Remove(prepared)
+
+	if slices.Contains(selectedOutputs, outputStepSummary) {
+		if err := writeStepSummary(prepared); err != nil {
+			return err
+		}
 	}
 
-	log.Infof("Input file size: %d bytes", size)
+	if publishCheck {
+		if err := publishCheckRun(prepared); err != nil {
+			return err
+		}
+	}
 
-	results, err := splitter.SplitDiffFile(diffFile, maxLength)
+	if !postComments {
+		return nil
+	}
+
+	log.Infof("Max comment length: %d %s", maxLength, unit)
+	report.MaxLength = maxLength
+	report.SizeUnit = string(unit)
+
+	results, err := splitter.Split(prepared, splitter.Options{
+		MaxLength:  maxLength - commentTarget.formatGrowth,
+		PerApp:     perApp,
+		Unit:       unit,
+		LongLines:  longLineMode,
+		Navigation: true,
+		Templates:  templates,
+		PRNumber:   commentTarget.number,
+		CommitSHA: firstNonEmpty(commitSHA, os.Getenv("GITHUB_SHA"), os.Getenv("CI_COMMIT_SHA"),
+			os.Getenv("BITBUCKET_COMMIT"), os.Getenv("BUILD_SOURCEVERSION")),
+	})
 	if err != nil {
 		return fmt.Errorf("failed to split diff file: %w", err)
 	}
 
 	if len(results) == 1 && results[0].TotalParts == 1 {
 		log.Info("No splitting needed - file is within size limit")
-		log.Infof("Content size: %d bytes", results[0].Size)
+		log.Infof("Content size: %d %s", results[0].Size, unit)
 	} else {
 		log.Infof("Split file into %d parts", len(results))
 		for _, result := range results {
-			log.Debugf("Part %d of %d - Length: %d bytes", result.PartNumber, result.TotalParts, result.Size)
+			log.Debugf("Part %d of %d - Length: %d %s", result.PartNumber, result.TotalParts, result.Size, unit)
 		}
 	}
 
-	ghConfig := github.Config{
-		Token:          token,
-		MaxRetries:     maxRetries,
-		RetryDelay:     retryDelay,
-		BackoffFactor:  backoffFactor,
-		RequestTimeout: requestTimeout,
-	}
-	client := github.NewClient(ghConfig)
-
-	log.Infof("Posting %d comment(s) to PR...", len(results))
+	report.setParts(results)
 
-	for _, result := range results {
-		log.Infof("Posting part %d of %d...", result.PartNumber, result.TotalParts)
-
-		err := client.PostPRComment(owner, repo, prNumber, result.Content, ghConfig, dryRun)
-		if err != nil {
-			return fmt.Errorf("failed to post comment part %d: %w", result.PartNumber, err)
-		}
-
-		if result.PartNumber < result.TotalParts && !dryRun {
-			time.Sleep(500 * time.Millisecond)
-		}
+	outcome, err := comments.Sync(commentTarget.poster, results, commentStrategy, dryRun)
+	report.setOutcome(outcome)
+	if err != nil {
+		return err
 	}
 
 	if dryRun {
 		log.Info("DRY RUN completed - No comments were posted")
 	} else {
-		log.Info("Successfully posted all comments to PR")
+		log.Info("Successfully posted all comments")
 	}
 
 	return nil
@@ -1,12 +1,24 @@
 package add
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
 	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 func init() {
@@ -67,8 +79,8 @@ func TestAddCommand_RequiredFlags(t *testing.T) {
 			cmd.SetArgs(tt.args)
 
 			// Disable output during test
-			cmd.SetOut(os.NewFile(0, os.DevNull))
-			cmd.SetErr(os.NewFile(0, os.DevNull))
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
 
 			err := cmd.Execute()
 
@@ -129,8 +141,8 @@ func TestAddCommand_FileValidation(t *testing.T) {
 			defer os.Unsetenv("GITHUB_TOKEN")
 
 			// Disable output during test
-			cmd.SetOut(os.NewFile(0, os.DevNull))
-			cmd.SetErr(os.NewFile(0, os.DevNull))
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
 
 			err := cmd.Execute()
 
@@ -188,8 +200,8 @@ func TestAddCommand_PRReferenceValidation(t *testing.T) {
 			defer os.Unsetenv("GITHUB_TOKEN")
 
 			// Disable output during test
-			cmd.SetOut(os.NewFile(0, os.DevNull))
-			cmd.SetErr(os.NewFile(0, os.DevNull))
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
 
 			err := cmd.Execute()
 
@@ -265,8 +277,8 @@ func TestAddCommand_TokenValidation(t *testing.T) {
 			cmd.SetArgs(args)
 
 			// Disable output during test
-			cmd.SetOut(os.NewFile(0, os.DevNull))
-			cmd.SetErr(os.NewFile(0, os.DevNull))
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
 
 			err := cmd.Execute()
 
@@ -303,8 +315,8 @@ func TestAddCommand_DryRunFlag(t *testing.T) {
 	})
 
 	// Disable output during test
-	cmd.SetOut(os.NewFile(0, os.DevNull))
-	cmd.SetErr(os.NewFile(0, os.DevNull))
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
 
 	err = cmd.Execute()
 
@@ -333,6 +345,38 @@ func TestAddCommand_MaxLengthFlag(t *testing.T) {
 	}
 }
 
+func TestAddCommand_MaxLengthValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "Zero", args: []string{"--max-length", "0"}},
+		{name: "Negative", args: []string{"--max-length", "-1"}},
+		{name: "No room left by Bitbucket formatting", args: []string{"--backend", "bitbucket", "--pr", "owner/repo#123", "--bitbucket-token", "fake-token", "--max-length", "2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewAddCommand()
+			cmd.SetArgs(append([]string{
+				"--file", "../../../testing/too-long-diff.md",
+				"--pr", "owner/repo#123",
+				"--github-token", "fake-token",
+				"--dry-run",
+			}, tt.args...))
+
+			// Disable output during test
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			if err == nil || !strings.Contains(err.Error(), "invalid max length") {
+				t.Errorf("Expected an invalid max length error, got %v", err)
+			}
+		})
+	}
+}
+
 func TestAddCommand_RetryFlags(t *testing.T) {
 	cmd := NewAddCommand()
 
@@ -353,7 +397,1094 @@ func TestAddCommand_RetryFlags(t *testing.T) {
 	}
 }
 
-// Helper function to create a test command
-func createTestCommand() *cobra.Command {
-	return NewAddCommand()
+func TestAddCommand_StrategyFlag(t *testing.T) {
+	cmd := NewAddCommand()
+
+	defaultVal, err := cmd.Flags().GetString("strategy")
+	if err != nil {
+		t.Fatalf("Failed to get strategy default value: %v", err)
+	}
+
+	if defaultVal != "update" {
+		t.Errorf("Expected default strategy 'update', got %q", defaultVal)
+	}
+}
+
+func TestAddCommand_StrategyValidation(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.md")
+	err := os.WriteFile(testFile, []byte("# Test"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		strategy    string
+		shouldError bool
+	}{
+		{name: "Update", strategy: "update", shouldError: false},
+		{name: "Minimize", strategy: "minimize", shouldError: false},
+		{name: "Delete", strategy: "delete", shouldError: false},
+		{name: "Append", strategy: "append", shouldError: false},
+		{name: "Invalid", strategy: "replace", shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewAddCommand()
+			cmd.SetArgs([]string{
+				"--file", testFile,
+				"--pr", "owner/repo#123",
+				"--github-token", "fake-token",
+				"--strategy", tt.strategy,
+				"--dry-run",
+			})
+
+			// Disable output during test
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+
+			if tt.shouldError && err == nil {
+				t.Error("Expected error for invalid strategy but got none")
+			}
+
+			if !tt.shouldError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAddCommand_SizeUnitValidation(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.md")
+	err := os.WriteFile(testFile, []byte("# Test ⚠️"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		shouldError bool
+	}{
+		{name: "Backend default", args: nil, shouldError: false},
+		{name: "Bytes", args: []string{"--size-unit", "bytes"}, shouldError: false},
+		{name: "Runes", args: []string{"--size-unit", "runes"}, shouldError: false},
+		{name: "Invalid", args: []string{"--size-unit", "words"}, shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewAddCommand()
+			cmd.SetArgs(append([]string{
+				"--file", testFile,
+				"--pr", "owner/repo#123",
+				"--github-token", "fake-token",
+				"--dry-run",
+			}, tt.args...))
+
+			// Disable output during test
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+
+			if tt.shouldError && err == nil {
+				t.Error("Expected error for invalid size unit but got none")
+			}
+
+			if !tt.shouldError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAddCommand_LongLines(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.md")
+	content := "## Argo CD Diff Preview\n\n<details>\n<summary>app</summary>\n<br>\n\n```diff\n+  data: " +
+		strings.Repeat("x", 5000) + "\n```\n\n</details>\n"
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		shouldError bool
+	}{
+		{name: "Wrap by default", args: nil, shouldError: false},
+		{name: "Wrap", args: []string{"--long-lines", "wrap"}, shouldError: false},
+		{name: "Truncate", args: []string{"--long-lines", "truncate"}, shouldError: false},
+		{name: "Error", args: []string{"--long-lines", "error"}, shouldError: true},
+		{name: "Invalid", args: []string{"--long-lines", "skip"}, shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewAddCommand()
+			cmd.SetArgs(append([]string{
+				"--file", testFile,
+				"--pr", "owner/repo#123",
+				"--github-token", "fake-token",
+				"--max-length", "2000",
+				"--dry-run",
+			}, tt.args...))
+
+			// Disable output during test
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+
+			if tt.shouldError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.shouldError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestGitLabBaseURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		ref         string
+		flag        string
+		env         string
+		expected    string
+		shouldError bool
+	}{
+		{name: "Short reference", ref: "group/project!1", expected: "https://gitlab.com"},
+		{name: "Short reference in GitLab CI", ref: "group/project!1", env: "https://gitlab.ci.example.com", expected: "https://gitlab.ci.example.com"},
+		{name: "Short reference with flag", ref: "group/project!1", flag: "https://gitlab.example.com", env: "https://gitlab.ci.example.com", expected: "https://gitlab.example.com"},
+		{name: "MR URL", ref: "https://gitlab.example.com/g/p/-/merge_requests/1", expected: "https://gitlab.example.com"},
+		{name: "MR URL outside GitLab CI of another instance", ref: "https://gitlab.example.com/g/p/-/merge_requests/1", env: "https://gitlab.com", expected: "https://gitlab.example.com"},
+		{name: "MR URL with matching flag", ref: "https://gitlab.example.com/gitlab/g/p/-/merge_requests/1", flag: "https://gitlab.example.com/gitlab", expected: "https://gitlab.example.com/gitlab"},
+		{name: "MR URL in GitLab CI of an instance under a path", ref: "https://gitlab.example.com/gitlab/g/p/-/merge_requests/1", env: "https://gitlab.example.com/gitlab", expected: "https://gitlab.example.com/gitlab"},
+		{name: "MR URL with conflicting flag", ref: "https://gitlab.com/g/p/-/merge_requests/1", flag: "https://gitlab.example.com", shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CI_SERVER_URL", tt.env)
+			previous := gitlabURL
+			gitlabURL = tt.flag
+			defer func() { gitlabURL = previous }()
+
+			got, err := gitlabBaseURL(tt.ref)
+			if tt.shouldError {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewGitLabTarget_InstanceUnderPath(t *testing.T) {
+	t.Setenv("CI_SERVER_URL", "")
+	previousRef, previousURL, previousToken := prRef, gitlabURL, gitlabToken
+	prRef = "https://example.com/gitlab/group/proj/-/merge_requests/1"
+	gitlabURL = "https://example.com/gitlab"
+	gitlabToken = "fake-token"
+	defer func() { prRef, gitlabURL, gitlabToken = previousRef, previousURL, previousToken }()
+
+	target, err := newGitLabTarget()
+	if err != nil {
+		t.Fatalf("newGitLabTarget failed: %v", err)
+	}
+	if expected := "MR: group/proj!1 (https://example.com/gitlab)"; target.description != expected {
+		t.Errorf("Expected %q, got %q", expected, target.description)
+	}
+}
+
+func TestAddCommand_BackendValidation(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.md")
+	err := os.WriteFile(testFile, []byte("# Test"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		shouldError bool
+	}{
+		{
+			name:        "GitLab with token and short reference",
+			args:        []string{"--backend", "gitlab", "--gitlab-token", "fake-token", "--pr", "group/subgroup/project!12"},
+			shouldError: false,
+		},
+		{
+			name:        "GitLab with token and MR URL",
+			args:        []string{"--backend", "gitlab", "--gitlab-token", "fake-token", "--pr", "https://gitlab.com/group/project/-/merge_requests/12"},
+			shouldError: false,
+		},
+		{
+			name:        "GitLab MR URL on another instance than --gitlab-url",
+			args:        []string{"--backend", "gitlab", "--gitlab-token", "fake-token", "--gitlab-url", "https://gitlab.example.com", "--pr", "https://gitlab.com/group/project/-/merge_requests/12"},
+			shouldError: true,
+		},
+		{
+			name:        "GitLab without token",
+			args:        []string{"--backend", "gitlab", "--pr", "group/project!12"},
+			shouldError: true,
+		},
+		{
+			name:        "GitLab with GitHub reference",
+			args:        []string{"--backend", "gitlab", "--gitlab-token", "fake-token", "--pr", "owner/repo#123"},
+			shouldError: true,
+		},
+		{
+			name:        "Minimize is not supported by GitLab",
+			args:        []string{"--backend", "gitlab", "--gitlab-token", "fake-token", "--pr", "group/project!12", "--strategy", "minimize"},
+			shouldError: true,
+		},
+		{
+			name:        "Bitbucket Cloud with token and PR URL",
+			args:        []string{"--backend", "bitbucket", "--bitbucket-token", "fake-token", "--pr", "https://bitbucket.org/workspace/repo/pull-requests/12"},
+			shouldError: false,
+		},
+		{
+			name:        "Bitbucket Server with URL flag and short reference",
+			args:        []string{"--backend", "bitbucket", "--bitbucket-token", "fake-token", "--bitbucket-url", "https://bitbucket.example.com", "--pr", "PROJ/repo#12"},
+			shouldError: false,
+		},
+		{
+			name:        "Bitbucket without token",
+			args:        []string{"--backend", "bitbucket", "--pr", "workspace/repo#12"},
+			shouldError: true,
+		},
+		{
+			name:        "Minimize is not supported by Bitbucket",
+			args:        []string{"--backend", "bitbucket", "--bitbucket-token", "fake-token", "--pr", "workspace/repo#12", "--strategy", "minimize"},
+			shouldError: true,
+		},
+		{
+			name:        "Gitea with token and PR URL",
+			args:        []string{"--backend", "gitea", "--gitea-token", "fake-token", "--pr", "https://git.example.com/owner/repo/pulls/12"},
+			shouldError: false,
+		},
+		{
+			name:        "Gitea with URL flag and short reference",
+			args:        []string{"--backend", "gitea", "--gitea-token", "fake-token", "--gitea-url", "https://git.example.com", "--pr", "owner/repo#12"},
+			shouldError: false,
+		},
+		{
+			name:        "Gitea short reference without URL",
+			args:        []string{"--backend", "gitea", "--gitea-token", "fake-token", "--pr", "owner/repo#12"},
+			shouldError: true,
+		},
+		{
+			name:        "Gitea without token",
+			args:        []string{"--backend", "gitea", "--pr", "https://git.example.com/owner/repo/pulls/12"},
+			shouldError: true,
+		},
+		{
+			name:        "Azure DevOps with token and PR URL",
+			args:        []string{"--backend", "azuredevops", "--azure-devops-token", "fake-token", "--pr", "https://dev.azure.com/org/project/_git/repo/pullrequest/42"},
+			shouldError: false,
+		},
+		{
+			name:        "Azure DevOps with URL flag and short reference",
+			args:        []string{"--backend", "azuredevops", "--azure-devops-token", "fake-token", "--azure-devops-url", "https://dev.azure.com/org", "--pr", "project/repo#42"},
+			shouldError: false,
+		},
+		{
+			name:        "Azure DevOps without token",
+			args:        []string{"--backend", "azuredevops", "--pr", "https://dev.azure.com/org/project/_git/repo/pullrequest/42"},
+			shouldError: true,
+		},
+		{
+			name:        "GitHub Enterprise Server PR URL with API URL",
+			args:        []string{"--github-token", "fake-token", "--github-api-url", "https://ghe.example.com/api/v3", "--pr", "https://ghe.example.com/owner/repo/pull/123"},
+			shouldError: false,
+		},
+		{
+			name:        "GitHub Enterprise Server PR URL without API URL",
+			args:        []string{"--github-token", "fake-token", "--pr", "https://ghe.example.com/owner/repo/pull/123"},
+			shouldError: true,
+		},
+		{
+			name:        "Invalid backend",
+			args:        []string{"--backend", "svn", "--pr", "owner/repo#123"},
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Unsetenv("GITLAB_TOKEN")
+			os.Unsetenv("GITHUB_API_URL")
+			os.Unsetenv("BITBUCKET_TOKEN")
+			os.Unsetenv("GITEA_TOKEN")
+			os.Unsetenv("AZURE_DEVOPS_EXT_PAT")
+			os.Unsetenv("SYSTEM_ACCESSTOKEN")
+			os.Unsetenv("SYSTEM_COLLECTIONURI")
+
+			cmd := NewAddCommand()
+			cmd.SetArgs(append([]string{"--file", testFile, "--dry-run"}, tt.args...))
+
+			// Disable output during test
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+
+			if tt.shouldError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.shouldError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAddCommand_GitHubAppAuth(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.md")
+	if err := os.WriteFile(testFile, []byte("# Test"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	keyFile := filepath.Join(tmpDir, "app.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		shouldError bool
+	}{
+		{
+			name:        "App ID and private key file",
+			args:        []string{"--github-app-id", "12345", "--github-app-private-key", keyFile},
+			shouldError: false,
+		},
+		{
+			name:        "App ID, installation ID and private key file",
+			args:        []string{"--github-app-id", "12345", "--github-app-installation-id", "678", "--github-app-private-key", keyFile},
+			shouldError: false,
+		},
+		{
+			name:        "App ID without private key",
+			args:        []string{"--github-app-id", "12345"},
+			shouldError: true,
+		},
+		{
+			name:        "Invalid app ID",
+			args:        []string{"--github-app-id", "my-app", "--github-app-private-key", keyFile},
+			shouldError: true,
+		},
+		{
+			name:        "Invalid private key",
+			args:        []string{"--github-app-id", "12345", "--github-app-private-key", testFile},
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// App authentication doesn't need a token
+			os.Unsetenv("GITHUB_TOKEN")
+			os.Unsetenv("GH_TOKEN")
+			os.Unsetenv("GITHUB_APP_PRIVATE_KEY")
+
+			cmd := NewAddCommand()
+			cmd.SetArgs(append([]string{"--file", testFile, "--pr", "owner/repo#123", "--dry-run"}, tt.args...))
+
+			// Disable output during test
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+
+			if tt.shouldError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.shouldError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAddCommand_Outputs(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.md")
+	if err := os.WriteFile(testFile, []byte("# Test"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	summaryFile := filepath.Join(tmpDir, "summary.md")
+
+	tests := []struct {
+		name        string
+		args        []string
+		shouldError bool
+	}{
+		{
+			name:        "Step summary only, without PR",
+			args:        []string{"--output", "step-summary", "--step-summary-file", summaryFile},
+			shouldError: false,
+		},
+		{
+			name:        "Comment and step summary",
+			args:        []string{"--output", "comment,step-summary", "--step-summary-file", summaryFile, "--github-token", "fake-token", "--pr", "owner/repo#123"},
+			shouldError: false,
+		},
+		{
+			name:        "Comment without PR",
+			args:        []string{"--output", "comment", "--github-token", "fake-token"},
+			shouldError: true,
+		},
+		{
+			name:        "Step summary without summary file",
+			args:        []string{"--output", "step-summary"},
+			shouldError: true,
+		},
+		{
+			name:        "Check run",
+			args:        []string{"--output", "check-run", "--github-token", "fake-token", "--pr", "owner/repo#123"},
+			shouldError: false,
+		},
+		{
+			name:        "Check run without PR",
+			args:        []string{"--output", "check-run", "--github-token", "fake-token"},
+			shouldError: true,
+		},
+		{
+			name:        "Check run with GitLab backend",
+			args:        []string{"--output", "check-run", "--backend", "gitlab", "--gitlab-token", "fake-token", "--pr", "group/project!12"},
+			shouldError: true,
+		},
+		{
+			name:        "Invalid output",
+			args:        []string{"--output", "slack", "--pr", "owner/repo#123"},
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Unsetenv("GITHUB_STEP_SUMMARY")
+
+			cmd := NewAddCommand()
+			cmd.SetArgs(append([]string{"--file", testFile, "--dry-run"}, tt.args...))
+
+			// Disable output during test
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+
+			if tt.shouldError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.shouldError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// Helper function to create a test command
+func createTestCommand() *cobra.Command {
+	return NewAddCommand()
+}
+
+func TestAddCommand_Template(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	validTemplate := filepath.Join(tmpDir, "valid.tmpl")
+	if err := os.WriteFile(validTemplate, []byte(`{{define "header"}}Diff for #{{.PRNumber}} at {{.CommitSHA}}
+{{end}}`), 0644); err != nil {
+		t.Fatalf("Failed to create template file: %v", err)
+	}
+
+	invalidTemplate := filepath.Join(tmpDir, "invalid.tmpl")
+	if err := os.WriteFile(invalidTemplate, []byte(`{{define "heading"}}{{.Title}}{{end}}`), 0644); err != nil {
+		t.Fatalf("Failed to create template file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		shouldError bool
+	}{
+		{name: "Valid template", args: []string{"--template", validTemplate, "--commit-sha", "abc123"}, shouldError: false},
+		{name: "Unknown template name", args: []string{"--template", invalidTemplate}, shouldError: true},
+		{name: "Missing template file", args: []string{"--template", filepath.Join(tmpDir, "missing.tmpl")}, shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewAddCommand()
+			cmd.SetArgs(append([]string{
+				"--file", "../../../testing/2-app-diff.md",
+				"--pr", "owner/repo#123",
+				"--github-token", "fake-token",
+				"--dry-run",
+			}, tt.args...))
+
+			// Disable output during test
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+
+			if tt.shouldError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.shouldError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// readFile returns the contents of a test file
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return content
+}
+
+func TestAddCommand_Filters(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		shouldError bool
+	}{
+		{name: "Include app", args: []string{"--include-app", "argocd-*"}, shouldError: false},
+		{name: "Exclude every app", args: []string{"--exclude-path", "examples/**"}, shouldError: false},
+		{name: "Several globs", args: []string{"--include-app", "a*,b*", "--exclude-app", "*-staging"}, shouldError: false},
+		{name: "Invalid glob", args: []string{"--exclude-app", "app-[0-9"}, shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewAddCommand()
+			cmd.SetArgs(append([]string{
+				"--file", "../../../testing/2-app-diff.md",
+				"--pr", "owner/repo#123",
+				"--github-token", "fake-token",
+				"--dry-run",
+			}, tt.args...))
+
+			// Disable output during test
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+
+			if tt.shouldError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.shouldError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAddCommand_IgnoreRules(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	validRules := filepath.Join(tmpDir, "valid.rules")
+	if err := os.WriteFile(validRules, []byte("# Chart bumps\nkey: labels\\.helm\\.sh/chart$\n"), 0644); err != nil {
+		t.Fatalf("Failed to create rules file: %v", err)
+	}
+
+	invalidRules := filepath.Join(tmpDir, "invalid.rules")
+	if err := os.WriteFile(invalidRules, []byte("value: foo\n"), 0644); err != nil {
+		t.Fatalf("Failed to create rules file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		shouldError bool
+	}{
+		{name: "Rules file", args: []string{"--ignore-rules", validRules}, shouldError: false},
+		{name: "Inline rules with commas", args: []string{"--ignore-line", "^\\s+replicas: [0-9]{1,3}$", "--ignore-key", "checksum/"}, shouldError: false},
+		{name: "Invalid rules file", args: []string{"--ignore-rules", invalidRules}, shouldError: true},
+		{name: "Missing rules file", args: []string{"--ignore-rules", filepath.Join(tmpDir, "missing.rules")}, shouldError: true},
+		{name: "Invalid inline regex", args: []string{"--ignore-key", "(unclosed"}, shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewAddCommand()
+			cmd.SetArgs(append([]string{
+				"--file", "../../../testing/2-app-diff.md",
+				"--pr", "owner/repo#123",
+				"--github-token", "fake-token",
+				"--dry-run",
+			}, tt.args...))
+
+			// Disable output during test
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+
+			if tt.shouldError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.shouldError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAddCommand_Redaction(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		shouldError bool
+	}{
+		{name: "Default redaction", args: nil, shouldError: false},
+		{name: "Extra patterns", args: []string{"--redact-pattern", "internal-[a-z]{3,}", "--redact-pattern", "token=([^&]+)"}, shouldError: false},
+		{name: "Redaction disabled", args: []string{"--redact=false"}, shouldError: false},
+		{name: "Invalid pattern", args: []string{"--redact-pattern", "(unclosed"}, shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewAddCommand()
+			cmd.SetArgs(append([]string{
+				"--file", "../../../testing/2-app-diff.md",
+				"--pr", "owner/repo#123",
+				"--github-token", "fake-token",
+				"--dry-run",
+			}, tt.args...))
+
+			// Disable output during test
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+
+			if tt.shouldError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.shouldError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// unparseableDiff is a diff file cut off in the middle of an application
+const unparseableDiff = "## Argo CD Diff Preview\n\n<details>\n<summary>app (apps/app.yaml)</summary>\n<br>\n\n```diff\n+  replicas: 2\n"
+
+func TestAddCommand_UnparseableFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "diff.md")
+	if err := os.WriteFile(path, []byte(unparseableDiff), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	cmd := NewAddCommand()
+	cmd.SetArgs([]string{
+		"--file", path,
+		"--pr", "owner/repo#123",
+		"--github-token", "fake-token",
+		"--dry-run",
+	})
+
+	// Disable output during test
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err != nil {
+		t.Errorf("Expected an unparseable diff file to be posted as is, got: %v", err)
+	}
+}
+
+func TestAddCommand_ReportFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	reportPath := filepath.Join(tmpDir, "report.json")
+
+	cmd := NewAddCommand()
+	cmd.SetArgs([]string{
+		"--file", "../../../testing/too-long-diff.md",
+		"--pr", "owner/repo#123",
+		"--github-token", "fake-token",
+		"--max-length", "20000",
+		"--dry-run",
+		"--report-file", reportPath,
+	})
+
+	// Disable output during test
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+
+	var report runReport
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+
+	if report.Backend != "github" || report.PRNumber != 123 || !report.DryRun {
+		t.Errorf("Unexpected target in report: %+v", report)
+	}
+	if report.InputSize == 0 {
+		t.Error("Expected the input size in the report")
+	}
+	if report.TotalParts < 2 || len(report.Parts) != report.TotalParts {
+		t.Fatalf("Expected several parts, got %d (%d listed)", report.TotalParts, len(report.Parts))
+	}
+	for i, part := range report.Parts {
+		if part.Number != i+1 {
+			t.Errorf("Expected part %d, got %d", i+1, part.Number)
+		}
+		if part.Size == 0 || part.Size > 20000 {
+			t.Errorf("Part %d: unexpected size %d", part.Number, part.Size)
+		}
+		if part.Action != string(comments.ActionCreate) && part.Action != string(comments.ActionCreateOrUpdate) {
+			t.Errorf("Part %d: expected a planned action, got %q", part.Number, part.Action)
+		}
+		if part.CommentID != 0 || part.URL != "" {
+			t.Errorf("Part %d: expected no comment in dry-run, got %d %q", part.Number, part.CommentID, part.URL)
+		}
+	}
+	if len(report.Parts[0].Apps) == 0 {
+		t.Error("Expected the apps of the first part in the report")
+	}
+	if report.API.Requests != 0 {
+		t.Errorf("Expected no API requests in dry-run, got %d", report.API.Requests)
+	}
+	if report.Error != "" {
+		t.Errorf("Unexpected error in report: %s", report.Error)
+	}
+}
+
+func TestAddCommand_ReportFileOnFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	reportPath := filepath.Join(tmpDir, "report.json")
+
+	cmd := NewAddCommand()
+	cmd.SetArgs([]string{
+		"--file", "../../../testing/2-app-diff.md",
+		"--pr", "owner/repo#123",
+		"--github-token", "fake-token",
+		"--strategy", "bogus",
+		"--report-file", reportPath,
+	})
+
+	// Disable output during test
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("Expected error but got none")
+	}
+
+	content, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("Expected a report for a failed run: %v", err)
+	}
+
+	var report runReport
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if !strings.Contains(report.Error, "bogus") {
+		t.Errorf("Expected the error in the report, got %q", report.Error)
+	}
+}
+
+func TestAddCommand_ReportFileYAML(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := []struct {
+		name   string
+		file   string
+		format string
+	}{
+		{name: "YAML extension", file: "report.yaml"},
+		{name: "YML extension", file: "report.yml"},
+		{name: "Format flag", file: "report.out", format: "YAML"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reportPath := filepath.Join(tmpDir, tt.file)
+			args := []string{
+				"--file", "../../../testing/2-app-diff.md",
+				"--pr", "owner/repo#123",
+				"--github-token", "fake-token",
+				"--dry-run",
+				"--report-file", reportPath,
+			}
+			if tt.format != "" {
+				args = append(args, "--report-format", tt.format)
+			}
+
+			cmd := NewAddCommand()
+			cmd.SetArgs(args)
+
+			// Disable output during test
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			content := readFile(t, reportPath)
+			if json.Valid(content) {
+				t.Fatalf("Expected a YAML report, got JSON:\n%s", content)
+			}
+
+			var report runReport
+			if err := yaml.Unmarshal(content, &report); err != nil {
+				t.Fatalf("Failed to decode report: %v", err)
+			}
+			if report.Backend != "github" || report.PRNumber != 123 || !report.DryRun || report.InputFile == "" {
+				t.Errorf("Unexpected report: %+v", report)
+			}
+			if report.TotalParts != 1 || len(report.Parts) != 1 || len(report.Parts[0].Apps) == 0 {
+				t.Errorf("Expected a single part with its apps, got %+v", report.Parts)
+			}
+			if !strings.Contains(string(content), "prNumber: 123\n") {
+				t.Errorf("Expected the JSON field names in the YAML report, got:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestAddCommand_ReportFormatValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "Invalid format", args: []string{"--report-file", filepath.Join(t.TempDir(), "report.json"), "--report-format", "xml"}},
+		{name: "Format without report file", args: []string{"--report-format", "yaml"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewAddCommand()
+			cmd.SetArgs(append([]string{
+				"--file", "../../../testing/2-app-diff.md",
+				"--pr", "owner/repo#123",
+				"--github-token", "fake-token",
+				"--dry-run",
+			}, tt.args...))
+
+			// Disable output during test
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			if err := cmd.Execute(); err == nil {
+				t.Error("Expected error but got none")
+			}
+		})
+	}
+}
+
+func TestParseReportFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		path     string
+		expected string
+	}{
+		{format: "", path: "report.json", expected: reportFormatJSON},
+		{format: "", path: "report", expected: reportFormatJSON},
+		{format: "", path: "out/report.YAML", expected: reportFormatYAML},
+		{format: "", path: "report.yml", expected: reportFormatYAML},
+		{format: "json", path: "report.yaml", expected: reportFormatJSON},
+		{format: "yaml", path: "report.json", expected: reportFormatYAML},
+	}
+
+	for _, tt := range tests {
+		got, err := parseReportFormat(tt.format, tt.path)
+		if err != nil {
+			t.Errorf("parseReportFormat(%q, %q) failed: %v", tt.format, tt.path, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("parseReportFormat(%q, %q) = %q, expected %q", tt.format, tt.path, got, tt.expected)
+		}
+	}
+
+	if _, err := parseReportFormat("toml", "report.toml"); err == nil {
+		t.Error("Expected an error for an invalid format")
+	}
+}
+
+func TestAddCommand_InputSources(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := readFile(t, "../../../testing/2-app-diff.md")
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	writer.Write(content)
+	writer.Close()
+	gzFile := filepath.Join(tmpDir, "diff.md.gz")
+	if err := os.WriteFile(gzFile, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		file  string
+		stdin []byte
+	}{
+		{name: "Gzip compressed file", file: gzFile},
+		{name: "Stdin", file: "-", stdin: content},
+		{name: "Compressed stdin", file: "-", stdin: buf.Bytes()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.stdin != nil {
+				reader, writer, err := os.Pipe()
+				if err != nil {
+					t.Fatalf("Failed to create pipe: %v", err)
+				}
+				old := os.Stdin
+				os.Stdin = reader
+				defer func() { os.Stdin = old }()
+
+				go func() {
+					writer.Write(tt.stdin)
+					writer.Close()
+				}()
+			}
+
+			reportPath := filepath.Join(tmpDir, "report.json")
+			cmd := NewAddCommand()
+			cmd.SetArgs([]string{
+				"--file", tt.file,
+				"--pr", "owner/repo#123",
+				"--github-token", "fake-token",
+				"--dry-run",
+				"--report-file", reportPath,
+			})
+
+			// Disable output during test
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var report runReport
+			if err := json.Unmarshal(readFile(t, reportPath), &report); err != nil {
+				t.Fatalf("Failed to decode report: %v", err)
+			}
+			if report.InputSize != len(content) {
+				t.Errorf("Expected the decompressed input size %d, got %d", len(content), report.InputSize)
+			}
+			if report.TotalParts != 1 || len(report.Parts[0].Apps) == 0 {
+				t.Errorf("Expected the applications in a single part, got %+v", report.Parts)
+			}
+		})
+	}
+}
+
+func TestAddCommand_CombinedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	reportPath := filepath.Join(tmpDir, "report.json")
+
+	cmd := NewAddCommand()
+	cmd.SetArgs([]string{
+		"--file", "dev=../../../testing/2-app-diff.md",
+		"--file", "prod=../../../testing/too-long-diff.md",
+		"--pr", "owner/repo#123",
+		"--github-token", "fake-token",
+		"--dry-run",
+		"--report-file", reportPath,
+	})
+
+	// Disable output during test
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var report runReport
+	if err := json.Unmarshal(readFile(t, reportPath), &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if len(report.Inputs) != 2 || report.Inputs[0].Label != "dev" || report.Inputs[1].Label != "prod" || report.InputFile != "" {
+		t.Errorf("Unexpected inputs in report: %+v", report.Inputs)
+	}
+
+	// Both environments are posted as one set of comments
+	var apps []string
+	for _, part := range report.Parts {
+		apps = append(apps, part.Apps...)
+	}
+	if !slices.Contains(apps, "dev/argocd-helm-chart") || !slices.Contains(apps, "prod/argocd-helm-chart") {
+		t.Errorf("Expected the applications of both environments, got %q", apps)
+	}
+}
+
+func TestAddCommand_CombinedFilesValidation(t *testing.T) {
+	cmd := NewAddCommand()
+	cmd.SetArgs([]string{
+		"--file", "../../../testing/2-app-diff.md",
+		"--file", "../../../testing/too-long-diff.md",
+		"--pr", "owner/repo#123",
+		"--github-token", "fake-token",
+		"--dry-run",
+	})
+
+	// Disable output during test
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "needs a label") {
+		t.Errorf("Expected an error asking for labels, got %v", err)
+	}
 }
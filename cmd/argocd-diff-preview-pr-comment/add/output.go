@@ -0,0 +1,74 @@
+package add
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/github"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/summary"
+)
+
+// Supported output targets
+const (
+	outputComment     = "comment"
+	outputStepSummary = "step-summary"
+	outputCheckRun    = "check-run"
+)
+
+// validOutputs returns all valid output targets
+func validOutputs() []string {
+	return []string{outputComment, outputStepSummary, outputCheckRun}
+}
+
+// parseOutputs validates the selected output targets
+func parseOutputs(values []string) ([]string, error) {
+	var selected []string
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if !slices.Contains(validOutputs(), value) {
+			return nil, fmt.Errorf("invalid output: %s (valid outputs: %s)", value, strings.Join(validOutputs(), ", "))
+		}
+		if !slices.Contains(selected, value) {
+			selected = append(selected, value)
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("at least one output is required (valid outputs: %s)", strings.Join(validOutputs(), ", "))
+	}
+	return selected, nil
+}
+
+// writeStepSummary writes the diff to the GitHub Actions job summary
+func writeStepSummary(input string) error {
+	log := logger.GetLogger()
+
+	path := firstNonEmpty(stepSummaryFile, os.Getenv("GITHUB_STEP_SUMMARY"))
+	if path == "" {
+		return fmt.Errorf("step summary file is required. Provide it via --step-summary-file flag or GITHUB_STEP_SUMMARY environment variable")
+	}
+
+	log.Infof("Writing step summary: %s", path)
+	return summary.Write(path, input, dryRun)
+}
+
+// publishCheckRun publishes the diff as a check run on the PR's head commit
+func publishCheckRun(input string) error {
+	log := logger.GetLogger()
+
+	pr, err := newGitHubPR()
+	if err != nil {
+		return err
+	}
+
+	output, err := github.BuildCheckRunOutput(input)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Publishing check run %q: %s", github.CheckRunName, output.Title)
+	return pr.client.PublishCheckRun(pr.owner, pr.repo, pr.number, output, pr.config, dryRun)
+}
@@ -0,0 +1,23 @@
+package add
+
+import (
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/filter"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/prepare"
+)
+
+// newPreparation returns the preparation selected with flags
+func newPreparation() (*prepare.Preparation, error) {
+	return prepare.New(prepare.Settings{
+		Filter: filter.Rules{
+			IncludeApps:  includeApps,
+			IncludePaths: includePaths,
+			ExcludeApps:  excludeApps,
+			ExcludePaths: excludePaths,
+		},
+		IgnoreRulesFile: ignoreRulesFile,
+		IgnoreLines:     ignoreLines,
+		IgnoreKeys:      ignoreKeys,
+		Redact:          redactValues,
+		RedactPatterns:  redactPatterns,
+	})
+}
@@ -0,0 +1,208 @@
+package add
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/apistats"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/prepare"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/splitter"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported formats for the --report-file report
+const (
+	reportFormatJSON = "json"
+	reportFormatYAML = "yaml"
+)
+
+// validReportFormats returns all valid report formats
+func validReportFormats() []string {
+	return []string{reportFormatJSON, reportFormatYAML}
+}
+
+// parseReportFormat returns the format a report is written to path in: the
+// given format, or YAML for a .yaml or .yml file and JSON for any other
+func parseReportFormat(format, path string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			return reportFormatYAML, nil
+		}
+		return reportFormatJSON, nil
+	}
+
+	format = strings.ToLower(format)
+	if !slices.Contains(validReportFormats(), format) {
+		return "", fmt.Errorf("invalid report format: %s (valid formats: %s)", format, strings.Join(validReportFormats(), ", "))
+	}
+	return format, nil
+}
+
+// runReport is the machine-readable report of a run written to --report-file
+type runReport struct {
+	Backend  string   `json:"backend" yaml:"backend"`
+	PR       string   `json:"pr,omitempty" yaml:"pr,omitempty"`
+	PRNumber int      `json:"prNumber,omitempty" yaml:"prNumber,omitempty"`
+	Target   string   `json:"target,omitempty" yaml:"target,omitempty"`
+	Outputs  []string `json:"outputs" yaml:"outputs"`
+	Strategy string   `json:"strategy" yaml:"strategy"`
+	DryRun   bool     `json:"dryRun" yaml:"dryRun"`
+
+	// InputFile is the diff file, or Inputs the labeled diff files of a
+	// combined report. InputSize is their total size
+	InputFile string        `json:"inputFile,omitempty" yaml:"inputFile,omitempty"`
+	Inputs    []reportInput `json:"inputs,omitempty" yaml:"inputs,omitempty"`
+	InputSize int           `json:"inputSize" yaml:"inputSize"`
+	MaxLength int           `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	SizeUnit  string        `json:"sizeUnit,omitempty" yaml:"sizeUnit,omitempty"`
+
+	TotalParts int          `json:"totalParts" yaml:"totalParts"`
+	Parts      []reportPart `json:"parts" yaml:"parts"`
+	// DeletedComments and MinimizedComments are previous comments removed
+	// or hidden as outdated
+	DeletedComments   []int64 `json:"deletedComments,omitempty" yaml:"deletedComments,omitempty"`
+	MinimizedComments int     `json:"minimizedComments,omitempty" yaml:"minimizedComments,omitempty"`
+
+	API             reportAPI `json:"api" yaml:"api"`
+	DurationSeconds float64   `json:"durationSeconds" yaml:"durationSeconds"`
+	Error           string    `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// reportInput is a labeled diff file of a combined report
+type reportInput struct {
+	Label string `json:"label" yaml:"label"`
+	File  string `json:"file" yaml:"file"`
+	Size  int    `json:"size" yaml:"size"`
+}
+
+// reportPart is a part of the split diff and what was done with its comment
+type reportPart struct {
+	Number int      `json:"number" yaml:"number"`
+	Size   int      `json:"size" yaml:"size"`
+	Apps   []string `json:"apps" yaml:"apps"`
+	// Action is created, updated or unchanged, or the planned create or
+	// create-or-update in dry-run mode. Empty if the run failed first
+	Action    string `json:"action,omitempty" yaml:"action,omitempty"`
+	CommentID int64  `json:"commentId,omitempty" yaml:"commentId,omitempty"`
+	URL       string `json:"url,omitempty" yaml:"url,omitempty"`
+}
+
+// reportAPI sums up the API requests of the run
+type reportAPI struct {
+	Requests               int              `json:"requests" yaml:"requests"`
+	Retries                int              `json:"retries" yaml:"retries"`
+	RateLimitWaits         int              `json:"rateLimitWaits" yaml:"rateLimitWaits"`
+	RateLimitWaitedSeconds float64          `json:"rateLimitWaitedSeconds" yaml:"rateLimitWaitedSeconds"`
+	RateLimit              *reportRateLimit `json:"rateLimit,omitempty" yaml:"rateLimit,omitempty"`
+}
+
+// reportRateLimit is the last rate limit state an API reported
+type reportRateLimit struct {
+	Limit     int        `json:"limit,omitempty" yaml:"limit,omitempty"`
+	Remaining int        `json:"remaining" yaml:"remaining"`
+	Reset     *time.Time `json:"reset,omitempty" yaml:"reset,omitempty"`
+}
+
+// setInputs records the diff files read
+func (r *runReport) setInputs(inputs []prepare.Input) {
+	r.InputSize = 0
+	for _, in := range inputs {
+		r.InputSize += len(in.Content)
+		if in.Label != "" {
+			r.Inputs = append(r.Inputs, reportInput{Label: in.Label, File: in.Path, Size: len(in.Content)})
+		}
+	}
+	if len(inputs) == 1 && inputs[0].Label == "" {
+		r.InputFile = inputs[0].Path
+	}
+}
+
+// setParts records the split results
+func (r *runReport) setParts(results []splitter.SplitResult) {
+	r.TotalParts = len(results)
+	r.Parts = make([]reportPart, 0, len(results))
+	for _, result := range results {
+		apps := result.Apps
+		if apps == nil {
+			apps = []string{}
+		}
+		r.Parts = append(r.Parts, reportPart{Number: result.PartNumber, Size: result.Size, Apps: apps})
+	}
+}
+
+// setOutcome records what was done with the comment of every part
+func (r *runReport) setOutcome(outcome *comments.Outcome) {
+	if outcome == nil {
+		return
+	}
+
+	for _, part := range outcome.Parts {
+		for i := range r.Parts {
+			if r.Parts[i].Number != part.PartNumber {
+				continue
+			}
+			r.Parts[i].Action = string(part.Action)
+			r.Parts[i].CommentID = part.Comment.ID
+			r.Parts[i].URL = part.Comment.URL
+		}
+	}
+	r.DeletedComments = outcome.Deleted
+	r.MinimizedComments = outcome.Minimized
+}
+
+// finish records the API stats, how long the run took and how it failed
+func (r *runReport) finish(start time.Time, err error) {
+	stats := apistats.Get()
+	r.API = reportAPI{
+		Requests:               stats.Requests,
+		Retries:                stats.Retries,
+		RateLimitWaits:         stats.RateLimitWaits,
+		RateLimitWaitedSeconds: stats.RateLimitWaited.Seconds(),
+	}
+	if stats.RateLimit != nil {
+		r.API.RateLimit = &reportRateLimit{Limit: stats.RateLimit.Limit, Remaining: stats.RateLimit.Remaining}
+		if !stats.RateLimit.Reset.IsZero() {
+			r.API.RateLimit.Reset = &stats.RateLimit.Reset
+		}
+	}
+
+	r.DurationSeconds = time.Since(start).Seconds()
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if r.Parts == nil {
+		r.Parts = []reportPart{}
+	}
+}
+
+// write writes the report as indented JSON or YAML
+func (r *runReport) write(path, format string) error {
+	var buf bytes.Buffer
+	if format == reportFormatYAML {
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(r); err != nil {
+			return fmt.Errorf("failed to encode report: %w", err)
+		}
+		encoder.Close()
+	} else {
+		encoder := json.NewEncoder(&buf)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(r); err != nil {
+			return fmt.Errorf("failed to encode report: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write report file: %w", err)
+	}
+	return nil
+}
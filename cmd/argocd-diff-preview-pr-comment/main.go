@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/belitre/argocd-diff-preview-pr-comment/cmd/argocd-diff-preview-pr-comment/add"
+	"github.com/belitre/argocd-diff-preview-pr-comment/cmd/argocd-diff-preview-pr-comment/split"
 	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
 	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/version"
 	"github.com/spf13/cobra"
@@ -53,6 +54,7 @@ func init() {
 	rootCmd.Version = version.GetVersion()
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(add.NewAddCommand())
+	rootCmd.AddCommand(split.NewSplitCommand())
 
 	// Add global log-level flag
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info",
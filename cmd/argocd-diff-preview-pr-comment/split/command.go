@@ -0,0 +1,290 @@
+package split
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/filter"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/github"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/prepare"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/splitter"
+	"github.com/spf13/cobra"
+)
+
+// Supported formats for writing the parts to stdout
+const (
+	formatJSON = "json"
+	formatNUL  = "nul"
+)
+
+// validFormats returns all valid stdout formats
+func validFormats() []string {
+	return []string{formatJSON, formatNUL}
+}
+
+var (
+	diffFiles []string
+	maxLength int
+	sizeUnit  string
+	perApp    bool
+	longLines string
+
+	templateFile string
+
+	includeApps  []string
+	excludeApps  []string
+	includePaths []string
+	excludePaths []string
+
+	ignoreRulesFile string
+	ignoreLines     []string
+	ignoreKeys      []string
+
+	redactValues   bool
+	redactPatterns []string
+
+	outputDir string
+	format    string
+)
+
+// part is a split part as written in the JSON format
+type part struct {
+	PartNumber int      `json:"partNumber"`
+	TotalParts int      `json:"totalParts"`
+	Size       int      `json:"size"`
+	Apps       []string `json:"apps"`
+	Content    string   `json:"content"`
+}
+
+func NewSplitCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "split",
+		Short: "Split an ArgoCD diff into parts without posting them",
+		Long: `Split an ArgoCD diff file into parts of at most --max-length, the same way
+the add command does, without talking to any pull request backend. Use it to
+feed the parts to other tools, e.g. a chat bot or an artifact upload.
+
+With --output-dir, every part is written to its own file: part-001.md,
+part-002.md and so on. Otherwise the parts are written to stdout (logs go to
+stderr) in --format:
+  - json: a JSON array of {partNumber, totalParts, size, apps, content}
+          (default)
+  - nul:  the content of every part followed by a NUL byte, e.g. for
+          xargs -0
+
+Existing part-NNN.md files in --output-dir beyond the new number of parts
+are removed, so the directory only holds the parts of the last run.
+
+The diff is prepared as the add command prepares it: --file takes the same
+paths, - for stdin, compressed diffs or label=path to combine several
+environments, and the application filters, ignore rules and redaction
+(on by default) are applied before splitting. --max-length, --size-unit,
+--per-app, --long-lines and --template work as they do for the add
+command.`,
+		RunE: runSplit,
+	}
+
+	cmd.Flags().StringArrayVarP(&diffFiles, "file", "f", nil, "Path to the diff markdown file, optionally gzip or zstd compressed, or - to read it from stdin. Repeat as label=path to combine the diffs of several environments (required)")
+	cmd.Flags().IntVarP(&maxLength, "max-length", "m", github.MaxCommentLength, "Maximum length for a single part, in --size-unit")
+	cmd.Flags().StringVar(&sizeUnit, "size-unit", string(splitter.SizeRunes), "Unit --max-length is measured in ("+strings.Join(splitter.ValidSizeUnits(), ", ")+")")
+	cmd.Flags().StringVar(&longLines, "long-lines", string(splitter.LongLinesWrap), "How to handle diff lines too long to fit in a part ("+strings.Join(splitter.ValidLongLineModes(), ", ")+")")
+	cmd.Flags().BoolVar(&perApp, "per-app", false, "Put every application in its own part, split further only if it exceeds --max-length")
+	cmd.Flags().StringVar(&templateFile, "template", "", "Path to a Go text/template file customising the layout of the parts ("+strings.Join(splitter.ValidTemplateNames(), ", ")+")")
+	cmd.Flags().StringSliceVar(&includeApps, "include-app", nil, "Only show applications whose name matches one of these globs")
+	cmd.Flags().StringSliceVar(&excludeApps, "exclude-app", nil, "Leave out applications whose name matches one of these globs")
+	cmd.Flags().StringSliceVar(&includePaths, "include-path", nil, "Only show applications whose source path matches one of these globs")
+	cmd.Flags().StringSliceVar(&excludePaths, "exclude-path", nil, "Leave out applications whose source path matches one of these globs")
+	cmd.Flags().StringVar(&ignoreRulesFile, "ignore-rules", "", "Path to a file of ignore rules, one \"line: regex\" or \"key: regex\" per line")
+	cmd.Flags().StringArrayVar(&ignoreLines, "ignore-line", nil, "Regex matching changed lines to treat as noise (can be repeated)")
+	cmd.Flags().StringArrayVar(&ignoreKeys, "ignore-key", nil, "Regex matching the dotted YAML key path of changed lines to treat as noise, e.g. 'labels\\.helm\\.sh/chart$' (can be repeated)")
+	cmd.Flags().BoolVar(&redactValues, "redact", true, "Mask Secret data, credentials and high-entropy strings before splitting")
+	cmd.Flags().StringArrayVar(&redactPatterns, "redact-pattern", nil, "Extra regex of values to mask; only the first capture group is masked if it has one (can be repeated)")
+
+	cmd.Flags().StringVarP(&outputDir, "output-dir", "o", "", "Directory to write the parts to as part-001.md, part-002.md, ... (default: write them to stdout)")
+	cmd.Flags().StringVar(&format, "format", formatJSON, "Format of the parts written to stdout ("+strings.Join(validFormats(), ", ")+")")
+
+	cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+func runSplit(cmd *cobra.Command, args []string) error {
+	format = strings.ToLower(format)
+	if !slices.Contains(validFormats(), format) {
+		return fmt.Errorf("invalid format: %s (valid formats: %s)", format, strings.Join(validFormats(), ", "))
+	}
+	if outputDir != "" && cmd.Flags().Changed("format") {
+		return fmt.Errorf("--format only applies when writing to stdout, not with --output-dir")
+	}
+
+	// Keep stdout for the parts
+	if outputDir == "" {
+		if err := logger.SetOutput("stderr"); err != nil {
+			return err
+		}
+	}
+	log := logger.GetLogger()
+
+	unit, err := splitter.ParseSizeUnit(sizeUnit)
+	if err != nil {
+		return err
+	}
+
+	if maxLength <= 0 {
+		return fmt.Errorf("invalid max length: %d (must be at least 1)", maxLength)
+	}
+
+	longLineMode, err := splitter.ParseLongLineMode(longLines)
+	if err != nil {
+		return err
+	}
+
+	var templates *splitter.Templates
+	if templateFile != "" {
+		templates, err = splitter.LoadTemplates(templateFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	inputs, err := prepare.ParseInputs(diffFiles)
+	if err != nil {
+		return err
+	}
+
+	prep, err := prepare.New(prepare.Settings{
+		Filter: filter.Rules{
+			IncludeApps:  includeApps,
+			IncludePaths: includePaths,
+			ExcludeApps:  excludeApps,
+			ExcludePaths: excludePaths,
+		},
+		IgnoreRulesFile: ignoreRulesFile,
+		IgnoreLines:     ignoreLines,
+		IgnoreKeys:      ignoreKeys,
+		Redact:          redactValues,
+		RedactPatterns:  redactPatterns,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := prepare.ReadInputs(inputs); err != nil {
+		return err
+	}
+
+	prepared, err := prepare.DiffFile(inputs, prep)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(prepared)
+
+	results, err := splitter.Split(prepared, splitter.Options{
+		MaxLength: maxLength,
+		PerApp:    perApp,
+		Unit:      unit,
+		LongLines: longLineMode,
+		Templates: templates,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to split diff file: %w", err)
+	}
+
+	log.Infof("Split file into %d part(s)", len(results))
+
+	if outputDir != "" {
+		return writeFiles(outputDir, results)
+	}
+	if format == formatNUL {
+		return writeNUL(cmd.OutOrStdout(), results)
+	}
+	return writeJSON(cmd.OutOrStdout(), results)
+}
+
+// partFileName returns the name of the file a part is written to
+func partFileName(partNumber int) string {
+	return fmt.Sprintf("part-%03d.md", partNumber)
+}
+
+// partFileRegexp matches the names of the files parts are written to
+var partFileRegexp = regexp.MustCompile(`^part-[0-9]{3,}\.md$`)
+
+// writeFiles writes every part to its own file in dir, removing the part
+// files left over from a previous run that produced more parts
+func writeFiles(dir string, results []splitter.SplitResult) error {
+	log := logger.GetLogger()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	for _, result := range results {
+		path := filepath.Join(dir, partFileName(result.PartNumber))
+		if err := os.WriteFile(path, []byte(result.Content), 0644); err != nil {
+			return fmt.Errorf("failed to write part %d: %w", result.PartNumber, err)
+		}
+		log.Infof("Wrote part %d of %d (%d %s): %s", result.PartNumber, result.TotalParts, result.Size, result.Unit, path)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read output directory: %w", err)
+	}
+	written := make(map[string]bool, len(results))
+	for _, result := range results {
+		written[partFileName(result.PartNumber)] = true
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !partFileRegexp.MatchString(entry.Name()) || written[entry.Name()] {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove stale part: %w", err)
+		}
+		log.Infof("Removed stale part: %s", path)
+	}
+	return nil
+}
+
+// writeJSON writes the parts as a JSON array
+func writeJSON(w io.Writer, results []splitter.SplitResult) error {
+	parts := make([]part, 0, len(results))
+	for _, result := range results {
+		apps := result.Apps
+		if apps == nil {
+			apps = []string{}
+		}
+		parts = append(parts, part{
+			PartNumber: result.PartNumber,
+			TotalParts: result.TotalParts,
+			Size:       result.Size,
+			Apps:       apps,
+			Content:    result.Content,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(parts); err != nil {
+		return fmt.Errorf("failed to write parts: %w", err)
+	}
+	return nil
+}
+
+// writeNUL writes the content of every part followed by a NUL byte
+func writeNUL(w io.Writer, results []splitter.SplitResult) error {
+	for _, result := range results {
+		if _, err := io.WriteString(w, result.Content+"\x00"); err != nil {
+			return fmt.Errorf("failed to write parts: %w", err)
+		}
+	}
+	return nil
+}
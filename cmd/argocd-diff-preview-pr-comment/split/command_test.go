@@ -0,0 +1,251 @@
+package split
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/splitter"
+)
+
+func init() {
+	// Initialize logger for tests
+	logger.Initialize(logger.ErrorLevel)
+}
+
+// runCommand runs the split command and returns what it wrote to stdout
+func runCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	cmd := NewSplitCommand()
+	cmd.SetArgs(args)
+	cmd.SetOut(&out)
+	cmd.SetErr(io.Discard)
+
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestSplitCommand_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "Missing file", args: []string{}},
+		{name: "File not found", args: []string{"--file", "does-not-exist.md"}},
+		{name: "Invalid format", args: []string{"--file", "../../../testing/2-app-diff.md", "--format", "yaml"}},
+		{name: "Format with output dir", args: []string{"--file", "../../../testing/2-app-diff.md", "--format", "nul", "--output-dir", "parts"}},
+		{name: "Invalid size unit", args: []string{"--file", "../../../testing/2-app-diff.md", "--size-unit", "words"}},
+		{name: "Invalid long lines mode", args: []string{"--file", "../../../testing/2-app-diff.md", "--long-lines", "fold"}},
+		{name: "Zero max length", args: []string{"--file", "../../../testing/2-app-diff.md", "--max-length", "0"}},
+		{name: "Negative max length", args: []string{"--file", "../../../testing/2-app-diff.md", "--max-length", "-1"}},
+		{name: "Invalid glob", args: []string{"--file", "../../../testing/2-app-diff.md", "--exclude-app", "app-[0-9"}},
+		{name: "Invalid redaction pattern", args: []string{"--file", "../../../testing/2-app-diff.md", "--redact-pattern", "(unclosed"}},
+		{name: "Unlabeled files combined", args: []string{"--file", "../../../testing/2-app-diff.md", "--file", "../../../testing/too-long-diff.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runCommand(t, tt.args...); err == nil {
+				t.Error("Expected error but got none")
+			}
+		})
+	}
+}
+
+func TestSplitCommand_JSON(t *testing.T) {
+	out, err := runCommand(t, "--file", "../../../testing/too-long-diff.md", "--max-length", "20000")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var parts []part
+	if err := json.Unmarshal([]byte(out), &parts); err != nil {
+		t.Fatalf("Failed to decode output: %v\n%s", err, out)
+	}
+
+	if len(parts) < 2 {
+		t.Fatalf("Expected several parts, got %d", len(parts))
+	}
+	for i, p := range parts {
+		if p.PartNumber != i+1 || p.TotalParts != len(parts) {
+			t.Errorf("Part %d: unexpected numbering %d of %d", i+1, p.PartNumber, p.TotalParts)
+		}
+		if p.Size == 0 || p.Size > 20000 || p.Size != splitter.SizeRunes.Measure(p.Content) {
+			t.Errorf("Part %d: unexpected size %d", p.PartNumber, p.Size)
+		}
+		if n, ok := splitter.ParsePartMarker(p.Content); !ok || n != p.PartNumber {
+			t.Errorf("Part %d: expected its part marker", p.PartNumber)
+		}
+	}
+	if len(parts[0].Apps) == 0 {
+		t.Error("Expected the apps of the first part")
+	}
+}
+
+func TestSplitCommand_NUL(t *testing.T) {
+	jsonOut, err := runCommand(t, "--file", "../../../testing/too-long-diff.md", "--max-length", "20000")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var parts []part
+	if err := json.Unmarshal([]byte(jsonOut), &parts); err != nil {
+		t.Fatalf("Failed to decode output: %v", err)
+	}
+
+	out, err := runCommand(t, "--file", "../../../testing/too-long-diff.md", "--max-length", "20000", "--format", "nul")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !strings.HasSuffix(out, "\x00") {
+		t.Fatal("Expected the output to end with a NUL byte")
+	}
+	contents := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	if len(contents) != len(parts) {
+		t.Fatalf("Expected %d parts, got %d", len(parts), len(contents))
+	}
+	for i, content := range contents {
+		if content != parts[i].Content {
+			t.Errorf("Part %d differs from the JSON output", i+1)
+		}
+	}
+}
+
+func TestSplitCommand_OutputDir(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "parts")
+
+	out, err := runCommand(t, "--file", "../../../testing/too-long-diff.md", "--max-length", "20000", "--output-dir", outDir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out != "" {
+		t.Errorf("Expected nothing written to stdout, got %q", out)
+	}
+
+	results, err := splitter.Split("../../../testing/too-long-diff.md", splitter.Options{MaxLength: 20000, Unit: splitter.SizeRunes})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("Failed to read output directory: %v", err)
+	}
+	if len(entries) != len(results) {
+		t.Fatalf("Expected %d files, got %d", len(results), len(entries))
+	}
+
+	for _, result := range results {
+		content, err := os.ReadFile(filepath.Join(outDir, partFileName(result.PartNumber)))
+		if err != nil {
+			t.Fatalf("Failed to read part %d: %v", result.PartNumber, err)
+		}
+		if string(content) != result.Content {
+			t.Errorf("Part %d differs from the split result", result.PartNumber)
+		}
+	}
+}
+
+func TestSplitCommand_StaleParts(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "parts")
+
+	if _, err := runCommand(t, "--file", "../../../testing/too-long-diff.md", "--max-length", "20000", "--output-dir", outDir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "notes.md"), []byte("notes"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if _, err := runCommand(t, "--file", "../../../testing/2-app-diff.md", "--output-dir", outDir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("Failed to read output directory: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "notes.md,part-001.md" {
+		t.Errorf("Expected only the new part and other files left, got %v", names)
+	}
+}
+
+func TestSplitCommand_Preparation(t *testing.T) {
+	diff := "<details>\n<summary>db (apps/db.yaml)</summary>\n<br>\n\n```diff\n" +
+		" apiVersion: v1\n" +
+		" data:\n" +
+		"+  password: c3dvcmRmaXNo\n" +
+		" kind: Secret\n" +
+		"```\n\n</details>\n"
+	path := filepath.Join(t.TempDir(), "diff.md")
+	if err := os.WriteFile(path, []byte(diff), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:        "Redacted by default",
+			args:        []string{"--file", path},
+			contains:    []string{"password: <redacted:"},
+			notContains: []string{"c3dvcmRmaXNo"},
+		},
+		{
+			name:     "Redaction disabled",
+			args:     []string{"--file", path, "--redact=false"},
+			contains: []string{"password: c3dvcmRmaXNo"},
+		},
+		{
+			name:        "Application filtered out",
+			args:        []string{"--file", "../../../testing/2-app-diff.md", "--exclude-app", "argocd-*"},
+			contains:    []string{"Total: 0 files changed"},
+			notContains: []string{"argocd-helm-chart"},
+		},
+		{
+			name:     "Labeled files combined",
+			args:     []string{"--file", "dev=../../../testing/2-app-diff.md", "--file", "prod=" + path},
+			contains: []string{"### Environment: dev", "### Environment: prod", "password: <redacted:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCommand(t, append(tt.args, "--format", "nul")...)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, expected := range tt.contains {
+				if !strings.Contains(out, expected) {
+					t.Errorf("Expected the parts to contain %q, got:\n%s", expected, out)
+				}
+			}
+			for _, unexpected := range tt.notContains {
+				if strings.Contains(out, unexpected) {
+					t.Errorf("Expected the parts not to contain %q", unexpected)
+				}
+			}
+		})
+	}
+}
+
+func TestPartFileName(t *testing.T) {
+	for partNumber, expected := range map[int]string{1: "part-001.md", 42: "part-042.md", 1000: "part-1000.md"} {
+		if got := partFileName(partNumber); got != expected {
+			t.Errorf("partFileName(%d) = %q, expected %q", partNumber, got, expected)
+		}
+	}
+}
@@ -4,8 +4,10 @@ go 1.25.7
 
 require (
 	github.com/google/go-github/v69 v69.2.0
+	github.com/klauspost/compress v1.18.0
 	github.com/spf13/cobra v1.10.2
 	go.uber.org/zap v1.27.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,102 @@
+package apistats
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Stats counts the API requests made during a run
+type Stats struct {
+	// Requests is the number of requests sent, including retries
+	Requests int
+	// Retries is the number of requests that were retries of a failed one
+	Retries int
+	// RateLimitWaits is how many times a rate limit was waited out, for
+	// RateLimitWaited in total
+	RateLimitWaits  int
+	RateLimitWaited time.Duration
+	// RateLimit is the last rate limit state an API reported, nil if none did
+	RateLimit *RateLimit
+}
+
+// RateLimit is the rate limit state reported by an API
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+var (
+	mu    sync.Mutex
+	stats Stats
+)
+
+// RecordRequest counts a request, retry tells whether it retries a failed one
+func RecordRequest(retry bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	stats.Requests++
+	if retry {
+		stats.Retries++
+	}
+}
+
+// RecordRateLimitWait counts waiting for a rate limit to reset
+func RecordRateLimitWait(wait time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	stats.RateLimitWaits++
+	stats.RateLimitWaited += wait
+}
+
+// RecordRateLimit keeps the rate limit state reported with a response
+func RecordRateLimit(rateLimit RateLimit) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	stats.RateLimit = &rateLimit
+}
+
+// RecordRateLimitHeaders keeps the rate limit state from the RateLimit-* or
+// X-RateLimit-* headers of a response, if it has them
+func RecordRateLimitHeaders(header http.Header) {
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-"} {
+		remaining, err := strconv.Atoi(header.Get(prefix + "Remaining"))
+		if err != nil {
+			continue
+		}
+
+		rateLimit := RateLimit{Remaining: remaining}
+		rateLimit.Limit, _ = strconv.Atoi(header.Get(prefix + "Limit"))
+		if epoch, err := strconv.ParseInt(header.Get(prefix+"Reset"), 10, 64); err == nil {
+			rateLimit.Reset = time.Unix(epoch, 0).UTC()
+		}
+		RecordRateLimit(rateLimit)
+		return
+	}
+}
+
+// Get returns the stats recorded so far
+func Get() Stats {
+	mu.Lock()
+	defer mu.Unlock()
+
+	result := stats
+	if stats.RateLimit != nil {
+		rateLimit := *stats.RateLimit
+		result.RateLimit = &rateLimit
+	}
+	return result
+}
+
+// Reset clears the stats recorded so far
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	stats = Stats{}
+}
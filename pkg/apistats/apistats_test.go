@@ -0,0 +1,72 @@
+package apistats
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	RecordRequest(false)
+	RecordRequest(true)
+	RecordRateLimitWait(2 * time.Second)
+	RecordRateLimitWait(3 * time.Second)
+
+	stats := Get()
+	if stats.Requests != 2 || stats.Retries != 1 {
+		t.Errorf("Expected 2 requests and 1 retry, got %+v", stats)
+	}
+	if stats.RateLimitWaits != 2 || stats.RateLimitWaited != 5*time.Second {
+		t.Errorf("Expected 2 rate limit waits for 5s, got %d for %v", stats.RateLimitWaits, stats.RateLimitWaited)
+	}
+
+	Reset()
+	if stats := Get(); stats.Requests != 0 || stats.RateLimit != nil {
+		t.Errorf("Expected stats to be cleared, got %+v", stats)
+	}
+}
+
+func TestRecordRateLimitHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected *RateLimit
+	}{
+		{
+			name:     "no headers",
+			headers:  map[string]string{},
+			expected: nil,
+		},
+		{
+			name:     "RateLimit headers",
+			headers:  map[string]string{"RateLimit-Limit": "2000", "RateLimit-Remaining": "1999", "RateLimit-Reset": "1700000000"},
+			expected: &RateLimit{Limit: 2000, Remaining: 1999, Reset: time.Unix(1700000000, 0).UTC()},
+		},
+		{
+			name:     "X-RateLimit headers without a reset",
+			headers:  map[string]string{"X-RateLimit-Limit": "60", "X-RateLimit-Remaining": "0"},
+			expected: &RateLimit{Limit: 60, Remaining: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Reset()
+			defer Reset()
+
+			header := http.Header{}
+			for name, value := range tt.headers {
+				header.Set(name, value)
+			}
+			RecordRateLimitHeaders(header)
+
+			got := Get().RateLimit
+			if (got == nil) != (tt.expected == nil) || (got != nil && *got != *tt.expected) {
+				t.Errorf("Expected rate limit %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
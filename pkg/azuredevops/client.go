@@ -0,0 +1,290 @@
+package azuredevops
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/rest"
+)
+
+// MaxCommentLength is Azure DevOps' limit, in characters, for the content of a
+// single comment
+const MaxCommentLength = 150000
+
+// apiVersion is the Azure DevOps REST API version used for all requests
+const apiVersion = "7.1"
+
+// firstCommentID is the ID Azure DevOps gives the first comment of a thread,
+// which holds the diff
+const firstCommentID = 1
+
+// Client represents an Azure DevOps API client
+type Client struct {
+	rest *rest.Client
+}
+
+// Config holds configuration for Azure DevOps client. Either PAT or
+// AccessToken must be set
+type Config struct {
+	// PAT is a personal access token, sent using basic authentication
+	PAT string
+	// AccessToken is an OAuth token such as the pipeline's
+	// SYSTEM_ACCESSTOKEN, sent as a bearer token
+	AccessToken    string
+	MaxRetries     int
+	RetryDelay     time.Duration
+	BackoffFactor  float64
+	RequestTimeout time.Duration
+}
+
+// PullRequest identifies a pull request in an Azure Repos repository
+type PullRequest struct {
+	// CollectionURL is the organization or collection URL,
+	// e.g. https://dev.azure.com/org
+	CollectionURL string
+	Project       string
+	Repo          string
+	ID            int
+}
+
+// thread is the subset of the Azure DevOps comment thread resource used by
+// this client
+type thread struct {
+	ID            int64           `json:"id"`
+	IsDeleted     bool            `json:"isDeleted"`
+	ThreadContext json.RawMessage `json:"threadContext"`
+	Comments      []struct {
+		ID          int64  `json:"id"`
+		Content     string `json:"content"`
+		CommentType string `json:"commentType"`
+		IsDeleted   bool   `json:"isDeleted"`
+		Author      struct {
+			ID string `json:"id"`
+		} `json:"author"`
+	} `json:"comments"`
+}
+
+// NewClient creates a new Azure DevOps client for the given organization or
+// collection URL
+func NewClient(collectionURL string, config Config) *Client {
+	restConfig := rest.Config{
+		MaxRetries:     config.MaxRetries,
+		RetryDelay:     config.RetryDelay,
+		BackoffFactor:  config.BackoffFactor,
+		RequestTimeout: config.RequestTimeout,
+	}
+
+	return &Client{
+		rest: rest.NewClient(collectionURL, restConfig, func(req *http.Request) {
+			if config.AccessToken != "" {
+				req.Header.Set("Authorization", "Bearer "+config.AccessToken)
+				return
+			}
+			req.SetBasicAuth("", config.PAT)
+		}),
+	}
+}
+
+// threadsPath returns the API path of the comment threads of a pull request
+func threadsPath(pr PullRequest) string {
+	return fmt.Sprintf("/%s/_apis/git/repositories/%s/pullRequests/%d/threads",
+		url.PathEscape(pr.Project), url.PathEscape(pr.Repo), pr.ID)
+}
+
+// PostPRThread creates a new comment thread on a pull request. Threads are
+// created closed so they don't block policies requiring resolved comments
+func (c *Client) PostPRThread(pr PullRequest, content string) (comments.Comment, error) {
+	log := logger.GetLogger()
+
+	body := map[string]interface{}{
+		"comments": []map[string]interface{}{
+			{"parentCommentId": 0, "content": content, "commentType": "text"},
+		},
+		"status": "closed",
+	}
+
+	var created thread
+	path := fmt.Sprintf("%s?api-version=%s", threadsPath(pr), apiVersion)
+	if _, err := c.rest.Do("POST", path, body, &created); err != nil {
+		return comments.Comment{}, fmt.Errorf("failed to post thread: %w", err)
+	}
+
+	log.Infof("Successfully posted thread to PR %d", pr.ID)
+	result := comments.Comment{ID: created.ID, Body: content, URL: threadURL(pr, created.ID)}
+	if len(created.Comments) > 0 {
+		result.Author = created.Comments[0].Author.ID
+	}
+	return result, nil
+}
+
+// ListPRThreads returns the general comment threads of a pull request as
+// comments, using the thread ID as comment ID and its first comment as body.
+// File comments, system threads and deleted threads are skipped
+func (c *Client) ListPRThreads(pr PullRequest) ([]comments.Comment, error) {
+	var response struct {
+		Value []thread `json:"value"`
+	}
+
+	path := fmt.Sprintf("%s?api-version=%s", threadsPath(pr), apiVersion)
+	if _, err := c.rest.Do("GET", path, nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to list threads: %w", err)
+	}
+
+	var result []comments.Comment
+	for _, t := range response.Value {
+		// Threads with a context are attached to a file
+		isFileThread := len(t.ThreadContext) > 0 && string(t.ThreadContext) != "null"
+		if t.IsDeleted || isFileThread || len(t.Comments) == 0 {
+			continue
+		}
+
+		first := t.Comments[0]
+		if first.IsDeleted || first.CommentType == "system" {
+			continue
+		}
+
+		result = append(result, comments.Comment{
+			ID:     t.ID,
+			Body:   first.Content,
+			Author: first.Author.ID,
+			URL:    threadURL(pr, t.ID),
+		})
+	}
+
+	return result, nil
+}
+
+// threadURL returns the web URL of a pull request thread
+func threadURL(pr PullRequest, threadID int64) string {
+	return fmt.Sprintf("%s/%s/_git/%s/pullrequest/%d?discussionId=%d",
+		strings.TrimSuffix(pr.CollectionURL, "/"), url.PathEscape(pr.Project), url.PathEscape(pr.Repo), pr.ID, threadID)
+}
+
+// UpdatePRThread replaces the content of the first comment of a thread
+func (c *Client) UpdatePRThread(pr PullRequest, threadID int64, content string) error {
+	log := logger.GetLogger()
+
+	path := fmt.Sprintf("%s/%d/comments/%d?api-version=%s", threadsPath(pr), threadID, firstCommentID, apiVersion)
+	if _, err := c.rest.Do("PATCH", path, map[string]string{"content": content}, nil); err != nil {
+		return fmt.Errorf("failed to update thread: %w", err)
+	}
+
+	log.Infof("Successfully updated thread %d", threadID)
+	return nil
+}
+
+// DeletePRThread deletes the first comment of a thread. Azure DevOps has no
+// API to delete a thread, but one whose comments are deleted is not shown
+func (c *Client) DeletePRThread(pr PullRequest, threadID int64) error {
+	log := logger.GetLogger()
+
+	path := fmt.Sprintf("%s/%d/comments/%d?api-version=%s", threadsPath(pr), threadID, firstCommentID, apiVersion)
+	if _, err := c.rest.Do("DELETE", path, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete thread: %w", err)
+	}
+
+	log.Infof("Successfully deleted thread %d", threadID)
+	return nil
+}
+
+// GetAuthenticatedUserID returns the ID of the identity the token belongs to
+func (c *Client) GetAuthenticatedUserID() (string, error) {
+	var data struct {
+		AuthenticatedUser struct {
+			ID string `json:"id"`
+		} `json:"authenticatedUser"`
+	}
+	if _, err := c.rest.Do("GET", "/_apis/connectionData", nil, &data); err != nil {
+		return "", fmt.Errorf("failed to get authenticated user: %w", err)
+	}
+	return data.AuthenticatedUser.ID, nil
+}
+
+// ValidatePRReference validates and parses an Azure Repos pull request reference
+// Accepts formats: https://dev.azure.com/org/project/_git/repo/pullrequest/123,
+// https://org.visualstudio.com/project/_git/repo/pullrequest/123 and
+// project/repo#123 (collectionURL must be set)
+func ValidatePRReference(ref, collectionURL string) (PullRequest, error) {
+	if strings.HasPrefix(ref, "dev.azure.com/") {
+		ref = "https://" + ref
+	}
+
+	// Handle URL format: https://dev.azure.com/org/project/_git/repo/pullrequest/123
+	if strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://") {
+		u, err := url.Parse(ref)
+		if err != nil {
+			return PullRequest{}, fmt.Errorf("invalid Azure DevOps PR URL: %w", err)
+		}
+
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		idx := -1
+		for i, part := range parts {
+			if part == "_git" {
+				idx = i
+				break
+			}
+		}
+		if idx < 1 || len(parts) < idx+4 || !strings.EqualFold(parts[idx+2], "pullrequest") {
+			return PullRequest{}, fmt.Errorf("invalid Azure DevOps PR URL format")
+		}
+
+		prID, err := strconv.Atoi(parts[idx+3])
+		if err != nil {
+			return PullRequest{}, fmt.Errorf("invalid PR number in URL: %s", parts[idx+3])
+		}
+
+		// Everything before the project is the organization or collection
+		u.Path = ""
+		if idx > 1 {
+			u.Path = "/" + strings.Join(parts[:idx-1], "/")
+		}
+		u.RawPath = ""
+		u.RawQuery = ""
+		u.Fragment = ""
+
+		return PullRequest{
+			CollectionURL: u.String(),
+			Project:       parts[idx-1],
+			Repo:          parts[idx+1],
+			ID:            prID,
+		}, nil
+	}
+
+	// Handle short format: project/repo#123
+	if strings.Contains(ref, "#") {
+		if collectionURL == "" {
+			return PullRequest{}, fmt.Errorf("the organization URL is required with the project/repo#123 format")
+		}
+
+		parts := strings.Split(ref, "#")
+		if len(parts) != 2 {
+			return PullRequest{}, fmt.Errorf("invalid PR reference format")
+		}
+
+		repoParts := strings.Split(parts[0], "/")
+		if len(repoParts) != 2 || repoParts[0] == "" || repoParts[1] == "" {
+			return PullRequest{}, fmt.Errorf("invalid repository format, expected project/repo")
+		}
+
+		prID, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return PullRequest{}, fmt.Errorf("invalid PR number: %s", parts[1])
+		}
+
+		return PullRequest{
+			CollectionURL: strings.TrimSuffix(collectionURL, "/"),
+			Project:       repoParts[0],
+			Repo:          repoParts[1],
+			ID:            prID,
+		}, nil
+	}
+
+	return PullRequest{}, fmt.Errorf("invalid PR reference format, expected https://dev.azure.com/org/project/_git/repo/pullrequest/123 or project/repo#123")
+}
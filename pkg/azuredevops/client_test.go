@@ -0,0 +1,248 @@
+package azuredevops
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+)
+
+func init() {
+	// Initialize logger for tests
+	logger.Initialize(logger.ErrorLevel)
+}
+
+func TestPostPRThread_PAT(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Expected POST request, got %s", r.Method)
+		}
+
+		if r.URL.EscapedPath() != "/org/My%20Project/_apis/git/repositories/repo/pullRequests/42/threads" {
+			t.Errorf("Unexpected path: %s", r.URL.EscapedPath())
+		}
+
+		if r.URL.Query().Get("api-version") != apiVersion {
+			t.Errorf("Expected api-version %s, got %q", apiVersion, r.URL.Query().Get("api-version"))
+		}
+
+		expected := "Basic " + base64.StdEncoding.EncodeToString([]byte(":test-pat"))
+		if r.Header.Get("Authorization") != expected {
+			t.Errorf("Expected Authorization %q, got %q", expected, r.Header.Get("Authorization"))
+		}
+
+		var body struct {
+			Comments []struct {
+				Content string `json:"content"`
+			} `json:"comments"`
+			Status string `json:"status"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body.Comments) != 1 || body.Comments[0].Content != "Test comment" {
+			t.Errorf("Unexpected thread body: %+v", body)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 7})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/org", Config{PAT: "test-pat", RequestTimeout: 30 * time.Second})
+	pr := PullRequest{CollectionURL: server.URL + "/org", Project: "My Project", Repo: "repo", ID: 42}
+
+	comment, err := client.PostPRThread(pr, "Test comment")
+	if err != nil {
+		t.Fatalf("PostPRThread failed: %v", err)
+	}
+
+	expectedURL := server.URL + "/org/My%20Project/_git/repo/pullrequest/42?discussionId=7"
+	if comment.ID != 7 || comment.URL != expectedURL {
+		t.Errorf("Expected thread 7 at %s, got %d at %s", expectedURL, comment.ID, comment.URL)
+	}
+}
+
+func TestListPRThreads_SkipsNonGeneralThreads(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer system-token" {
+			t.Errorf("Expected bearer token, got %q", r.Header.Get("Authorization"))
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"value": []map[string]interface{}{
+				{"id": 1, "comments": []map[string]interface{}{
+					{"id": 1, "content": "diff", "commentType": "text", "author": map[string]string{"id": "user-id"}},
+				}},
+				{"id": 2, "comments": []map[string]interface{}{
+					{"id": 1, "content": "policy update", "commentType": "system"},
+				}},
+				{"id": 3, "threadContext": map[string]string{"filePath": "/app.yaml"}, "comments": []map[string]interface{}{
+					{"id": 1, "content": "file comment", "commentType": "text"},
+				}},
+				{"id": 4, "comments": []map[string]interface{}{
+					{"id": 1, "content": "", "commentType": "text", "isDeleted": true},
+				}},
+				{"id": 5, "isDeleted": true, "comments": []map[string]interface{}{}},
+			},
+			"count": 5,
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/org", Config{AccessToken: "system-token", RequestTimeout: 30 * time.Second})
+	pr := PullRequest{CollectionURL: "https://dev.azure.com/org", Project: "project", Repo: "repo", ID: 42}
+
+	threads, err := client.ListPRThreads(pr)
+	if err != nil {
+		t.Fatalf("ListPRThreads failed: %v", err)
+	}
+
+	if len(threads) != 1 {
+		t.Fatalf("Expected 1 thread, got %d: %+v", len(threads), threads)
+	}
+
+	if threads[0].ID != 1 || threads[0].Body != "diff" || threads[0].Author != "user-id" {
+		t.Errorf("Unexpected thread: %+v", threads[0])
+	}
+
+	expectedURL := "https://dev.azure.com/org/project/_git/repo/pullrequest/42?discussionId=1"
+	if threads[0].URL != expectedURL {
+		t.Errorf("Expected URL %q, got %q", expectedURL, threads[0].URL)
+	}
+}
+
+func TestUpdateAndDeletePRThread(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/org/project/_apis/git/repositories/repo/pullRequests/42/threads/7/comments/1" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		methods = append(methods, r.Method)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/org", Config{PAT: "test-pat", RequestTimeout: 30 * time.Second})
+	pr := PullRequest{CollectionURL: server.URL + "/org", Project: "project", Repo: "repo", ID: 42}
+
+	if err := client.UpdatePRThread(pr, 7, "Updated"); err != nil {
+		t.Errorf("UpdatePRThread failed: %v", err)
+	}
+
+	if err := client.DeletePRThread(pr, 7); err != nil {
+		t.Errorf("DeletePRThread failed: %v", err)
+	}
+
+	if len(methods) != 2 || methods[0] != "PATCH" || methods[1] != "DELETE" {
+		t.Errorf("Expected PATCH then DELETE, got %v", methods)
+	}
+}
+
+func TestGetAuthenticatedUserID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/org/_apis/connectionData" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"authenticatedUser": map[string]string{"id": "user-id"},
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/org", Config{PAT: "test-pat", RequestTimeout: 30 * time.Second})
+
+	id, err := client.GetAuthenticatedUserID()
+	if err != nil {
+		t.Fatalf("GetAuthenticatedUserID failed: %v", err)
+	}
+
+	if id != "user-id" {
+		t.Errorf("Expected ID 'user-id', got %q", id)
+	}
+}
+
+func TestValidatePRReference(t *testing.T) {
+	tests := []struct {
+		name          string
+		ref           string
+		collectionURL string
+		expected      PullRequest
+		shouldError   bool
+	}{
+		{
+			name:     "dev.azure.com URL",
+			ref:      "https://dev.azure.com/org/project/_git/repo/pullrequest/42",
+			expected: PullRequest{CollectionURL: "https://dev.azure.com/org", Project: "project", Repo: "repo", ID: 42},
+		},
+		{
+			name:     "dev.azure.com without scheme",
+			ref:      "dev.azure.com/org/project/_git/repo/pullrequest/42",
+			expected: PullRequest{CollectionURL: "https://dev.azure.com/org", Project: "project", Repo: "repo", ID: 42},
+		},
+		{
+			name:     "Project with spaces",
+			ref:      "https://dev.azure.com/org/My%20Project/_git/repo/pullrequest/42?_a=files",
+			expected: PullRequest{CollectionURL: "https://dev.azure.com/org", Project: "My Project", Repo: "repo", ID: 42},
+		},
+		{
+			name:     "visualstudio.com URL",
+			ref:      "https://org.visualstudio.com/project/_git/repo/pullrequest/42",
+			expected: PullRequest{CollectionURL: "https://org.visualstudio.com", Project: "project", Repo: "repo", ID: 42},
+		},
+		{
+			name:     "Azure DevOps Server URL",
+			ref:      "https://tfs.example.com/tfs/DefaultCollection/project/_git/repo/pullrequest/42",
+			expected: PullRequest{CollectionURL: "https://tfs.example.com/tfs/DefaultCollection", Project: "project", Repo: "repo", ID: 42},
+		},
+		{
+			name:          "Short format with collection URL",
+			ref:           "project/repo#42",
+			collectionURL: "https://dev.azure.com/org/",
+			expected:      PullRequest{CollectionURL: "https://dev.azure.com/org", Project: "project", Repo: "repo", ID: 42},
+		},
+		{
+			name:        "Short format without collection URL",
+			ref:         "project/repo#42",
+			shouldError: true,
+		},
+		{
+			name:        "URL without pull request",
+			ref:         "https://dev.azure.com/org/project/_git/repo",
+			shouldError: true,
+		},
+		{
+			name:        "Invalid PR number",
+			ref:         "https://dev.azure.com/org/project/_git/repo/pullrequest/abc",
+			shouldError: true,
+		},
+		{
+			name:        "Invalid format",
+			ref:         "invalid",
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr, err := ValidatePRReference(tt.ref, tt.collectionURL)
+
+			if tt.shouldError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+
+			if pr != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, pr)
+			}
+		})
+	}
+}
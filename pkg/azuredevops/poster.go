@@ -0,0 +1,43 @@
+package azuredevops
+
+import "github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
+
+// PRPoster manages the comment threads of a single Azure Repos pull request.
+// It implements comments.Poster and comments.AuthorLookup
+type PRPoster struct {
+	client *Client
+	pr     PullRequest
+}
+
+// NewPRPoster creates a poster for the given pull request
+func NewPRPoster(client *Client, pr PullRequest) *PRPoster {
+	return &PRPoster{
+		client: client,
+		pr:     pr,
+	}
+}
+
+// PostComment creates a new thread on the pull request
+func (p *PRPoster) PostComment(body string) (comments.Comment, error) {
+	return p.client.PostPRThread(p.pr, body)
+}
+
+// ListComments returns the general threads of the pull request
+func (p *PRPoster) ListComments() ([]comments.Comment, error) {
+	return p.client.ListPRThreads(p.pr)
+}
+
+// UpdateComment replaces the content of an existing thread
+func (p *PRPoster) UpdateComment(id int64, body string) error {
+	return p.client.UpdatePRThread(p.pr, id, body)
+}
+
+// DeleteComment deletes an existing thread
+func (p *PRPoster) DeleteComment(id int64) error {
+	return p.client.DeletePRThread(p.pr, id)
+}
+
+// AuthenticatedLogin returns the ID of the identity the token belongs to
+func (p *PRPoster) AuthenticatedLogin() (string, error) {
+	return p.client.GetAuthenticatedUserID()
+}
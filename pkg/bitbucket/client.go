@@ -0,0 +1,144 @@
+package bitbucket
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/rest"
+)
+
+// CloudAPIURL is the base URL of the Bitbucket Cloud REST API
+const CloudAPIURL = "https://api.bitbucket.org/2.0"
+
+// MaxCommentLength is the limit used for a single Bitbucket comment. Neither
+// flavour documents a hard limit, this keeps comments well within what both
+// accept and render
+const MaxCommentLength = 32768
+
+// Flavor identifies the Bitbucket product hosting a pull request
+type Flavor string
+
+const (
+	// Cloud is bitbucket.org
+	Cloud Flavor = "cloud"
+	// Server is Bitbucket Server / Data Center
+	Server Flavor = "server"
+)
+
+// Config holds configuration for Bitbucket clients
+type Config struct {
+	// Token is an access token sent as a bearer token, or the password (app
+	// password on Cloud) when Username is set
+	Token    string
+	Username string
+	// BaseURL is the URL of the Bitbucket Server instance. Unused for Cloud
+	BaseURL        string
+	MaxRetries     int
+	RetryDelay     time.Duration
+	BackoffFactor  float64
+	RequestTimeout time.Duration
+}
+
+// PullRequest identifies a Bitbucket pull request
+type PullRequest struct {
+	Flavor Flavor
+	// Owner is the workspace on Cloud or the project key on Server
+	Owner string
+	Repo  string
+	ID    int
+	// BaseURL is the Bitbucket Server URL found in a PR URL, if any
+	BaseURL string
+}
+
+// newRESTClient creates a REST client authenticating with the configured
+// credentials
+func newRESTClient(baseURL string, config Config) *rest.Client {
+	restConfig := rest.Config{
+		MaxRetries:     config.MaxRetries,
+		RetryDelay:     config.RetryDelay,
+		BackoffFactor:  config.BackoffFactor,
+		RequestTimeout: config.RequestTimeout,
+	}
+
+	return rest.NewClient(baseURL, restConfig, func(req *http.Request) {
+		if config.Username != "" {
+			req.SetBasicAuth(config.Username, config.Token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+config.Token)
+		}
+	})
+}
+
+// ValidatePRReference validates and parses a Bitbucket PR reference
+// Accepts formats: owner/repo#123, https://bitbucket.org/workspace/repo/pull-requests/123,
+// https://bitbucket.example.com/projects/PROJ/repos/repo/pull-requests/123
+// Short references are Cloud references unless serverURL is set
+func ValidatePRReference(ref, serverURL string) (PullRequest, error) {
+	// Handle URL formats
+	if strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://") {
+		u, err := url.Parse(ref)
+		if err != nil {
+			return PullRequest{}, fmt.Errorf("invalid Bitbucket PR URL: %w", err)
+		}
+
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+		// Cloud: /workspace/repo/pull-requests/123
+		if u.Host == "bitbucket.org" || u.Host == "www.bitbucket.org" {
+			if len(parts) >= 4 && parts[2] == "pull-requests" {
+				id, err := strconv.Atoi(parts[3])
+				if err != nil {
+					return PullRequest{}, fmt.Errorf("invalid PR number in URL: %s", parts[3])
+				}
+				return PullRequest{Flavor: Cloud, Owner: parts[0], Repo: parts[1], ID: id}, nil
+			}
+			return PullRequest{}, fmt.Errorf("invalid Bitbucket Cloud PR URL format")
+		}
+
+		// Server: [/context]/projects/PROJ/repos/repo/pull-requests/123[/overview]
+		for i := 0; i+5 < len(parts); i++ {
+			if parts[i] == "projects" && parts[i+2] == "repos" && parts[i+4] == "pull-requests" {
+				id, err := strconv.Atoi(parts[i+5])
+				if err != nil {
+					return PullRequest{}, fmt.Errorf("invalid PR number in URL: %s", parts[i+5])
+				}
+				baseURL := u.Scheme + "://" + u.Host
+				if i > 0 {
+					baseURL += "/" + strings.Join(parts[:i], "/")
+				}
+				return PullRequest{Flavor: Server, Owner: parts[i+1], Repo: parts[i+3], ID: id, BaseURL: baseURL}, nil
+			}
+		}
+		return PullRequest{}, fmt.Errorf("invalid Bitbucket Server PR URL format")
+	}
+
+	// Handle short format: owner/repo#123
+	if strings.Contains(ref, "#") {
+		parts := strings.Split(ref, "#")
+		if len(parts) != 2 {
+			return PullRequest{}, fmt.Errorf("invalid PR reference format, expected owner/repo#123")
+		}
+
+		repoParts := strings.Split(parts[0], "/")
+		if len(repoParts) != 2 || repoParts[0] == "" || repoParts[1] == "" {
+			return PullRequest{}, fmt.Errorf("invalid repository format, expected owner/repo")
+		}
+
+		id, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return PullRequest{}, fmt.Errorf("invalid PR number: %s", parts[1])
+		}
+
+		flavor := Cloud
+		if serverURL != "" {
+			flavor = Server
+		}
+		return PullRequest{Flavor: flavor, Owner: repoParts[0], Repo: repoParts[1], ID: id}, nil
+	}
+
+	return PullRequest{}, fmt.Errorf("invalid PR reference format, expected owner/repo#123 or a Bitbucket PR URL")
+}
@@ -0,0 +1,102 @@
+package bitbucket
+
+import (
+	"testing"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+)
+
+func init() {
+	// Initialize logger for tests
+	logger.Initialize(logger.ErrorLevel)
+}
+
+func TestValidatePRReference(t *testing.T) {
+	tests := []struct {
+		name        string
+		ref         string
+		serverURL   string
+		expected    PullRequest
+		shouldError bool
+	}{
+		{
+			name:     "Cloud short format",
+			ref:      "workspace/repo#12",
+			expected: PullRequest{Flavor: Cloud, Owner: "workspace", Repo: "repo", ID: 12},
+		},
+		{
+			name:      "Server short format with server URL",
+			ref:       "PROJ/repo#12",
+			serverURL: "https://bitbucket.example.com",
+			expected:  PullRequest{Flavor: Server, Owner: "PROJ", Repo: "repo", ID: 12},
+		},
+		{
+			name:     "Cloud URL",
+			ref:      "https://bitbucket.org/workspace/repo/pull-requests/34",
+			expected: PullRequest{Flavor: Cloud, Owner: "workspace", Repo: "repo", ID: 34},
+		},
+		{
+			name:     "Cloud URL with trailing path",
+			ref:      "https://bitbucket.org/workspace/repo/pull-requests/34/diff",
+			expected: PullRequest{Flavor: Cloud, Owner: "workspace", Repo: "repo", ID: 34},
+		},
+		{
+			name: "Server URL",
+			ref:  "https://bitbucket.example.com/projects/PROJ/repos/repo/pull-requests/56/overview",
+			expected: PullRequest{
+				Flavor: Server, Owner: "PROJ", Repo: "repo", ID: 56,
+				BaseURL: "https://bitbucket.example.com",
+			},
+		},
+		{
+			name: "Server URL with context path",
+			ref:  "https://git.example.com/bitbucket/projects/PROJ/repos/repo/pull-requests/7",
+			expected: PullRequest{
+				Flavor: Server, Owner: "PROJ", Repo: "repo", ID: 7,
+				BaseURL: "https://git.example.com/bitbucket",
+			},
+		},
+		{
+			name:        "Invalid Cloud URL",
+			ref:         "https://bitbucket.org/workspace/repo",
+			shouldError: true,
+		},
+		{
+			name:        "Invalid Server URL",
+			ref:         "https://bitbucket.example.com/projects/PROJ/repos/repo/browse",
+			shouldError: true,
+		},
+		{
+			name:        "Invalid PR number",
+			ref:         "workspace/repo#abc",
+			shouldError: true,
+		},
+		{
+			name:        "Invalid format",
+			ref:         "invalid",
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr, err := ValidatePRReference(tt.ref, tt.serverURL)
+
+			if tt.shouldError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+
+			if pr != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, pr)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package bitbucket
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/rest"
+)
+
+// CloudClient represents a Bitbucket Cloud API client
+type CloudClient struct {
+	rest *rest.Client
+}
+
+// cloudComment is the subset of the Bitbucket Cloud comment resource used by
+// this client
+type cloudComment struct {
+	ID      int64 `json:"id"`
+	Deleted bool  `json:"deleted"`
+	Content struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+	User struct {
+		UUID string `json:"uuid"`
+	} `json:"user"`
+	Inline interface{} `json:"inline"`
+	Links  struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+// toComment converts a comment returned by the API
+func (c cloudComment) toComment() comments.Comment {
+	return comments.Comment{
+		ID:     c.ID,
+		Body:   c.Content.Raw,
+		Author: c.User.UUID,
+		URL:    c.Links.HTML.Href,
+	}
+}
+
+// cloudContent is the request body for creating and updating comments
+type cloudContent struct {
+	Content struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+}
+
+func newCloudContent(body string) cloudContent {
+	var c cloudContent
+	c.Content.Raw = body
+	return c
+}
+
+// NewCloudClient creates a new Bitbucket Cloud client
+func NewCloudClient(config Config) *CloudClient {
+	return &CloudClient{rest: newRESTClient(CloudAPIURL, config)}
+}
+
+// commentsPath returns the API path of the comments of a pull request
+func cloudCommentsPath(workspace, repo string, prID int) string {
+	return fmt.Sprintf("/repositories/%s/%s/pullrequests/%d/comments", url.PathEscape(workspace), url.PathEscape(repo), prID)
+}
+
+// PostPRComment posts a comment to a Bitbucket Cloud PR and returns the
+// created comment
+func (c *CloudClient) PostPRComment(workspace, repo string, prID int, body string) (comments.Comment, error) {
+	log := logger.GetLogger()
+
+	var created cloudComment
+	if _, err := c.rest.Do("POST", cloudCommentsPath(workspace, repo, prID), newCloudContent(body), &created); err != nil {
+		return comments.Comment{}, fmt.Errorf("failed to post comment: %w", err)
+	}
+
+	log.Infof("Successfully posted comment to PR #%d", prID)
+	return created.toComment(), nil
+}
+
+// ListPRComments returns the general (non-inline) comments of a Bitbucket
+// Cloud PR, oldest first
+func (c *CloudClient) ListPRComments(workspace, repo string, prID int) ([]comments.Comment, error) {
+	var result []comments.Comment
+
+	next := cloudCommentsPath(workspace, repo, prID) + "?pagelen=100&sort=created_on"
+	for next != "" {
+		var page struct {
+			Values []cloudComment `json:"values"`
+			Next   string         `json:"next"`
+		}
+		if _, err := c.rest.Do("GET", next, nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to list comments: %w", err)
+		}
+
+		for _, comment := range page.Values {
+			if comment.Deleted || comment.Inline != nil {
+				continue
+			}
+			result = append(result, comment.toComment())
+		}
+
+		next = page.Next
+	}
+
+	return result, nil
+}
+
+// UpdatePRComment replaces the body of an existing Bitbucket Cloud PR comment
+func (c *CloudClient) UpdatePRComment(workspace, repo string, prID int, commentID int64, body string) error {
+	log := logger.GetLogger()
+
+	path := fmt.Sprintf("%s/%d", cloudCommentsPath(workspace, repo, prID), commentID)
+	if _, err := c.rest.Do("PUT", path, newCloudContent(body), nil); err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	log.Infof("Successfully updated comment %d", commentID)
+	return nil
+}
+
+// DeletePRComment deletes an existing Bitbucket Cloud PR comment
+func (c *CloudClient) DeletePRComment(workspace, repo string, prID int, commentID int64) error {
+	log := logger.GetLogger()
+
+	path := fmt.Sprintf("%s/%d", cloudCommentsPath(workspace, repo, prID), commentID)
+	if _, err := c.rest.Do("DELETE", path, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+
+	log.Infof("Successfully deleted comment %d", commentID)
+	return nil
+}
+
+// GetAuthenticatedUUID returns the UUID of the user the credentials belong to
+func (c *CloudClient) GetAuthenticatedUUID() (string, error) {
+	var user struct {
+		UUID string `json:"uuid"`
+	}
+	if _, err := c.rest.Do("GET", "/user", nil, &user); err != nil {
+		return "", fmt.Errorf("failed to get authenticated user: %w", err)
+	}
+	return user.UUID, nil
+}
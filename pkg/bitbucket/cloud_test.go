@@ -0,0 +1,127 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestCloudClient returns a Cloud client whose API calls go to the given test server
+func newTestCloudClient(serverURL string, config Config) *CloudClient {
+	return &CloudClient{rest: newRESTClient(serverURL, config)}
+}
+
+func TestCloudPostPRComment_BasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Expected POST request, got %s", r.Method)
+		}
+
+		if r.URL.Path != "/repositories/workspace/repo/pullrequests/12/comments" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "app-password" {
+			t.Errorf("Expected basic auth user:app-password, got %s:%s", username, password)
+		}
+
+		var body cloudContent
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Content.Raw != "Test comment" {
+			t.Errorf("Expected raw content 'Test comment', got %q", body.Content.Raw)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":    1,
+			"links": map[string]interface{}{"html": map[string]string{"href": "https://bitbucket.org/workspace/repo/pull-requests/12#comment-1"}},
+		})
+	}))
+	defer server.Close()
+
+	client := newTestCloudClient(server.URL, Config{Token: "app-password", Username: "user", RequestTimeout: 30 * time.Second})
+
+	comment, err := client.PostPRComment("workspace", "repo", 12, "Test comment")
+	if err != nil {
+		t.Fatalf("PostPRComment failed: %v", err)
+	}
+
+	if comment.ID != 1 || comment.URL != "https://bitbucket.org/workspace/repo/pull-requests/12#comment-1" {
+		t.Errorf("Unexpected created comment: %+v", comment)
+	}
+}
+
+func TestCloudListPRComments_Pagination(t *testing.T) {
+	var serverURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("Expected bearer token, got %q", r.Header.Get("Authorization"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Query().Get("page") == "" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"values": []map[string]interface{}{
+					{"id": 1, "content": map[string]string{"raw": "first"}, "user": map[string]string{"uuid": "{bot}"}},
+					{"id": 2, "content": map[string]string{"raw": "inline"}, "inline": map[string]interface{}{"path": "a.yaml"}},
+				},
+				"next": serverURL + "/repositories/workspace/repo/pullrequests/12/comments?page=2",
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"values": []map[string]interface{}{
+				{"id": 3, "deleted": true, "content": map[string]string{"raw": ""}},
+				{"id": 4, "content": map[string]string{"raw": "second"}, "user": map[string]string{"uuid": "{reviewer}"}},
+			},
+		})
+	}))
+	defer server.Close()
+	serverURL = server.URL
+
+	client := newTestCloudClient(server.URL, Config{Token: "test-token", RequestTimeout: 30 * time.Second})
+
+	comments, err := client.ListPRComments("workspace", "repo", 12)
+	if err != nil {
+		t.Fatalf("ListPRComments failed: %v", err)
+	}
+
+	if len(comments) != 2 {
+		t.Fatalf("Expected 2 comments (inline and deleted skipped), got %d", len(comments))
+	}
+
+	if comments[0].ID != 1 || comments[0].Author != "{bot}" || comments[1].ID != 4 {
+		t.Errorf("Unexpected comments: %+v", comments)
+	}
+}
+
+func TestCloudUpdateAndDeletePRComment(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repositories/workspace/repo/pullrequests/12/comments/42" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		methods = append(methods, r.Method)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newTestCloudClient(server.URL, Config{Token: "test-token", RequestTimeout: 30 * time.Second})
+
+	if err := client.UpdatePRComment("workspace", "repo", 12, 42, "Updated"); err != nil {
+		t.Errorf("UpdatePRComment failed: %v", err)
+	}
+
+	if err := client.DeletePRComment("workspace", "repo", 12, 42); err != nil {
+		t.Errorf("DeletePRComment failed: %v", err)
+	}
+
+	if len(methods) != 2 || methods[0] != "PUT" || methods[1] != "DELETE" {
+		t.Errorf("Expected PUT then DELETE, got %v", methods)
+	}
+}
@@ -0,0 +1,62 @@
+package bitbucket
+
+import (
+	"strings"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/diffparser"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/splitter"
+)
+
+// FormatGrowth is the most FormatMarkdown makes a comment longer, in bytes
+// or characters: the markdown part marker is one longer than the HTML one and
+// may need a blank line before it. Splitting must keep that much room free
+const FormatGrowth = 2
+
+// FormatMarkdown adapts argocd-diff-preview markdown to Bitbucket, which
+// renders neither HTML nor <details>/<summary> blocks. Each collapsible
+// application section becomes a heading followed by its diff, and the hidden
+// HTML part marker is rewritten in its markdown-only form. The result is at
+// most FormatGrowth longer than content
+func FormatMarkdown(content string) string {
+	lines := strings.Split(content, "\n")
+	formatted := make([]string, 0, len(lines))
+
+	// Never rewrite code blocks, even if a manifest contains HTML. Like the
+	// diff parser, only a bare fence closes a block, since diff lines always
+	// start with a prefix character
+	inFence := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if inFence {
+			inFence = !diffparser.IsClosingFence(line)
+			formatted = append(formatted, line)
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = true
+			formatted = append(formatted, line)
+			continue
+		}
+
+		switch {
+		case trimmed == "<details>" || trimmed == "</details>" || trimmed == "<br>":
+			continue
+		case strings.HasPrefix(trimmed, "<summary>") && strings.HasSuffix(trimmed, "</summary>"):
+			title := strings.TrimSuffix(strings.TrimPrefix(trimmed, "<summary>"), "</summary>")
+			formatted = append(formatted, "#### "+strings.TrimSpace(title))
+		default:
+			if partNumber, ok := splitter.ParsePartMarker(line); ok && trimmed == splitter.PartMarker(partNumber) {
+				// A link reference definition can't interrupt a paragraph
+				if len(formatted) > 0 && formatted[len(formatted)-1] != "" {
+					formatted = append(formatted, "")
+				}
+				formatted = append(formatted, splitter.MarkdownPartMarker(partNumber))
+				continue
+			}
+			formatted = append(formatted, line)
+		}
+	}
+
+	return strings.Join(formatted, "\n")
+}
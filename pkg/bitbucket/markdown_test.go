@@ -0,0 +1,95 @@
+package bitbucket
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/splitter"
+)
+
+func TestFormatMarkdown(t *testing.T) {
+	input := "## Argo CD Diff Preview\n\n" +
+		"<details>\n" +
+		"<summary>app-name (path/to/app)</summary>\n" +
+		"<br>\n\n" +
+		"```diff\n" +
+		"+<details>\n" +
+		"+data: value\n" +
+		"```\n\n" +
+		"</details>\n\n" +
+		"---\n**Part 1 of 2**\n" +
+		splitter.PartMarker(1)
+
+	expected := "## Argo CD Diff Preview\n\n" +
+		"#### app-name (path/to/app)\n\n" +
+		"```diff\n" +
+		"+<details>\n" +
+		"+data: value\n" +
+		"```\n\n\n" +
+		"---\n**Part 1 of 2**\n\n" +
+		splitter.MarkdownPartMarker(1)
+
+	formatted := FormatMarkdown(input)
+	if formatted != expected {
+		t.Errorf("Unexpected formatted markdown:\n%q\nexpected:\n%q", formatted, expected)
+	}
+
+	if partNumber, ok := splitter.ParsePartMarker(formatted); !ok || partNumber != 1 {
+		t.Errorf("Formatted markdown should keep a parsable part marker")
+	}
+
+	if len(formatted) > len(input) {
+		t.Errorf("Formatted markdown (%d bytes) should not be longer than the input (%d bytes)", len(formatted), len(input))
+	}
+
+	if strings.Contains(formatted, "<summary>") {
+		t.Error("Formatted markdown should not contain <summary> tags")
+	}
+}
+
+func TestFormatMarkdown_IndentedFence(t *testing.T) {
+	input := "<details>\n" +
+		"<summary>docs-app</summary>\n" +
+		"<br>\n\n" +
+		"```diff\n" +
+		"   README.md: |\n" +
+		"     ```\n" +
+		"+    <details>\n" +
+		"+    <summary>kept (as is)</summary>\n" +
+		"     ```\n" +
+		"```\n\n" +
+		"</details>\n"
+
+	expected := "#### docs-app\n\n" +
+		"```diff\n" +
+		"   README.md: |\n" +
+		"     ```\n" +
+		"+    <details>\n" +
+		"+    <summary>kept (as is)</summary>\n" +
+		"     ```\n" +
+		"```\n\n"
+
+	if formatted := FormatMarkdown(input); formatted != expected {
+		t.Errorf("Unexpected formatted markdown:\n%q\nexpected:\n%q", formatted, expected)
+	}
+}
+
+func TestFormatMarkdown_Growth(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "Marker after a paragraph", input: "Summary only\n" + splitter.PartMarker(12)},
+		{name: "Marker after a blank line", input: "Summary only\n\n" + splitter.PartMarker(3)},
+		{name: "Footer", input: "---\n**Part 9 of 10**\n" + splitter.PartMarker(9)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted := FormatMarkdown(tt.input)
+			if len(formatted) > len(tt.input)+FormatGrowth {
+				t.Errorf("Formatted markdown (%d bytes) grew by more than %d bytes (input %d bytes)", len(formatted), FormatGrowth, len(tt.input))
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package bitbucket
+
+import "github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
+
+// CloudPRPoster manages the comments of a single Bitbucket Cloud PR. It
+// implements comments.Poster, comments.Formatter and comments.AuthorLookup
+type CloudPRPoster struct {
+	client *CloudClient
+	pr     PullRequest
+}
+
+// NewCloudPRPoster creates a poster for the given Bitbucket Cloud PR
+func NewCloudPRPoster(client *CloudClient, pr PullRequest) *CloudPRPoster {
+	return &CloudPRPoster{client: client, pr: pr}
+}
+
+// PostComment creates a new comment on the PR
+func (p *CloudPRPoster) PostComment(body string) (comments.Comment, error) {
+	return p.client.PostPRComment(p.pr.Owner, p.pr.Repo, p.pr.ID, body)
+}
+
+// ListComments returns all general comments on the PR
+func (p *CloudPRPoster) ListComments() ([]comments.Comment, error) {
+	return p.client.ListPRComments(p.pr.Owner, p.pr.Repo, p.pr.ID)
+}
+
+// UpdateComment replaces the body of an existing PR comment
+func (p *CloudPRPoster) UpdateComment(id int64, body string) error {
+	return p.client.UpdatePRComment(p.pr.Owner, p.pr.Repo, p.pr.ID, id, body)
+}
+
+// DeleteComment deletes an existing PR comment
+func (p *CloudPRPoster) DeleteComment(id int64) error {
+	return p.client.DeletePRComment(p.pr.Owner, p.pr.Repo, p.pr.ID, id)
+}
+
+// FormatComment adapts the comment markdown to Bitbucket
+func (p *CloudPRPoster) FormatComment(body string) string {
+	return FormatMarkdown(body)
+}
+
+// AuthenticatedLogin returns the UUID of the authenticated user, matching
+// the author of comments returned by ListComments
+func (p *CloudPRPoster) AuthenticatedLogin() (string, error) {
+	return p.client.GetAuthenticatedUUID()
+}
+
+// ServerPRPoster manages the comments of a single Bitbucket Server PR. It
+// implements comments.Poster and comments.Formatter
+type ServerPRPoster struct {
+	client *ServerClient
+	pr     PullRequest
+}
+
+// NewServerPRPoster creates a poster for the given Bitbucket Server PR
+func NewServerPRPoster(client *ServerClient, pr PullRequest) *ServerPRPoster {
+	return &ServerPRPoster{client: client, pr: pr}
+}
+
+// PostComment creates a new comment on the PR
+func (p *ServerPRPoster) PostComment(body string) (comments.Comment, error) {
+	return p.client.PostPRComment(p.pr.Owner, p.pr.Repo, p.pr.ID, body)
+}
+
+// ListComments returns all general comments on the PR
+func (p *ServerPRPoster) ListComments() ([]comments.Comment, error) {
+	return p.client.ListPRComments(p.pr.Owner, p.pr.Repo, p.pr.ID)
+}
+
+// UpdateComment replaces the body of an existing PR comment
+func (p *ServerPRPoster) UpdateComment(id int64, body string) error {
+	return p.client.UpdatePRComment(p.pr.Owner, p.pr.Repo, p.pr.ID, id, body)
+}
+
+// DeleteComment deletes an existing PR comment
+func (p *ServerPRPoster) DeleteComment(id int64) error {
+	return p.client.DeletePRComment(p.pr.Owner, p.pr.Repo, p.pr.ID, id)
+}
+
+// FormatComment adapts the comment markdown to Bitbucket
+func (p *ServerPRPoster) FormatComment(body string) string {
+	return FormatMarkdown(body)
+}
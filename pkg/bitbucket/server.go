@@ -0,0 +1,166 @@
+package bitbucket
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/rest"
+)
+
+// ServerClient represents a Bitbucket Server / Data Center API client
+type ServerClient struct {
+	rest    *rest.Client
+	baseURL string
+}
+
+// serverComment is the subset of the Bitbucket Server comment resource used
+// by this client
+type serverComment struct {
+	ID      int64  `json:"id"`
+	Version int    `json:"version"`
+	Text    string `json:"text"`
+	Author  struct {
+		Slug string `json:"slug"`
+	} `json:"author"`
+}
+
+// NewServerClient creates a new Bitbucket Server client
+func NewServerClient(config Config) *ServerClient {
+	baseURL := strings.TrimSuffix(config.BaseURL, "/")
+	return &ServerClient{
+		rest:    newRESTClient(baseURL+"/rest/api/1.0", config),
+		baseURL: baseURL,
+	}
+}
+
+// pullRequestPath returns the API path of a pull request
+func serverPullRequestPath(project, repo string, prID int) string {
+	return fmt.Sprintf("/projects/%s/repos/%s/pull-requests/%d", url.PathEscape(project), url.PathEscape(repo), prID)
+}
+
+// PostPRComment posts a comment to a Bitbucket Server PR and returns the
+// created comment
+func (c *ServerClient) PostPRComment(project, repo string, prID int, body string) (comments.Comment, error) {
+	log := logger.GetLogger()
+
+	var created serverComment
+	path := serverPullRequestPath(project, repo, prID) + "/comments"
+	if _, err := c.rest.Do("POST", path, map[string]string{"text": body}, &created); err != nil {
+		return comments.Comment{}, fmt.Errorf("failed to post comment: %w", err)
+	}
+
+	log.Infof("Successfully posted comment to PR #%d", prID)
+	return c.toComment(project, repo, prID, created), nil
+}
+
+// ListPRComments returns the general (non-inline) comments of a Bitbucket
+// Server PR, oldest first. Server has no endpoint listing general comments,
+// so they are read from the PR activity stream
+func (c *ServerClient) ListPRComments(project, repo string, prID int) ([]comments.Comment, error) {
+	var result []comments.Comment
+	seen := make(map[int64]bool)
+
+	start := 0
+	for {
+		var page struct {
+			Values []struct {
+				Action        string         `json:"action"`
+				CommentAction string         `json:"commentAction"`
+				Comment       *serverComment `json:"comment"`
+				CommentAnchor interface{}    `json:"commentAnchor"`
+			} `json:"values"`
+			IsLastPage    bool `json:"isLastPage"`
+			NextPageStart int  `json:"nextPageStart"`
+		}
+
+		path := fmt.Sprintf("%s/activities?limit=100&start=%d", serverPullRequestPath(project, repo, prID), start)
+		if _, err := c.rest.Do("GET", path, nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to list comments: %w", err)
+		}
+
+		for _, activity := range page.Values {
+			if activity.Action != "COMMENTED" || activity.Comment == nil || activity.CommentAnchor != nil {
+				continue
+			}
+			if seen[activity.Comment.ID] {
+				continue
+			}
+			seen[activity.Comment.ID] = true
+
+			result = append(result, c.toComment(project, repo, prID, *activity.Comment))
+		}
+
+		if page.IsLastPage {
+			break
+		}
+		start = page.NextPageStart
+	}
+
+	// Activities are returned newest first
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return result, nil
+}
+
+// getComment returns a comment, including the version needed to modify it
+func (c *ServerClient) getComment(project, repo string, prID int, commentID int64) (*serverComment, error) {
+	var comment serverComment
+	path := fmt.Sprintf("%s/comments/%d", serverPullRequestPath(project, repo, prID), commentID)
+	if _, err := c.rest.Do("GET", path, nil, &comment); err != nil {
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+	return &comment, nil
+}
+
+// toComment converts a comment returned by the API
+func (c *ServerClient) toComment(project, repo string, prID int, comment serverComment) comments.Comment {
+	return comments.Comment{
+		ID:     comment.ID,
+		Body:   comment.Text,
+		Author: comment.Author.Slug,
+		URL: fmt.Sprintf("%s/projects/%s/repos/%s/pull-requests/%d/overview?commentId=%d",
+			c.baseURL, project, repo, prID, comment.ID),
+	}
+}
+
+// UpdatePRComment replaces the body of an existing Bitbucket Server PR comment
+func (c *ServerClient) UpdatePRComment(project, repo string, prID int, commentID int64, body string) error {
+	log := logger.GetLogger()
+
+	comment, err := c.getComment(project, repo, prID, commentID)
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("%s/comments/%d", serverPullRequestPath(project, repo, prID), commentID)
+	request := map[string]interface{}{"text": body, "version": comment.Version}
+	if _, err := c.rest.Do("PUT", path, request, nil); err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	log.Infof("Successfully updated comment %d", commentID)
+	return nil
+}
+
+// DeletePRComment deletes an existing Bitbucket Server PR comment
+func (c *ServerClient) DeletePRComment(project, repo string, prID int, commentID int64) error {
+	log := logger.GetLogger()
+
+	comment, err := c.getComment(project, repo, prID, commentID)
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("%s/comments/%d?version=%d", serverPullRequestPath(project, repo, prID), commentID, comment.Version)
+	if _, err := c.rest.Do("DELETE", path, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+
+	log.Infof("Successfully deleted comment %d", commentID)
+	return nil
+}
@@ -0,0 +1,120 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServerPostPRComment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/rest/api/1.0/projects/PROJ/repos/repo/pull-requests/12/comments" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 5, "text": "Test comment", "author": map[string]string{"slug": "bot"}})
+	}))
+	defer server.Close()
+
+	client := NewServerClient(Config{Token: "test-token", BaseURL: server.URL, RequestTimeout: 30 * time.Second})
+
+	comment, err := client.PostPRComment("PROJ", "repo", 12, "Test comment")
+	if err != nil {
+		t.Fatalf("PostPRComment failed: %v", err)
+	}
+
+	expectedURL := server.URL + "/projects/PROJ/repos/repo/pull-requests/12/overview?commentId=5"
+	if comment.ID != 5 || comment.URL != expectedURL {
+		t.Errorf("Expected comment 5 at %s, got %d at %s", expectedURL, comment.ID, comment.URL)
+	}
+}
+
+func TestServerListPRComments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/1.0/projects/PROJ/repos/repo/pull-requests/12/activities" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		// Activities are returned newest first
+		if r.URL.Query().Get("start") == "0" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"values": []map[string]interface{}{
+					{"action": "COMMENTED", "commentAction": "ADDED", "comment": map[string]interface{}{"id": 3, "text": "newest", "author": map[string]string{"slug": "bot"}}},
+					{"action": "APPROVED"},
+					{"action": "COMMENTED", "commentAction": "ADDED", "comment": map[string]interface{}{"id": 2, "text": "inline"}, "commentAnchor": map[string]string{"path": "a.yaml"}},
+				},
+				"isLastPage":    false,
+				"nextPageStart": 3,
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"values": []map[string]interface{}{
+				{"action": "COMMENTED", "commentAction": "ADDED", "comment": map[string]interface{}{"id": 1, "text": "oldest", "author": map[string]string{"slug": "bot"}}},
+			},
+			"isLastPage": true,
+		})
+	}))
+	defer server.Close()
+
+	client := NewServerClient(Config{Token: "test-token", BaseURL: server.URL, RequestTimeout: 30 * time.Second})
+
+	comments, err := client.ListPRComments("PROJ", "repo", 12)
+	if err != nil {
+		t.Fatalf("ListPRComments failed: %v", err)
+	}
+
+	if len(comments) != 2 {
+		t.Fatalf("Expected 2 comments, got %d", len(comments))
+	}
+
+	if comments[0].ID != 1 || comments[1].ID != 3 {
+		t.Errorf("Expected comments oldest first (1, 3), got %d, %d", comments[0].ID, comments[1].ID)
+	}
+
+	if comments[0].Author != "bot" {
+		t.Errorf("Expected author 'bot', got %q", comments[0].Author)
+	}
+}
+
+func TestServerUpdatePRComment_UsesVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/1.0/projects/PROJ/repos/repo/pull-requests/12/comments/42" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+
+		switch r.Method {
+		case "GET":
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 42, "version": 3, "text": "old"})
+		case "PUT":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["version"] != float64(3) || body["text"] != "Updated" {
+				t.Errorf("Unexpected update body: %v", body)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 42, "version": 4})
+		case "DELETE":
+			if r.URL.Query().Get("version") != "3" {
+				t.Errorf("Expected version=3 on delete, got %q", r.URL.Query().Get("version"))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	client := NewServerClient(Config{Token: "test-token", BaseURL: server.URL, RequestTimeout: 30 * time.Second})
+
+	if err := client.UpdatePRComment("PROJ", "repo", 12, 42, "Updated"); err != nil {
+		t.Errorf("UpdatePRComment failed: %v", err)
+	}
+
+	if err := client.DeletePRComment("PROJ", "repo", 12, 42); err != nil {
+		t.Errorf("DeletePRComment failed: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package comments
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Comment represents an existing comment on a pull or merge request
+type Comment struct {
+	ID int64
+	// NodeID is the GraphQL node ID of the comment, only set by backends
+	// that have one (GitHub)
+	NodeID string
+	Body   string
+	Author string
+	URL    string
+}
+
+// Poster posts and manages the comments of a single pull or merge request.
+// Implementations are bound to their target when they are created
+type Poster interface {
+	// PostComment creates a new comment and returns it, including its ID
+	// and URL
+	PostComment(body string) (Comment, error)
+	// ListComments returns all existing comments, oldest first
+	ListComments() ([]Comment, error)
+	// UpdateComment replaces the body of an existing comment
+	UpdateComment(id int64, body string) error
+	// DeleteComment deletes an existing comment
+	DeleteComment(id int64) error
+}
+
+// Minimizer is implemented by posters that can hide comments without
+// deleting them
+type Minimizer interface {
+	// MinimizeComments hides the given comments as outdated and returns how
+	// many were minimized
+	MinimizeComments(comments []Comment) (int, error)
+}
+
+// Formatter is implemented by posters whose platform renders markdown
+// differently. Comments are formatted before they are compared and posted, so
+// whatever formatting can add must be kept free when splitting them
+type Formatter interface {
+	// FormatComment adapts a comment body to the platform
+	FormatComment(body string) string
+}
+
+// AuthorLookup is implemented by posters that can tell which user their
+// credentials belong to, so only comments posted by that user are managed
+type AuthorLookup interface {
+	// AuthenticatedLogin returns the login of the authenticated user
+	AuthenticatedLogin() (string, error)
+}
+
+// Strategy selects what happens to comments posted by previous runs
+type Strategy string
+
+const (
+	// StrategyUpdate edits previous parts in place, creates extra parts and
+	// deletes parts no longer needed
+	StrategyUpdate Strategy = "update"
+	// StrategyMinimize hides previous comments as outdated, then posts a fresh set
+	StrategyMinimize Strategy = "minimize"
+	// StrategyDelete deletes previous comments, then posts a fresh set
+	StrategyDelete Strategy = "delete"
+	// StrategyAppend leaves previous comments alone and posts a fresh set
+	StrategyAppend Strategy = "append"
+)
+
+// ValidStrategies returns all valid strategies
+func ValidStrategies() []string {
+	return []string{
+		string(StrategyUpdate),
+		string(StrategyMinimize),
+		string(StrategyDelete),
+		string(StrategyAppend),
+	}
+}
+
+// ParseStrategy parses a string into a Strategy
+func ParseStrategy(strategy string) (Strategy, error) {
+	switch strings.ToLower(strategy) {
+	case string(StrategyUpdate):
+		return StrategyUpdate, nil
+	case string(StrategyMinimize):
+		return StrategyMinimize, nil
+	case string(StrategyDelete):
+		return StrategyDelete, nil
+	case string(StrategyAppend):
+		return StrategyAppend, nil
+	default:
+		return StrategyUpdate, fmt.Errorf("invalid strategy: %s (valid strategies: %s)",
+			strategy, strings.Join(ValidStrategies(), ", "))
+	}
+}
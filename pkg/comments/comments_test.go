@@ -0,0 +1,19 @@
+package comments
+
+import "testing"
+
+func TestParseStrategy(t *testing.T) {
+	for _, valid := range ValidStrategies() {
+		strategy, err := ParseStrategy(valid)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", valid, err)
+		}
+		if string(strategy) != valid {
+			t.Errorf("Expected %q, got %q", valid, strategy)
+		}
+	}
+
+	if _, err := ParseStrategy("replace"); err == nil {
+		t.Error("Expected error for invalid strategy but got none")
+	}
+}
@@ -0,0 +1,333 @@
+package comments
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/splitter"
+)
+
+// postDelay is the pause between consecutive comments to avoid rapid requests
+var postDelay = 500 * time.Millisecond
+
+// Action is what was done with the comment of a part
+type Action string
+
+// Actions taken, or planned in dry-run mode
+const (
+	ActionCreated   Action = "created"
+	ActionUpdated   Action = "updated"
+	ActionUnchanged Action = "unchanged"
+	// ActionCreate and ActionCreateOrUpdate are planned in dry-run mode
+	ActionCreate         Action = "create"
+	ActionCreateOrUpdate Action = "create-or-update"
+)
+
+// PartOutcome is what happened to the comment of a part
+type PartOutcome struct {
+	PartNumber int
+	Action     Action
+	// Comment is the comment holding the part, empty in dry-run mode
+	Comment Comment
+}
+
+// Outcome is what Sync did
+type Outcome struct {
+	// Parts holds the outcome of every part handled, in order
+	Parts []PartOutcome
+	// Deleted are the IDs of the previous comments deleted
+	Deleted []int64
+	// Minimized is the number of previous comments hidden as outdated
+	Minimized int
+}
+
+// Sync posts the split results through the poster, handling comments from
+// previous runs according to the strategy. Once every part has a comment,
+// the parts are linked to each other. In dry-run mode nothing is sent and
+// the planned actions are logged instead. Returns what was done, up to the
+// failure if there was one
+func Sync(poster Poster, results []splitter.SplitResult, strategy Strategy, dryRun bool) (*Outcome, error) {
+	log := logger.GetLogger()
+	outcome := &Outcome{}
+
+	minimizer, canMinimize := poster.(Minimizer)
+	if strategy == StrategyMinimize && !canMinimize {
+		return outcome, fmt.Errorf("strategy %s is not supported by this backend", strategy)
+	}
+
+	var previous []Comment
+	if strategy != StrategyAppend {
+		// Dry-run makes no API calls, so existing comments can't be inspected
+		if dryRun {
+			log.Infof("[DRY RUN] Would look up previous diff comments (strategy: %s)", strategy)
+		} else {
+			var err error
+			previous, err = FindPrevious(poster)
+			if err != nil {
+				return outcome, err
+			}
+			log.Infof("Found %d previous diff comment(s)", len(previous))
+		}
+	}
+
+	switch strategy {
+	case StrategyUpdate:
+		posted, err := updateComments(poster, results, previous, outcome, dryRun)
+		if err != nil {
+			return outcome, err
+		}
+		return outcome, linkComments(poster, results, posted, outcome, dryRun)
+	case StrategyMinimize:
+		if len(previous) > 0 {
+			count, err := minimizer.MinimizeComments(previous)
+			if err != nil {
+				return outcome, fmt.Errorf("failed to minimize previous comments: %w", err)
+			}
+			outcome.Minimized = count
+			log.Infof("Minimized %d previous comment(s) as outdated", count)
+		}
+	case StrategyDelete:
+		for _, comment := range previous {
+			if err := poster.DeleteComment(comment.ID); err != nil {
+				return outcome, fmt.Errorf("failed to delete previous comment %d: %w", comment.ID, err)
+			}
+			outcome.Deleted = append(outcome.Deleted, comment.ID)
+		}
+	}
+
+	posted, err := postComments(poster, results, outcome, dryRun)
+	if err != nil {
+		return outcome, err
+	}
+	return outcome, linkComments(poster, results, posted, outcome, dryRun)
+}
+
+// FindPrevious returns the comments posted by this tool, identified by their
+// part marker and, when the poster can determine it, the authenticated login
+func FindPrevious(poster Poster) ([]Comment, error) {
+	log := logger.GetLogger()
+
+	login := ""
+	if lookup, ok := poster.(AuthorLookup); ok {
+		var err error
+		login, err = lookup.AuthenticatedLogin()
+		if err != nil {
+			log.Warnf("Could not determine authenticated user, matching previous comments by marker only: %v", err)
+		}
+	}
+
+	all, err := poster.ListComments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list existing comments: %w", err)
+	}
+
+	var previous []Comment
+	for _, comment := range all {
+		if _, ok := splitter.ParsePartMarker(comment.Body); !ok {
+			continue
+		}
+		if login != "" && !sameLogin(comment.Author, login) {
+			log.Debugf("Skipping comment %d by %s: not posted by %s", comment.ID, comment.Author, login)
+			continue
+		}
+		previous = append(previous, comment)
+	}
+
+	return previous, nil
+}
+
+// sameLogin compares logins ignoring case and the "[bot]" suffix some APIs
+// add to app accounts
+func sameLogin(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "[bot]"), strings.TrimSuffix(b, "[bot]"))
+}
+
+// postComments creates a new comment for every split result and returns
+// them. Each part links to the parts posted before it
+func postComments(poster Poster, results []splitter.SplitResult, outcome *Outcome, dryRun bool) ([]Comment, error) {
+	log := logger.GetLogger()
+
+	log.Infof("Posting %d comment(s)...", len(results))
+
+	posted := make([]Comment, len(results))
+	for i, result := range results {
+		result = render(poster, results, posted)[i]
+		if dryRun {
+			logDryRun("post", result)
+			outcome.Parts = append(outcome.Parts, PartOutcome{PartNumber: result.PartNumber, Action: ActionCreate})
+			continue
+		}
+
+		log.Infof("Posting part %d of %d...", result.PartNumber, result.TotalParts)
+		comment, err := poster.PostComment(result.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to post comment part %d: %w", result.PartNumber, err)
+		}
+		comment.Body = result.Content
+		posted[i] = comment
+		outcome.Parts = append(outcome.Parts, PartOutcome{PartNumber: result.PartNumber, Action: ActionCreated, Comment: comment})
+
+		if result.PartNumber < result.TotalParts {
+			time.Sleep(postDelay)
+		}
+	}
+
+	return posted, nil
+}
+
+// updateComments makes the comments match the split results: previous
+// comments for the same part are updated in place, missing parts are created
+// and leftover parts are deleted. Returns the comment holding every part
+func updateComments(poster Poster, results []splitter.SplitResult, previous []Comment, outcome *Outcome, dryRun bool) ([]Comment, error) {
+	log := logger.GetLogger()
+
+	existing := make(map[int][]Comment)
+	for _, comment := range previous {
+		partNumber, _ := splitter.ParsePartMarker(comment.Body)
+		existing[partNumber] = append(existing[partNumber], comment)
+	}
+
+	// Parts that already have a comment can be linked to right away
+	posted := make([]Comment, len(results))
+	for i, result := range results {
+		if comments := existing[result.PartNumber]; len(comments) > 0 {
+			posted[i] = comments[0]
+		}
+	}
+
+	log.Infof("Posting %d comment(s)...", len(results))
+
+	for i, result := range results {
+		result = render(poster, results, posted)[i]
+		comments := existing[result.PartNumber]
+		delete(existing, result.PartNumber)
+
+		var err error
+		var action Action
+		switch {
+		case dryRun:
+			logDryRun("post or update", result)
+			outcome.Parts = append(outcome.Parts, PartOutcome{PartNumber: result.PartNumber, Action: ActionCreateOrUpdate})
+			continue
+		case len(comments) == 0:
+			log.Infof("Posting part %d of %d...", result.PartNumber, result.TotalParts)
+			posted[i], err = poster.PostComment(result.Content)
+			posted[i].Body = result.Content
+			action = ActionCreated
+		case comments[0].Body == result.Content:
+			log.Infof("Part %d of %d is unchanged (comment %d)", result.PartNumber, result.TotalParts, comments[0].ID)
+			action = ActionUnchanged
+		default:
+			log.Infof("Updating part %d of %d (comment %d)...", result.PartNumber, result.TotalParts, comments[0].ID)
+			err = poster.UpdateComment(comments[0].ID, result.Content)
+			posted[i].Body = result.Content
+			action = ActionUpdated
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to post comment part %d: %w", result.PartNumber, err)
+		}
+		outcome.Parts = append(outcome.Parts, PartOutcome{PartNumber: result.PartNumber, Action: action, Comment: posted[i]})
+
+		// Duplicates of the same part are left over from interrupted runs
+		for i := 1; i < len(comments); i++ {
+			duplicate := comments[i]
+			if err := poster.DeleteComment(duplicate.ID); err != nil {
+				return nil, fmt.Errorf("failed to delete duplicate comment for part %d: %w", result.PartNumber, err)
+			}
+			outcome.Deleted = append(outcome.Deleted, duplicate.ID)
+		}
+
+		if result.PartNumber < result.TotalParts {
+			time.Sleep(postDelay)
+		}
+	}
+
+	// Anything left belongs to parts beyond the new total
+	for _, partNumber := range slices.Sorted(maps.Keys(existing)) {
+		for _, comment := range existing[partNumber] {
+			log.Infof("Deleting outdated part %d (comment %d)...", partNumber, comment.ID)
+			if err := poster.DeleteComment(comment.ID); err != nil {
+				return nil, fmt.Errorf("failed to delete outdated comment part %d: %w", partNumber, err)
+			}
+			outcome.Deleted = append(outcome.Deleted, comment.ID)
+		}
+	}
+
+	return posted, nil
+}
+
+// linkComments updates the posted comments whose links to other parts were
+// not known when they were posted. Unchanged parts that get updated are
+// marked as such in the outcome
+func linkComments(poster Poster, results []splitter.SplitResult, posted []Comment, outcome *Outcome, dryRun bool) error {
+	log := logger.GetLogger()
+
+	if dryRun || len(results) <= 1 {
+		return nil
+	}
+
+	for i, result := range render(poster, results, posted) {
+		if posted[i].Body == result.Content {
+			continue
+		}
+
+		log.Infof("Linking part %d of %d (comment %d)...", result.PartNumber, result.TotalParts, posted[i].ID)
+		if err := poster.UpdateComment(posted[i].ID, result.Content); err != nil {
+			return fmt.Errorf("failed to link comment part %d: %w", result.PartNumber, err)
+		}
+		if part := &outcome.Parts[i]; part.Action == ActionUnchanged {
+			part.Action = ActionUpdated
+		}
+	}
+
+	return nil
+}
+
+// render returns the split results linked to the given comments, one per
+// part, and formatted for the poster. Parts without a comment yet are left
+// unlinked
+func render(poster Poster, results []splitter.SplitResult, comments []Comment) []splitter.SplitResult {
+	links := make([]string, len(comments))
+	for i, comment := range comments {
+		links[i] = commentLink(comment.URL)
+	}
+	results = splitter.Link(results, links)
+
+	if formatter, ok := poster.(Formatter); ok {
+		results = formatResults(formatter, results)
+	}
+	return results
+}
+
+// commentLink returns the link to a comment from its URL. Comments are on
+// the same page, so the URL fragment is enough when there is one
+func commentLink(url string) string {
+	if i := strings.Index(url, "#"); i >= 0 {
+		return url[i:]
+	}
+	return url
+}
+
+// formatResults returns a copy of the results with their content formatted
+func formatResults(formatter Formatter, results []splitter.SplitResult) []splitter.SplitResult {
+	formatted := make([]splitter.SplitResult, 0, len(results))
+	for _, result := range results {
+		result.Content = formatter.FormatComment(result.Content)
+		result.Size = result.Unit.Measure(result.Content)
+		formatted = append(formatted, result)
+	}
+	return formatted
+}
+
+// logDryRun logs the action that would be taken for a split result
+func logDryRun(action string, result splitter.SplitResult) {
+	log := logger.GetLogger()
+
+	log.Infof("[DRY RUN] Would %s part %d of %d", action, result.PartNumber, result.TotalParts)
+	log.Infof("[DRY RUN] Comment length: %d %s", result.Size, result.Unit)
+	log.Debugf("[DRY RUN] Comment content:\n%s", result.Content)
+}
@@ -0,0 +1,354 @@
+package comments
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/splitter"
+)
+
+func init() {
+	// Initialize logger for tests
+	logger.Initialize(logger.ErrorLevel)
+	postDelay = 0
+}
+
+// fakePoster records the calls made through the Poster interface
+type fakePoster struct {
+	comments  []Comment
+	nextID    int64
+	login     string
+	posted    []string
+	updated   map[int64]string
+	deleted   []int64
+	minimized []int64
+}
+
+func newFakePoster(existing ...Comment) *fakePoster {
+	return &fakePoster{comments: existing, nextID: 100, updated: make(map[int64]string)}
+}
+
+func (f *fakePoster) PostComment(body string) (Comment, error) {
+	f.posted = append(f.posted, body)
+	f.nextID++
+	return Comment{ID: f.nextID, Body: body, URL: fmt.Sprintf("https://example.com/pr/1#comment-%d", f.nextID)}, nil
+}
+
+func (f *fakePoster) ListComments() ([]Comment, error) {
+	return f.comments, nil
+}
+
+func (f *fakePoster) UpdateComment(id int64, body string) error {
+	f.updated[id] = body
+	return nil
+}
+
+func (f *fakePoster) DeleteComment(id int64) error {
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+// fakeMinimizingPoster additionally implements Minimizer and AuthorLookup
+type fakeMinimizingPoster struct {
+	*fakePoster
+}
+
+func (f fakeMinimizingPoster) MinimizeComments(comments []Comment) (int, error) {
+	for _, comment := range comments {
+		f.minimized = append(f.minimized, comment.ID)
+	}
+	return len(comments), nil
+}
+
+func (f fakeMinimizingPoster) AuthenticatedLogin() (string, error) {
+	return f.login, nil
+}
+
+// partComment returns a comment carrying the marker for the given part
+func partComment(id int64, part int, author string) Comment {
+	return Comment{ID: id, Body: fmt.Sprintf("old part %d\n%s", part, splitter.PartMarker(part)), Author: author}
+}
+
+// splitResults returns results for the given number of parts
+func splitResults(total int) []splitter.SplitResult {
+	results := make([]splitter.SplitResult, 0, total)
+	for i := 1; i <= total; i++ {
+		content := fmt.Sprintf("new part %d\n%s", i, splitter.PartMarker(i))
+		results = append(results, splitter.SplitResult{PartNumber: i, TotalParts: total, Content: content, Size: len(content)})
+	}
+	return results
+}
+
+func TestSync_UpdateFewerParts(t *testing.T) {
+	poster := newFakePoster(
+		partComment(1, 1, "bot"),
+		Comment{ID: 2, Body: "LGTM", Author: "reviewer"},
+		partComment(3, 2, "bot"),
+		partComment(4, 3, "bot"),
+	)
+
+	outcome, err := Sync(poster, splitResults(2), StrategyUpdate, false)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	expectActions(t, outcome, ActionUpdated, ActionUpdated)
+	if len(outcome.Deleted) != 1 || outcome.Deleted[0] != 4 {
+		t.Errorf("Expected comment 4 reported as deleted, got %v", outcome.Deleted)
+	}
+
+	if len(poster.posted) != 0 {
+		t.Errorf("Expected no new comments, got %d", len(poster.posted))
+	}
+
+	if len(poster.updated) != 2 || poster.updated[1] == "" || poster.updated[3] == "" {
+		t.Errorf("Expected comments 1 and 3 to be updated, got %v", poster.updated)
+	}
+
+	if len(poster.deleted) != 1 || poster.deleted[0] != 4 {
+		t.Errorf("Expected comment 4 to be deleted, got %v", poster.deleted)
+	}
+}
+
+func TestSync_UpdateMoreParts(t *testing.T) {
+	poster := newFakePoster(partComment(1, 1, "bot"))
+
+	outcome, err := Sync(poster, splitResults(3), StrategyUpdate, false)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	expectActions(t, outcome, ActionUpdated, ActionCreated, ActionCreated)
+	if outcome.Parts[1].Comment.ID != 101 || outcome.Parts[1].Comment.URL != "https://example.com/pr/1#comment-101" {
+		t.Errorf("Expected part 2 to report comment 101, got %+v", outcome.Parts[1].Comment)
+	}
+
+	if len(poster.updated) != 1 {
+		t.Errorf("Expected 1 updated comment, got %d", len(poster.updated))
+	}
+
+	if len(poster.posted) != 2 {
+		t.Errorf("Expected 2 new comments, got %d", len(poster.posted))
+	}
+
+	if len(poster.deleted) != 0 {
+		t.Errorf("Expected no deleted comments, got %v", poster.deleted)
+	}
+}
+
+func TestSync_UpdateUnchangedAndDuplicates(t *testing.T) {
+	results := splitResults(1)
+	poster := newFakePoster(
+		Comment{ID: 1, Body: results[0].Content},
+		partComment(2, 1, "bot"),
+	)
+
+	outcome, err := Sync(poster, results, StrategyUpdate, false)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	expectActions(t, outcome, ActionUnchanged)
+	if outcome.Parts[0].Comment.ID != 1 {
+		t.Errorf("Expected the unchanged part to report comment 1, got %d", outcome.Parts[0].Comment.ID)
+	}
+
+	if len(poster.updated) != 0 || len(poster.posted) != 0 {
+		t.Errorf("Expected unchanged part to be left alone, got updated=%v posted=%d", poster.updated, len(poster.posted))
+	}
+
+	if len(poster.deleted) != 1 || poster.deleted[0] != 2 {
+		t.Errorf("Expected duplicate comment 2 to be deleted, got %v", poster.deleted)
+	}
+}
+
+func TestSync_Delete(t *testing.T) {
+	poster := newFakePoster(partComment(1, 1, "bot"), partComment(2, 2, "bot"))
+
+	if _, err := Sync(poster, splitResults(1), StrategyDelete, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if len(poster.deleted) != 2 {
+		t.Errorf("Expected 2 deleted comments, got %v", poster.deleted)
+	}
+
+	if len(poster.posted) != 1 {
+		t.Errorf("Expected 1 new comment, got %d", len(poster.posted))
+	}
+}
+
+func TestSync_Append(t *testing.T) {
+	poster := newFakePoster(partComment(1, 1, "bot"))
+
+	if _, err := Sync(poster, splitResults(2), StrategyAppend, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if len(poster.posted) != 2 || len(poster.updated) != 0 || len(poster.deleted) != 0 {
+		t.Errorf("Expected only 2 new comments, got posted=%d updated=%v deleted=%v",
+			len(poster.posted), poster.updated, poster.deleted)
+	}
+}
+
+func TestSync_MinimizeByAuthor(t *testing.T) {
+	poster := fakeMinimizingPoster{newFakePoster(
+		partComment(1, 1, "diff-bot[bot]"),
+		partComment(2, 1, "someone-else"),
+	)}
+	poster.login = "diff-bot"
+
+	if _, err := Sync(poster, splitResults(1), StrategyMinimize, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if len(poster.minimized) != 1 || poster.minimized[0] != 1 {
+		t.Errorf("Expected only comment 1 to be minimized, got %v", poster.minimized)
+	}
+
+	if len(poster.posted) != 1 {
+		t.Errorf("Expected 1 new comment, got %d", len(poster.posted))
+	}
+}
+
+func TestSync_MinimizeUnsupported(t *testing.T) {
+	poster := newFakePoster()
+
+	if _, err := Sync(poster, splitResults(1), StrategyMinimize, false); err == nil {
+		t.Error("Expected error for unsupported minimize strategy but got none")
+	}
+}
+
+func TestSync_DryRun(t *testing.T) {
+	poster := newFakePoster(partComment(1, 1, "bot"))
+
+	for _, strategy := range ValidStrategies() {
+		if strategy == string(StrategyMinimize) {
+			continue
+		}
+		outcome, err := Sync(poster, splitResults(2), Strategy(strategy), true)
+		if err != nil {
+			t.Fatalf("Sync with strategy %s failed: %v", strategy, err)
+		}
+
+		planned := ActionCreate
+		if strategy == string(StrategyUpdate) {
+			planned = ActionCreateOrUpdate
+		}
+		expectActions(t, outcome, planned, planned)
+	}
+
+	if len(poster.posted) != 0 || len(poster.updated) != 0 || len(poster.deleted) != 0 {
+		t.Errorf("Dry-run should not change comments, got posted=%d updated=%v deleted=%v",
+			len(poster.posted), poster.updated, poster.deleted)
+	}
+}
+
+// expectActions checks the action reported for every part
+func expectActions(t *testing.T, outcome *Outcome, expected ...Action) {
+	t.Helper()
+
+	if len(outcome.Parts) != len(expected) {
+		t.Fatalf("Expected %d part outcomes, got %d", len(expected), len(outcome.Parts))
+	}
+	for i, part := range outcome.Parts {
+		if part.PartNumber != i+1 || part.Action != expected[i] {
+			t.Errorf("Expected part %d to be %s, got part %d %s", i+1, expected[i], part.PartNumber, part.Action)
+		}
+	}
+}
+
+// navigationResults splits the test diff into parts linked to each other
+func navigationResults(t *testing.T) []splitter.SplitResult {
+	t.Helper()
+
+	results, err := splitter.Split("../../testing/too-long-diff.md", splitter.Options{MaxLength: 8000, Navigation: true})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(results) < 3 {
+		t.Fatalf("Expected at least 3 parts, got %d", len(results))
+	}
+	return results
+}
+
+func TestSync_LinksParts(t *testing.T) {
+	results := navigationResults(t)
+	poster := newFakePoster()
+
+	if _, err := Sync(poster, results, StrategyAppend, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if len(poster.posted) != len(results) {
+		t.Fatalf("Expected %d new comments, got %d", len(results), len(poster.posted))
+	}
+
+	// Posted comments are 101, 102, ... and all but the last need the link
+	// to the part posted after them
+	if len(poster.updated) != len(results)-1 {
+		t.Errorf("Expected %d comments to be linked, got %v", len(results)-1, poster.updated)
+	}
+
+	first := poster.updated[101]
+	for _, expected := range []string{"[Next →](#comment-102)", "- [argocd-helm-chart](#comment-101) (part 1)"} {
+		if !strings.Contains(first, expected) {
+			t.Errorf("Expected first part to contain %q, got:\n%s", expected, first)
+		}
+	}
+
+	last := poster.posted[len(results)-1]
+	if !strings.Contains(last, fmt.Sprintf("[← Previous](#comment-%d)", 100+len(results)-1)) {
+		t.Errorf("Expected last part to link to the previous one, got:\n%s", last)
+	}
+}
+
+func TestSync_UpdateLinkedUnchanged(t *testing.T) {
+	results := navigationResults(t)
+
+	first := newFakePoster()
+	if _, err := Sync(first, results, StrategyAppend, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	var existing []Comment
+	for i, body := range first.posted {
+		id := int64(101 + i)
+		if updated, ok := first.updated[id]; ok {
+			body = updated
+		}
+		existing = append(existing, Comment{ID: id, Body: body, URL: fmt.Sprintf("https://example.com/pr/1#comment-%d", id)})
+	}
+
+	poster := newFakePoster(existing...)
+	if _, err := Sync(poster, results, StrategyUpdate, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if len(poster.posted) != 0 || len(poster.updated) != 0 || len(poster.deleted) != 0 {
+		t.Errorf("Expected linked parts to be left alone, got posted=%d updated=%v deleted=%v",
+			len(poster.posted), poster.updated, poster.deleted)
+	}
+}
+
+// upperFormatter formats comments in upper case, keeping their length
+type upperFormatter struct{}
+
+func (upperFormatter) FormatComment(body string) string {
+	return strings.ToUpper(body)
+}
+
+func TestFormatResults_MeasuresInUnit(t *testing.T) {
+	content := "ñandú\n" + splitter.PartMarker(1)
+	results := []splitter.SplitResult{{PartNumber: 1, TotalParts: 1, Content: content, Unit: splitter.SizeRunes}}
+
+	formatted := formatResults(upperFormatter{}, results)
+	if expected := splitter.SizeRunes.Measure(formatted[0].Content); formatted[0].Size != expected {
+		t.Errorf("Expected the formatted size in runes, %d, got %d", expected, formatted[0].Size)
+	}
+	if formatted[0].Unit != splitter.SizeRunes {
+		t.Errorf("Expected the unit to be kept, got %q", formatted[0].Unit)
+	}
+}
@@ -0,0 +1,116 @@
+package diffparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LabeledReport is the report of a diff file with the label of its
+// environment, e.g. the cluster it was rendered for
+type LabeledReport struct {
+	Label  string
+	Report *Report
+}
+
+// Combine merges the reports of several environments into one. Its
+// applications are those of every report in order, each under the heading
+// of its environment. The intro is a table of the changes of every
+// environment, and the summary and stats list those of every report under
+// its label
+func Combine(reports []LabeledReport) *Report {
+	combined := &Report{Intro: environmentTable(reports)}
+
+	summary := &Summary{}
+	total := 0
+	for _, labeled := range reports {
+		report := labeled.Report
+		if combined.Title == "" {
+			combined.Title = report.Title
+		}
+
+		count, changed := reportTotal(report)
+		total += count
+
+		summary.Lines = append(summary.Lines, "", fmt.Sprintf("%s (%s):", labeled.Label, changed))
+		if report.Summary != nil {
+			for _, line := range report.Summary.Lines {
+				// Every environment's own total is in its label line
+				if strings.HasPrefix(line, "Total:") || strings.TrimSpace(line) == "" {
+					continue
+				}
+				summary.Lines = append(summary.Lines, line)
+			}
+			summary.Entries = append(summary.Entries, report.Summary.Entries...)
+		}
+
+		for _, app := range report.Apps {
+			app.Environment = labeled.Label
+			combined.Apps = append(combined.Apps, app)
+		}
+
+		combined.Other = append(combined.Other, report.Intro...)
+		combined.Other = append(combined.Other, report.Other...)
+		for _, warning := range report.Warnings {
+			combined.Warnings = append(combined.Warnings, fmt.Sprintf("%s (%s)", warning, labeled.Label))
+		}
+
+		if report.Stats != nil {
+			if combined.Stats == nil {
+				combined.Stats = &Stats{}
+			}
+			for _, line := range report.Stats.Lines {
+				combined.Stats.Lines = append(combined.Stats.Lines, fmt.Sprintf("- %s: %s", labeled.Label, line))
+			}
+		}
+	}
+
+	summary.Total = fmt.Sprintf("%d files changed", total)
+	summary.Lines = append([]string{"Total: " + summary.Total}, summary.Lines...)
+	combined.Summary = summary
+
+	return combined
+}
+
+// reportTotal returns the number of files changed in a report, from its
+// summary if it has one, and how the summary puts it
+func reportTotal(report *Report) (int, string) {
+	if report.Summary != nil {
+		if match := summaryTotalRegexp.FindStringSubmatch(report.Summary.Total); match != nil {
+			count, err := strconv.Atoi(match[1])
+			if err == nil {
+				return count, report.Summary.Total
+			}
+		}
+	}
+	return len(report.Apps), fmt.Sprintf("%d files changed", len(report.Apps))
+}
+
+// environmentTable returns a markdown table of the applications added,
+// modified and deleted and the lines changed in every environment
+func environmentTable(reports []LabeledReport) []string {
+	lines := []string{
+		"| Environment | Applications | Added | Modified | Deleted | Lines |",
+		"| --- | ---: | ---: | ---: | ---: | ---: |",
+	}
+	for _, labeled := range reports {
+		report := labeled.Report
+
+		changes := make(map[ChangeType]int)
+		for _, app := range report.Apps {
+			changes[app.Change]++
+		}
+
+		added, removed := 0, 0
+		if report.Summary != nil {
+			for _, entry := range report.Summary.Entries {
+				added += entry.Added
+				removed += entry.Removed
+			}
+		}
+
+		lines = append(lines, fmt.Sprintf("| %s | %d | %d | %d | %d | +%d -%d |", labeled.Label, len(report.Apps),
+			changes[ChangeAdded], changes[ChangeModified], changes[ChangeDeleted], added, removed))
+	}
+	return lines
+}
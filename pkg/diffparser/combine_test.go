@@ -0,0 +1,161 @@
+package diffparser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const devDiff = `## Argo CD Diff Preview
+
+Summary:
+` + "```yaml" + `
+Total: 2 files changed
+
+Added (1):
++ cache (+10)
+
+Modified (1):
+± guestbook (+3|-1)
+` + "```" + `
+
+<details>
+<summary>cache (apps/cache.yaml)</summary>
+<br>
+
+` + "```diff" + `
+@@ Application added: cache (apps/cache.yaml) @@
++kind: Deployment
+` + "```" + `
+
+</details>
+
+<details>
+<summary>guestbook (apps/guestbook.yaml)</summary>
+<br>
+
+` + "```diff" + `
+@@ Application modified: guestbook (apps/guestbook.yaml) @@
+-replicas: 1
++replicas: 2
+` + "```" + `
+
+</details>
+
+_Stats_:
+[Applications: 2], [Full Run: 10s]
+`
+
+const prodDiff = `## Argo CD Diff Preview
+
+Summary:
+` + "```yaml" + `
+Total: 1 files changed
+
+Deleted (1):
+- guestbook (-20)
+` + "```" + `
+
+<details>
+<summary>guestbook (apps/guestbook.yaml)</summary>
+<br>
+
+` + "```diff" + `
+@@ Application deleted: guestbook (apps/guestbook.yaml) @@
+-kind: Deployment
+` + "```" + `
+
+</details>
+
+⚠️ Diff is too long
+
+_Stats_:
+[Applications: 1], [Full Run: 12s]
+`
+
+// combined parses the dev and prod diffs and combines them
+func combined(t *testing.T) *Report {
+	t.Helper()
+
+	var reports []LabeledReport
+	for _, input := range []struct{ label, diff string }{{"dev", devDiff}, {"prod", prodDiff}} {
+		report, err := Parse(input.diff)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		reports = append(reports, LabeledReport{Label: input.label, Report: report})
+	}
+	return Combine(reports)
+}
+
+func TestCombine(t *testing.T) {
+	report := combined(t)
+
+	if report.Title != "Argo CD Diff Preview" {
+		t.Errorf("Unexpected title %q", report.Title)
+	}
+
+	expectedIntro := []string{
+		"| Environment | Applications | Added | Modified | Deleted | Lines |",
+		"| --- | ---: | ---: | ---: | ---: | ---: |",
+		"| dev | 2 | 1 | 1 | 0 | +13 -1 |",
+		"| prod | 1 | 0 | 0 | 1 | +0 -20 |",
+	}
+	if !slices.Equal(report.Intro, expectedIntro) {
+		t.Errorf("Expected intro:\n%s\ngot:\n%s", strings.Join(expectedIntro, "\n"), strings.Join(report.Intro, "\n"))
+	}
+
+	expectedSummary := []string{
+		"Total: 3 files changed",
+		"",
+		"dev (2 files changed):",
+		"Added (1):",
+		"+ cache (+10)",
+		"Modified (1):",
+		"± guestbook (+3|-1)",
+		"",
+		"prod (1 files changed):",
+		"Deleted (1):",
+		"- guestbook (-20)",
+	}
+	if !slices.Equal(report.Summary.Lines, expectedSummary) {
+		t.Errorf("Expected summary:\n%s\ngot:\n%s", strings.Join(expectedSummary, "\n"), strings.Join(report.Summary.Lines, "\n"))
+	}
+
+	if names := report.AppNames(); !slices.Equal(names, []string{"dev/cache", "dev/guestbook", "prod/guestbook"}) {
+		t.Errorf("Unexpected applications %q", names)
+	}
+	if !slices.Equal(report.Warnings, []string{"⚠️ Diff is too long (prod)"}) {
+		t.Errorf("Unexpected warnings %q", report.Warnings)
+	}
+	expectedStats := []string{"- dev: [Applications: 2], [Full Run: 10s]", "- prod: [Applications: 1], [Full Run: 12s]"}
+	if !slices.Equal(report.Stats.Lines, expectedStats) {
+		t.Errorf("Unexpected stats %q", report.Stats.Lines)
+	}
+}
+
+func TestCombine_RoundTrip(t *testing.T) {
+	report := combined(t)
+	rendered := report.Render()
+
+	if strings.Count(rendered, EnvironmentHeading("dev")) != 1 || strings.Count(rendered, EnvironmentHeading("prod")) != 1 {
+		t.Errorf("Expected one heading per environment:\n%s", rendered)
+	}
+
+	parsed, err := Parse(rendered)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if parsed.Render() != rendered {
+		t.Errorf("Expected the combined report to render the same once parsed:\n%s", parsed.Render())
+	}
+	if !slices.Equal(parsed.AppNames(), report.AppNames()) {
+		t.Errorf("Expected applications %q, got %q", report.AppNames(), parsed.AppNames())
+	}
+	if !slices.Equal(parsed.Intro, report.Intro) {
+		t.Errorf("Expected intro %q, got %q", report.Intro, parsed.Intro)
+	}
+	if !parsed.HasEnvironments() {
+		t.Error("Expected the parsed report to have environments")
+	}
+}
@@ -0,0 +1,136 @@
+package diffparser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	summaryGroupRegexp = regexp.MustCompile(`^(Added|Modified|Deleted) \((\d+)\):$`)
+	summaryTotalRegexp = regexp.MustCompile(`^(\d+)(.*)$`)
+	statsAppsRegexp    = regexp.MustCompile(`\[Applications: (\d+)\]`)
+)
+
+// Filter removes the applications keep returns false for, along with their
+// summary entries. The summary block is regenerated so its counts match the
+// applications left, and the applications removed are taken off the count in
+// the stats footer. Returns the names of the removed applications
+func (r *Report) Filter(keep func(name, path string) bool) []string {
+	paths := make(map[string]string, len(r.Apps))
+	for _, app := range r.Apps {
+		paths[app.Name] = app.Path
+	}
+
+	removed := make(map[string]bool)
+	var names []string
+	remove := func(name string) {
+		if !removed[name] {
+			removed[name] = true
+			names = append(names, name)
+		}
+	}
+
+	apps := r.Apps[:0:0]
+	for _, app := range r.Apps {
+		if keep(app.Name, app.Path) {
+			apps = append(apps, app)
+		} else {
+			remove(app.Name)
+		}
+	}
+	r.Apps = apps
+
+	// The summary can list applications without a diff section
+	if r.Summary != nil {
+		for _, entry := range r.Summary.Entries {
+			if !keep(entry.Name, paths[entry.Name]) {
+				remove(entry.Name)
+			}
+		}
+		r.Summary.remove(removed)
+	}
+
+	if r.Stats != nil {
+		r.Stats.removeApps(len(names))
+	}
+
+	return names
+}
+
+// removeApps takes the number of removed applications off the
+// "[Applications: N]" stat, which also counts the unchanged ones
+func (s *Stats) removeApps(removed int) {
+	if removed == 0 {
+		return
+	}
+	for i, line := range s.Lines {
+		s.Lines[i] = statsAppsRegexp.ReplaceAllStringFunc(line, func(stat string) string {
+			count, _ := strconv.Atoi(statsAppsRegexp.FindStringSubmatch(stat)[1])
+			return fmt.Sprintf("[Applications: %d]", max(count-removed, 0))
+		})
+	}
+}
+
+// remove drops the entries of the named applications from the summary and
+// rewrites its lines, recounting the total and every group from the entries
+// left
+func (s *Summary) remove(names map[string]bool) {
+	entries := s.Entries[:0:0]
+	dropped := 0
+	for _, entry := range s.Entries {
+		if names[entry.Name] {
+			dropped++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	s.Entries = entries
+	if dropped == 0 {
+		return
+	}
+
+	// Count the entries left in every group
+	counts := make(map[int]int)
+	group := -1
+	for i, line := range s.Lines {
+		if summaryGroupRegexp.MatchString(line) {
+			group = i
+			counts[group] = 0
+		} else if match := summaryEntryRegex.FindStringSubmatch(line); match != nil && !names[match[2]] {
+			counts[group]++
+		}
+	}
+
+	var lines []string
+	for i, line := range s.Lines {
+		switch {
+		case strings.HasPrefix(line, "Total:"):
+			if match := summaryTotalRegexp.FindStringSubmatch(s.Total); match != nil {
+				s.Total = fmt.Sprintf("%d%s", len(s.Entries), match[2])
+				line = "Total: " + s.Total
+			}
+		case summaryGroupRegexp.MatchString(line):
+			if counts[i] == 0 {
+				continue
+			}
+			line = fmt.Sprintf("%s (%d):", summaryGroupRegexp.FindStringSubmatch(line)[1], counts[i])
+		default:
+			if match := summaryEntryRegex.FindStringSubmatch(line); match != nil && names[match[2]] {
+				continue
+			}
+		}
+
+		// Emptied groups would leave consecutive blank lines behind
+		if strings.TrimSpace(line) == "" && (len(lines) == 0 || strings.TrimSpace(lines[len(lines)-1]) == "") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	s.Lines = lines
+}
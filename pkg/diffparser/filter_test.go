@@ -0,0 +1,117 @@
+package diffparser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReport_Filter(t *testing.T) {
+	tests := []struct {
+		name        string
+		keep        func(name, path string) bool
+		wantRemoved []string
+		wantApps    []string
+		wantTotal   string
+		wantSummary []string
+		wantStats   string
+	}{
+		{
+			name:        "keep everything",
+			keep:        func(name, path string) bool { return true },
+			wantApps:    []string{"web", "old-app"},
+			wantTotal:   "3 files changed",
+			wantSummary: strings.Split("Total: 3 files changed\n\nAdded (1):\n+ new-app (+10)\n\nModified (1):\n± web (+2|-1)\n\nDeleted (1):\n- old-app (-7)", "\n"),
+			wantStats:   "[Applications: 3], [Full Run: 1s]",
+		},
+		{
+			name:        "drop by name",
+			keep:        func(name, path string) bool { return name != "old-app" },
+			wantRemoved: []string{"old-app"},
+			wantApps:    []string{"web"},
+			wantTotal:   "2 files changed",
+			wantSummary: strings.Split("Total: 2 files changed\n\nAdded (1):\n+ new-app (+10)\n\nModified (1):\n± web (+2|-1)", "\n"),
+			wantStats:   "[Applications: 2], [Full Run: 1s]",
+		},
+		{
+			name:        "drop by path",
+			keep:        func(name, path string) bool { return !strings.HasPrefix(path, "apps/") },
+			wantRemoved: []string{"web"},
+			wantApps:    []string{"old-app"},
+			wantTotal:   "2 files changed",
+			wantSummary: strings.Split("Total: 2 files changed\n\nAdded (1):\n+ new-app (+10)\n\nDeleted (1):\n- old-app (-7)", "\n"),
+			wantStats:   "[Applications: 2], [Full Run: 1s]",
+		},
+		{
+			name:        "drop an application only in the summary",
+			keep:        func(name, path string) bool { return name != "new-app" },
+			wantRemoved: []string{"new-app"},
+			wantApps:    []string{"web", "old-app"},
+			wantTotal:   "2 files changed",
+			wantSummary: strings.Split("Total: 2 files changed\n\nModified (1):\n± web (+2|-1)\n\nDeleted (1):\n- old-app (-7)", "\n"),
+			wantStats:   "[Applications: 2], [Full Run: 1s]",
+		},
+		{
+			name:        "drop everything",
+			keep:        func(name, path string) bool { return false },
+			wantRemoved: []string{"web", "old-app", "new-app"},
+			wantTotal:   "0 files changed",
+			wantSummary: []string{"Total: 0 files changed"},
+			wantStats:   "[Applications: 0], [Full Run: 1s]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Parse(sampleDiff)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			removed := report.Filter(tt.keep)
+			if !slices.Equal(removed, tt.wantRemoved) {
+				t.Errorf("removed = %q, want %q", removed, tt.wantRemoved)
+			}
+			if got := report.AppNames(); !slices.Equal(got, tt.wantApps) {
+				t.Errorf("apps = %q, want %q", got, tt.wantApps)
+			}
+			if report.Summary.Total != tt.wantTotal {
+				t.Errorf("Summary.Total = %q, want %q", report.Summary.Total, tt.wantTotal)
+			}
+			if !slices.Equal(report.Summary.Lines, tt.wantSummary) {
+				t.Errorf("Summary.Lines = %q, want %q", report.Summary.Lines, tt.wantSummary)
+			}
+			if got := strings.Join(report.Stats.Lines, "\n"); got != tt.wantStats {
+				t.Errorf("Stats = %q, want %q", got, tt.wantStats)
+			}
+			if len(report.Summary.Entries) != 3-len(tt.wantRemoved) {
+				t.Errorf("got %d summary entries, want %d", len(report.Summary.Entries), 3-len(tt.wantRemoved))
+			}
+
+			// The filtered report must parse back to the same summary
+			reparsed, err := Parse(report.Render())
+			if err != nil {
+				t.Fatalf("Parse() of the filtered report error = %v", err)
+			}
+			if !slices.Equal(reparsed.Summary.Entries, report.Summary.Entries) {
+				t.Errorf("reparsed entries = %+v, want %+v", reparsed.Summary.Entries, report.Summary.Entries)
+			}
+		})
+	}
+}
+
+func TestReport_FilterRecountsTotal(t *testing.T) {
+	// A total out of step with the entries is recounted, not decremented
+	report, err := Parse(strings.Replace(sampleDiff, "Total: 3 files changed", "Total: 5 files changed", 1))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	report.Filter(func(name, path string) bool { return name != "old-app" })
+	if report.Summary.Total != "2 files changed" {
+		t.Errorf("Summary.Total = %q, want %q", report.Summary.Total, "2 files changed")
+	}
+	if report.Summary.Lines[0] != "Total: 2 files changed" {
+		t.Errorf("Summary.Lines[0] = %q, want %q", report.Summary.Lines[0], "Total: 2 files changed")
+	}
+}
@@ -0,0 +1,230 @@
+package diffparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChangeType is how an application changed
+type ChangeType string
+
+// Supported change types
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeModified ChangeType = "modified"
+	ChangeDeleted  ChangeType = "deleted"
+	// ChangeUnknown is used when the diff doesn't say how an application changed
+	ChangeUnknown ChangeType = ""
+)
+
+// Report is a parsed argocd-diff-preview markdown file
+type Report struct {
+	// Title is the text of the "## " heading
+	Title string
+	// Intro holds the lines before the first application that are in no
+	// known block, e.g. the environment table of a combined report
+	Intro []string
+	// Summary is the summary block, nil if the file has none
+	Summary *Summary
+	// Apps are the per-application sections, in file order
+	Apps []*Application
+	// Warnings are the truncation warnings argocd-diff-preview adds when the
+	// diff was cut short
+	Warnings []string
+	// Other holds lines outside any known block, kept so nothing is lost
+	Other []string
+	// Stats is the stats footer, nil if the file has none
+	Stats *Stats
+}
+
+// Summary is the yaml summary block listing the changed applications
+type Summary struct {
+	// Lines are the raw lines inside the yaml block
+	Lines []string
+	// Total is the value of the "Total:" line, e.g. "1 files changed"
+	Total   string
+	Entries []SummaryEntry
+}
+
+// SummaryEntry is an application listed in the summary block
+type SummaryEntry struct {
+	Name    string
+	Change  ChangeType
+	Added   int
+	Removed int
+}
+
+// Application is the diff section of a single application
+type Application struct {
+	Name string
+	// Path is the source path of the application manifest, empty if unknown
+	Path   string
+	Change ChangeType
+	// Header is the "@@ Application modified: ... @@" line, empty if absent
+	Header string
+	Hunks  []Hunk
+	// Environment is the label of the diff file the application comes from
+	// in a combined report, empty otherwise
+	Environment string
+}
+
+// Hunk is a run of diff lines between skipped-line markers
+type Hunk struct {
+	// Skipped is the marker preceding the hunk, nil for the first hunk
+	Skipped *SkippedLines
+	// Lines are the diff lines, including their +, - or space prefix
+	Lines []string
+}
+
+// SkippedLines is an "@@ skipped N lines (a -> b) @@" marker
+type SkippedLines struct {
+	Count int
+	From  int
+	To    int
+}
+
+// Stats is the "_Stats_:" footer
+type Stats struct {
+	// Lines are the lines following the "_Stats_:" line
+	Lines []string
+}
+
+// String renders the marker as it appears in the diff
+func (s SkippedLines) String() string {
+	return fmt.Sprintf("@@ skipped %d lines (%d -> %d) @@", s.Count, s.From, s.To)
+}
+
+// Title returns the text shown in the section's summary tag,
+// e.g. "app-name (path/to/app.yaml)"
+func (a *Application) Title() string {
+	if a.Path == "" {
+		return a.Name
+	}
+	return fmt.Sprintf("%s (%s)", a.Name, a.Path)
+}
+
+// QualifiedName returns the name of the application, prefixed with its
+// environment in a combined report, e.g. "prod/guestbook"
+func (a *Application) QualifiedName() string {
+	if a.Environment == "" {
+		return a.Name
+	}
+	return a.Environment + "/" + a.Name
+}
+
+// EnvironmentHeading returns the heading preceding the applications of an
+// environment in a combined report
+func EnvironmentHeading(environment string) string {
+	return environmentHeadingPrefix + environment
+}
+
+// DiffLines returns the lines inside the section's diff code block: the
+// header, then every hunk preceded by its skipped-line marker
+func (a *Application) DiffLines() []string {
+	var lines []string
+	if a.Header != "" {
+		lines = append(lines, a.Header)
+	}
+	for _, hunk := range a.Hunks {
+		if hunk.Skipped != nil {
+			lines = append(lines, hunk.Skipped.String())
+		}
+		lines = append(lines, hunk.Lines...)
+	}
+	return lines
+}
+
+// SectionOpening returns the lines that open a section with the given title,
+// up to and including the start of the diff code block
+func SectionOpening(title string) []string {
+	return []string{"<details>", fmt.Sprintf("<summary>%s</summary>", title), "<br>", "", "```diff"}
+}
+
+// SectionClosing returns the lines that close a section, from the end of
+// the diff code block
+func SectionClosing() []string {
+	return []string{"```", "", "</details>"}
+}
+
+// Lines returns the full markdown section of the application
+func (a *Application) Lines() []string {
+	lines := SectionOpening(a.Title())
+	lines = append(lines, a.DiffLines()...)
+	return append(lines, SectionClosing()...)
+}
+
+// AppNames returns the qualified names of the applications in the report,
+// in order
+func (r *Report) AppNames() []string {
+	names := make([]string, 0, len(r.Apps))
+	for _, app := range r.Apps {
+		names = append(names, app.QualifiedName())
+	}
+	return names
+}
+
+// HasEnvironments returns true if the report combines the diffs of several
+// environments
+func (r *Report) HasEnvironments() bool {
+	for _, app := range r.Apps {
+		if app.Environment != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderHeader renders the title, intro and summary block, followed by a
+// blank line
+func (r *Report) RenderHeader() string {
+	var b strings.Builder
+	if r.Title != "" {
+		fmt.Fprintf(&b, "## %s\n\n", r.Title)
+	}
+	if len(r.Intro) > 0 {
+		b.WriteString(strings.Join(r.Intro, "\n") + "\n\n")
+	}
+	if r.Summary != nil {
+		b.WriteString("Summary:\n```yaml\n")
+		for _, line := range r.Summary.Lines {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("```\n\n")
+	}
+	return b.String()
+}
+
+// RenderBody renders the application sections, warnings and other lines,
+// each followed by a blank line. The applications of every environment of a
+// combined report are preceded by its heading
+func (r *Report) RenderBody() string {
+	var b strings.Builder
+	environment := ""
+	for _, app := range r.Apps {
+		if app.Environment != environment {
+			environment = app.Environment
+			b.WriteString(EnvironmentHeading(environment) + "\n\n")
+		}
+		b.WriteString(strings.Join(app.Lines(), "\n") + "\n\n")
+	}
+	for _, line := range r.Other {
+		b.WriteString(line + "\n\n")
+	}
+	for _, warning := range r.Warnings {
+		b.WriteString(warning + "\n\n")
+	}
+	return b.String()
+}
+
+// RenderFooter renders the stats footer, or an empty string if there is none
+func (r *Report) RenderFooter() string {
+	if r.Stats == nil {
+		return ""
+	}
+	return "_Stats_:\n" + strings.Join(r.Stats.Lines, "\n") + "\n"
+}
+
+// Render renders the report back to markdown
+func (r *Report) Render() string {
+	return r.RenderHeader() + r.RenderBody() + r.RenderFooter()
+}
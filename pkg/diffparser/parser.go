@@ -0,0 +1,290 @@
+package diffparser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/input"
+)
+
+var (
+	summaryTagRegexp  = regexp.MustCompile(`^<summary>(.*?)(?: \(([^()]*)\))?</summary>$`)
+	appHeaderRegexp   = regexp.MustCompile(`^@@ Application (added|modified|deleted): .* @@$`)
+	skippedRegexp     = regexp.MustCompile(`^@@ skipped (\d+) lines \((\d+) -> (\d+)\) @@$`)
+	summaryEntryRegex = regexp.MustCompile(`^([+±-]) (\S+)(?: \((.*)\))?$`)
+)
+
+// warningPrefix starts the truncation warnings argocd-diff-preview writes
+const warningPrefix = "⚠️"
+
+// environmentHeadingPrefix starts the heading of an environment in a
+// combined report
+const environmentHeadingPrefix = "### Environment: "
+
+// ParseFile reads and parses an argocd-diff-preview markdown file. The path
+// may be input.Stdin, and the file may be compressed, see input.Open
+func ParseFile(path string) (*Report, error) {
+	content, err := input.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+	return Parse(string(content))
+}
+
+// Parse parses argocd-diff-preview markdown. Structure is only recognised on
+// whole lines outside code blocks, so manifests containing tags such as
+// <details> or "_Stats_:" don't confuse it
+func Parse(content string) (*Report, error) {
+	p := &parser{lines: strings.Split(strings.TrimSuffix(content, "\n"), "\n")}
+	return p.parse()
+}
+
+// parser walks the lines of a diff file
+type parser struct {
+	lines []string
+	pos   int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.lines)
+}
+
+func (p *parser) peek() string {
+	return strings.TrimRight(p.lines[p.pos], "\r")
+}
+
+func (p *parser) next() string {
+	line := p.peek()
+	p.pos++
+	return line
+}
+
+// skipBlank advances past empty lines
+func (p *parser) skipBlank() {
+	for !p.done() && strings.TrimSpace(p.peek()) == "" {
+		p.pos++
+	}
+}
+
+// errorf returns a parse error for the current line
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parse() (*Report, error) {
+	report := &Report{}
+	environment := ""
+
+	for !p.done() {
+		line := p.peek()
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			// Blank lines are only kept where they separate intro paragraphs
+			if len(report.Intro) > 0 && len(report.Apps) == 0 {
+				report.Intro = append(report.Intro, "")
+			}
+			p.pos++
+		case strings.HasPrefix(line, "## ") && report.Title == "" && len(report.Apps) == 0:
+			report.Title = strings.TrimPrefix(p.next(), "## ")
+		case trimmed == "Summary:" && report.Summary == nil:
+			summary, err := p.parseSummary()
+			if err != nil {
+				return nil, err
+			}
+			report.Summary = summary
+		case trimmed == "<details>":
+			app, err := p.parseApplication()
+			if err != nil {
+				return nil, err
+			}
+			app.Environment = environment
+			report.Apps = append(report.Apps, app)
+		case strings.HasPrefix(line, environmentHeadingPrefix):
+			environment = strings.TrimSpace(strings.TrimPrefix(p.next(), environmentHeadingPrefix))
+		case trimmed == "_Stats_:":
+			p.pos++
+			report.Stats = &Stats{}
+			for !p.done() {
+				report.Stats.Lines = append(report.Stats.Lines, p.next())
+			}
+			// Drop trailing blank lines
+			for len(report.Stats.Lines) > 0 && strings.TrimSpace(report.Stats.Lines[len(report.Stats.Lines)-1]) == "" {
+				report.Stats.Lines = report.Stats.Lines[:len(report.Stats.Lines)-1]
+			}
+		case strings.HasPrefix(trimmed, warningPrefix):
+			report.Warnings = append(report.Warnings, p.next())
+		case len(report.Apps) == 0 && environment == "":
+			report.Intro = append(report.Intro, p.next())
+		default:
+			report.Other = append(report.Other, p.next())
+		}
+	}
+
+	for len(report.Intro) > 0 && report.Intro[len(report.Intro)-1] == "" {
+		report.Intro = report.Intro[:len(report.Intro)-1]
+	}
+
+	// Applications without a header line take their change type from the summary
+	if report.Summary != nil {
+		for _, app := range report.Apps {
+			if app.Change != ChangeUnknown {
+				continue
+			}
+			for _, entry := range report.Summary.Entries {
+				if entry.Name == app.Name {
+					app.Change = entry.Change
+					break
+				}
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// parseSummary parses the "Summary:" line and the yaml block following it
+func (p *parser) parseSummary() (*Summary, error) {
+	p.pos++
+	p.skipBlank()
+	if p.done() || strings.TrimSpace(p.peek()) != "```yaml" {
+		return nil, p.errorf("expected summary code block")
+	}
+	p.pos++
+
+	summary := &Summary{}
+	change := ChangeUnknown
+	for {
+		if p.done() {
+			return nil, p.errorf("unterminated summary code block")
+		}
+		line := p.next()
+		if strings.TrimSpace(line) == "```" {
+			return summary, nil
+		}
+		summary.Lines = append(summary.Lines, line)
+
+		switch {
+		case strings.HasPrefix(line, "Total:"):
+			summary.Total = strings.TrimSpace(strings.TrimPrefix(line, "Total:"))
+		case strings.HasPrefix(line, "Added ("):
+			change = ChangeAdded
+		case strings.HasPrefix(line, "Modified ("):
+			change = ChangeModified
+		case strings.HasPrefix(line, "Deleted ("):
+			change = ChangeDeleted
+		default:
+			if match := summaryEntryRegex.FindStringSubmatch(line); match != nil {
+				entry := SummaryEntry{Name: match[2], Change: change}
+				entry.Added, entry.Removed = parseLineCounts(match[3])
+				summary.Entries = append(summary.Entries, entry)
+			}
+		}
+	}
+}
+
+// parseLineCounts parses the counts of a summary entry, e.g. "+212|-48"
+func parseLineCounts(counts string) (added, removed int) {
+	for _, count := range strings.Split(counts, "|") {
+		count = strings.TrimSpace(count)
+		if len(count) < 2 {
+			continue
+		}
+		n, err := strconv.Atoi(count[1:])
+		if err != nil {
+			continue
+		}
+		switch count[0] {
+		case '+':
+			added = n
+		case '-':
+			removed = n
+		}
+	}
+	return added, removed
+}
+
+// IsClosingFence reports whether a line inside a diff code block closes it.
+// Diff lines start with a prefix character, so only an exact "```" does
+func IsClosingFence(line string) bool {
+	return strings.TrimRight(line, "\r") == "```"
+}
+
+// parseApplication parses an application section from its <details> line to
+// its </details> line
+func (p *parser) parseApplication() (*Application, error) {
+	p.pos++
+	p.skipBlank()
+	if p.done() {
+		return nil, p.errorf("expected <summary> tag")
+	}
+
+	match := summaryTagRegexp.FindStringSubmatch(strings.TrimSpace(p.peek()))
+	if match == nil {
+		return nil, p.errorf("expected <summary> tag, got %q", p.peek())
+	}
+	p.pos++
+	app := &Application{Name: match[1], Path: match[2]}
+
+	// Skip the <br> and blank lines before the diff code block
+	for !p.done() && strings.TrimSpace(p.peek()) != "```diff" {
+		if trimmed := strings.TrimSpace(p.peek()); trimmed != "" && trimmed != "<br>" {
+			return nil, p.errorf("expected diff code block in application %s, got %q", app.Name, p.peek())
+		}
+		p.pos++
+	}
+	if p.done() {
+		return nil, p.errorf("expected diff code block in application %s", app.Name)
+	}
+	p.pos++
+
+	// Diff lines always start with a prefix character, so only a bare,
+	// unindented fence is the end of the block. Context lines such as
+	// "   ```" are manifest content
+	hunk := Hunk{}
+	first := true
+	for {
+		if p.done() {
+			return nil, p.errorf("unterminated diff code block in application %s", app.Name)
+		}
+		line := p.next()
+		if IsClosingFence(line) {
+			break
+		}
+
+		if first && appHeaderRegexp.MatchString(line) {
+			app.Header = line
+			app.Change = ChangeType(appHeaderRegexp.FindStringSubmatch(line)[1])
+			first = false
+			continue
+		}
+		first = false
+
+		if match := skippedRegexp.FindStringSubmatch(line); match != nil {
+			if hunk.Skipped != nil || len(hunk.Lines) > 0 {
+				app.Hunks = append(app.Hunks, hunk)
+			}
+			count, _ := strconv.Atoi(match[1])
+			from, _ := strconv.Atoi(match[2])
+			to, _ := strconv.Atoi(match[3])
+			hunk = Hunk{Skipped: &SkippedLines{Count: count, From: from, To: to}}
+			continue
+		}
+
+		hunk.Lines = append(hunk.Lines, line)
+	}
+	if hunk.Skipped != nil || len(hunk.Lines) > 0 {
+		app.Hunks = append(app.Hunks, hunk)
+	}
+
+	p.skipBlank()
+	if p.done() || strings.TrimSpace(p.peek()) != "</details>" {
+		return nil, p.errorf("expected </details> closing application %s", app.Name)
+	}
+	p.pos++
+
+	return app, nil
+}
@@ -0,0 +1,264 @@
+package diffparser
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const sampleDiff = "## Argo CD Diff Preview\n" +
+	"\n" +
+	"Summary:\n" +
+	"```yaml\n" +
+	"Total: 3 files changed\n" +
+	"\n" +
+	"Added (1):\n" +
+	"+ new-app (+10)\n" +
+	"\n" +
+	"Modified (1):\n" +
+	"± web (+2|-1)\n" +
+	"\n" +
+	"Deleted (1):\n" +
+	"- old-app (-7)\n" +
+	"```\n" +
+	"\n" +
+	"<details>\n" +
+	"<summary>web (apps/web.yaml)</summary>\n" +
+	"<br>\n" +
+	"\n" +
+	"```diff\n" +
+	"@@ Application modified: web (apps/web.yaml) @@\n" +
+	" metadata:\n" +
+	"+  description: <details>\n" +
+	"@@ skipped 34 lines (153 -> 186) @@\n" +
+	"-  note: _Stats_:\n" +
+	"+  note: </details>\n" +
+	"```\n" +
+	"\n" +
+	"</details>\n" +
+	"\n" +
+	"<details>\n" +
+	"<summary>old-app</summary>\n" +
+	"<br>\n" +
+	"\n" +
+	"```diff\n" +
+	"-kind: ConfigMap\n" +
+	"```\n" +
+	"\n" +
+	"</details>\n" +
+	"\n" +
+	"⚠️⚠️⚠️ Diff exceeds max length of 100 characters. Truncating to fit.\n" +
+	"\n" +
+	"_Stats_:\n" +
+	"[Applications: 3], [Full Run: 1s]\n"
+
+func TestParse(t *testing.T) {
+	report, err := Parse(sampleDiff)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if report.Title != "Argo CD Diff Preview" {
+		t.Errorf("Title = %q", report.Title)
+	}
+
+	if report.Summary == nil {
+		t.Fatal("Summary is nil")
+	}
+	if report.Summary.Total != "3 files changed" {
+		t.Errorf("Summary.Total = %q", report.Summary.Total)
+	}
+	wantEntries := []SummaryEntry{
+		{Name: "new-app", Change: ChangeAdded, Added: 10},
+		{Name: "web", Change: ChangeModified, Added: 2, Removed: 1},
+		{Name: "old-app", Change: ChangeDeleted, Removed: 7},
+	}
+	if len(report.Summary.Entries) != len(wantEntries) {
+		t.Fatalf("got %d summary entries, want %d", len(report.Summary.Entries), len(wantEntries))
+	}
+	for i, want := range wantEntries {
+		if report.Summary.Entries[i] != want {
+			t.Errorf("Summary.Entries[%d] = %+v, want %+v", i, report.Summary.Entries[i], want)
+		}
+	}
+
+	if len(report.Apps) != 2 {
+		t.Fatalf("got %d apps, want 2", len(report.Apps))
+	}
+
+	web := report.Apps[0]
+	if web.Name != "web" || web.Path != "apps/web.yaml" || web.Change != ChangeModified {
+		t.Errorf("Apps[0] = %s %s %s", web.Name, web.Path, web.Change)
+	}
+	if len(web.Hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2", len(web.Hunks))
+	}
+	if web.Hunks[0].Skipped != nil || len(web.Hunks[0].Lines) != 2 {
+		t.Errorf("Hunks[0] = %+v", web.Hunks[0])
+	}
+	if skipped := web.Hunks[1].Skipped; skipped == nil || *skipped != (SkippedLines{Count: 34, From: 153, To: 186}) {
+		t.Errorf("Hunks[1].Skipped = %+v", skipped)
+	}
+	if len(web.Hunks[1].Lines) != 2 {
+		t.Errorf("Hunks[1].Lines = %q", web.Hunks[1].Lines)
+	}
+
+	// Without a header line the change type comes from the summary
+	old := report.Apps[1]
+	if old.Name != "old-app" || old.Path != "" || old.Change != ChangeDeleted || old.Header != "" {
+		t.Errorf("Apps[1] = %+v", old)
+	}
+
+	if len(report.Warnings) != 1 || !strings.HasPrefix(report.Warnings[0], "⚠️") {
+		t.Errorf("Warnings = %q", report.Warnings)
+	}
+	if len(report.Other) != 0 {
+		t.Errorf("Other = %q", report.Other)
+	}
+	if report.Stats == nil || len(report.Stats.Lines) != 1 || report.Stats.Lines[0] != "[Applications: 3], [Full Run: 1s]" {
+		t.Errorf("Stats = %+v", report.Stats)
+	}
+}
+
+func TestParse_RoundTrip(t *testing.T) {
+	files := []string{
+		"../../testing/2-app-diff.md",
+		"../../testing/too-long-diff.md",
+	}
+
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Failed to read test file: %v", err)
+			}
+
+			report, err := Parse(string(content))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(report.Apps) == 0 {
+				t.Error("Expected at least one application")
+			}
+
+			if got := report.Render(); got != string(content) {
+				t.Errorf("Render() does not reproduce the input (%d bytes, want %d)", len(got), len(content))
+			}
+		})
+	}
+
+	report, err := Parse(sampleDiff)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := report.Render(); got != sampleDiff {
+		t.Errorf("Render() = %q, want %q", got, sampleDiff)
+	}
+}
+
+func TestParse_Intro(t *testing.T) {
+	content := "## Diff\n\nFirst paragraph\nstill first\n\nSecond paragraph\n\n" +
+		"<details>\n<summary>app</summary>\n<br>\n\n```diff\n+a\n```\n\n</details>\n\nTrailing note\n"
+
+	report, err := Parse(content)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	expected := []string{"First paragraph", "still first", "", "Second paragraph"}
+	if !slices.Equal(report.Intro, expected) {
+		t.Errorf("Intro = %q, expected %q", report.Intro, expected)
+	}
+	if !slices.Equal(report.Other, []string{"Trailing note"}) {
+		t.Errorf("Other = %q", report.Other)
+	}
+	if !strings.HasPrefix(report.Render(), "## Diff\n\nFirst paragraph\nstill first\n\nSecond paragraph\n\n<details>") {
+		t.Errorf("Expected the intro before the applications:\n%s", report.Render())
+	}
+}
+
+func TestParse_IndentedFence(t *testing.T) {
+	content := "<details>\n<summary>docs-app (apps/docs.yaml)</summary>\n<br>\n\n```diff\n" +
+		" data:\n" +
+		"   README.md: |\n" +
+		"     ```\n" +
+		"-    old\n" +
+		"+    new\n" +
+		"     ```\n" +
+		"```\n\n</details>\n\n"
+
+	report, err := Parse(content)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(report.Apps) != 1 {
+		t.Fatalf("Expected 1 application, got %d", len(report.Apps))
+	}
+	if lines := report.Apps[0].Hunks[0].Lines; len(lines) != 6 || lines[5] != "     ```" {
+		t.Errorf("Expected the indented fences to stay in the diff, got %q", lines)
+	}
+	if got := report.Render(); got != content {
+		t.Errorf("Render() = %q, want %q", got, content)
+	}
+}
+
+func TestIsClosingFence(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{line: "```", want: true},
+		{line: "```\r", want: true},
+		{line: "   ```", want: false},
+		{line: "```diff", want: false},
+		{line: "+```", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsClosingFence(tt.line); got != tt.want {
+			t.Errorf("IsClosingFence(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "unterminated summary",
+			content: "Summary:\n```yaml\nTotal: 1 files changed\n",
+			wantErr: "unterminated summary code block",
+		},
+		{
+			name:    "missing summary tag",
+			content: "<details>\n<br>\n",
+			wantErr: "line 2: expected <summary> tag",
+		},
+		{
+			name:    "unterminated diff",
+			content: "<details>\n<summary>app</summary>\n<br>\n\n```diff\n+a: b\n",
+			wantErr: "unterminated diff code block in application app",
+		},
+		{
+			name:    "missing closing details",
+			content: "<details>\n<summary>app</summary>\n<br>\n\n```diff\n+a: b\n```\n\n_Stats_:\n",
+			wantErr: "line 9: expected </details> closing application app",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.content)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
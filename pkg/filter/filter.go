@@ -0,0 +1,146 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rules are the globs applications are selected by. Globs use path.Match
+// syntax, plus ** to match any number of directories
+type Rules struct {
+	// IncludeApps and IncludePaths keep only the applications whose name or
+	// source path match any of them. When both are empty every application
+	// is included
+	IncludeApps  []string
+	IncludePaths []string
+	// ExcludeApps and ExcludePaths drop the applications whose name or source
+	// path match any of them, even if included
+	ExcludeApps  []string
+	ExcludePaths []string
+}
+
+// IsEmpty returns true if there are no rules
+func (r Rules) IsEmpty() bool {
+	return len(r.IncludeApps) == 0 && len(r.IncludePaths) == 0 &&
+		len(r.ExcludeApps) == 0 && len(r.ExcludePaths) == 0
+}
+
+// Filter selects applications by name and source path
+type Filter struct {
+	includeApps  []*regexp.Regexp
+	includePaths []*regexp.Regexp
+	excludeApps  []*regexp.Regexp
+	excludePaths []*regexp.Regexp
+}
+
+// New compiles the rules into a filter
+func New(rules Rules) (*Filter, error) {
+	f := &Filter{}
+	for _, set := range []struct {
+		globs  []string
+		target *[]*regexp.Regexp
+	}{
+		{rules.IncludeApps, &f.includeApps},
+		{rules.IncludePaths, &f.includePaths},
+		{rules.ExcludeApps, &f.excludeApps},
+		{rules.ExcludePaths, &f.excludePaths},
+	} {
+		for _, glob := range set.globs {
+			re, err := CompileGlob(glob)
+			if err != nil {
+				return nil, err
+			}
+			*set.target = append(*set.target, re)
+		}
+	}
+	return f, nil
+}
+
+// Keep returns true if the application with the given name and source path
+// is selected. Path rules never match an application with no known path
+func (f *Filter) Keep(name, path string) bool {
+	if matchAny(f.excludeApps, name) || (path != "" && matchAny(f.excludePaths, path)) {
+		return false
+	}
+	if len(f.includeApps) == 0 && len(f.includePaths) == 0 {
+		return true
+	}
+	return matchAny(f.includeApps, name) || (path != "" && matchAny(f.includePaths, path))
+}
+
+// matchAny returns true if s matches any of the globs
+func matchAny(globs []*regexp.Regexp, s string) bool {
+	for _, glob := range globs {
+		if glob.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompileGlob converts a glob to a regular expression matching the whole
+// string. * and ? don't match "/", ** matches anything, including "/", and
+// "**/" also matches no directory at all
+func CompileGlob(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				i++
+				if i+1 < len(runes) && runes[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := i + 1
+			if end < len(runes) && (runes[end] == '!' || runes[end] == '^') {
+				end++
+			}
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("invalid glob %q: unterminated character class", glob)
+			}
+			class := runes[i+1 : end]
+			b.WriteString("[")
+			if len(class) > 0 && (class[0] == '!' || class[0] == '^') {
+				b.WriteString("^")
+				class = class[1:]
+			}
+			b.WriteString(strings.ReplaceAll(string(class), `\`, `\\`))
+			b.WriteString("]")
+			i = end
+		case '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("invalid glob %q: trailing backslash", glob)
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", glob, err)
+	}
+	return re, nil
+}
@@ -0,0 +1,135 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		glob    string
+		input   string
+		matches bool
+	}{
+		{glob: "frontend", input: "frontend", matches: true},
+		{glob: "frontend", input: "frontend-staging", matches: false},
+		{glob: "frontend-*", input: "frontend-staging", matches: true},
+		{glob: "*-staging", input: "frontend-staging", matches: true},
+		{glob: "app-?", input: "app-1", matches: true},
+		{glob: "app-?", input: "app-10", matches: false},
+		{glob: "app-[0-9]", input: "app-7", matches: true},
+		{glob: "app-[!0-9]", input: "app-7", matches: false},
+		{glob: "app-[!0-9]", input: "app-x", matches: true},
+		{glob: "apps/*", input: "apps/frontend.yaml", matches: true},
+		{glob: "apps/*", input: "apps/staging/frontend.yaml", matches: false},
+		{glob: "apps/**", input: "apps/staging/frontend.yaml", matches: true},
+		{glob: "**/staging/*", input: "apps/staging/frontend.yaml", matches: true},
+		{glob: "**/staging/*", input: "staging/frontend.yaml", matches: true},
+		{glob: "apps/**/*.yaml", input: "apps/frontend.yaml", matches: true},
+		{glob: "apps/**/*.yaml", input: "apps/a/b/frontend.yaml", matches: true},
+		{glob: "apps/**/*.yaml", input: "apps/a/b/frontend.json", matches: false},
+		{glob: "app.yaml", input: "appxyaml", matches: false},
+		{glob: `\*`, input: "*", matches: true},
+		{glob: `\*`, input: "x", matches: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.glob+" "+tt.input, func(t *testing.T) {
+			re, err := CompileGlob(tt.glob)
+			if err != nil {
+				t.Fatalf("CompileGlob failed: %v", err)
+			}
+			if got := re.MatchString(tt.input); got != tt.matches {
+				t.Errorf("Expected %q matching %q to be %v, got %v", tt.glob, tt.input, tt.matches, got)
+			}
+		})
+	}
+}
+
+func TestCompileGlob_Invalid(t *testing.T) {
+	for _, glob := range []string{"app-[0-9", `app\`, "app-[z-a]"} {
+		if _, err := CompileGlob(glob); err == nil || !strings.Contains(err.Error(), "invalid glob") {
+			t.Errorf("Expected invalid glob error for %q, got %v", glob, err)
+		}
+	}
+}
+
+func TestFilter_Keep(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    Rules
+		app      string
+		path     string
+		expected bool
+	}{
+		{
+			name:     "no rules",
+			app:      "frontend",
+			path:     "apps/frontend.yaml",
+			expected: true,
+		},
+		{
+			name:     "included by name",
+			rules:    Rules{IncludeApps: []string{"front*"}},
+			app:      "frontend",
+			expected: true,
+		},
+		{
+			name:     "not included",
+			rules:    Rules{IncludeApps: []string{"back*"}},
+			app:      "frontend",
+			path:     "apps/frontend.yaml",
+			expected: false,
+		},
+		{
+			name:     "included by path",
+			rules:    Rules{IncludeApps: []string{"back*"}, IncludePaths: []string{"apps/**"}},
+			app:      "frontend",
+			path:     "apps/frontend.yaml",
+			expected: true,
+		},
+		{
+			name:     "excluded by name",
+			rules:    Rules{ExcludeApps: []string{"*-staging"}},
+			app:      "frontend-staging",
+			expected: false,
+		},
+		{
+			name:     "excluded by path",
+			rules:    Rules{ExcludePaths: []string{"**/staging/**"}},
+			app:      "frontend",
+			path:     "apps/staging/frontend.yaml",
+			expected: false,
+		},
+		{
+			name:     "exclude wins over include",
+			rules:    Rules{IncludeApps: []string{"front*"}, ExcludeApps: []string{"*-staging"}},
+			app:      "frontend-staging",
+			expected: false,
+		},
+		{
+			name:     "path rules don't match an unknown path",
+			rules:    Rules{IncludePaths: []string{"**"}},
+			app:      "frontend",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(tt.rules)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			if got := f.Keep(tt.app, tt.path); got != tt.expected {
+				t.Errorf("Expected Keep(%q, %q) to be %v, got %v", tt.app, tt.path, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidGlob(t *testing.T) {
+	if _, err := New(Rules{ExcludePaths: []string{"apps/[a"}}); err == nil {
+		t.Error("Expected error for an invalid glob but got none")
+	}
+}
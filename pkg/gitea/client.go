@@ -0,0 +1,228 @@
+package gitea
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/rest"
+)
+
+// MaxCommentLength is the comment length used by default. Gitea doesn't
+// enforce a limit, so this matches GitHub to keep comments readable
+const MaxCommentLength = 65536
+
+// Client represents a Gitea / Forgejo API client
+type Client struct {
+	rest *rest.Client
+}
+
+// Config holds configuration for Gitea client
+type Config struct {
+	Token          string
+	BaseURL        string
+	MaxRetries     int
+	RetryDelay     time.Duration
+	BackoffFactor  float64
+	RequestTimeout time.Duration
+}
+
+// PullRequest identifies a pull request on a Gitea instance
+type PullRequest struct {
+	Owner  string
+	Repo   string
+	Number int
+	// BaseURL is the instance URL, set when the reference is a URL
+	BaseURL string
+}
+
+// comment is the subset of the Gitea comment resource used by this client
+type comment struct {
+	ID      int64  `json:"id"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// nextLinkRegexp extracts the rel="next" URL from a Link header
+var nextLinkRegexp = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// NewClient creates a new Gitea client
+func NewClient(config Config) *Client {
+	restConfig := rest.Config{
+		MaxRetries:     config.MaxRetries,
+		RetryDelay:     config.RetryDelay,
+		BackoffFactor:  config.BackoffFactor,
+		RequestTimeout: config.RequestTimeout,
+	}
+
+	baseURL := strings.TrimSuffix(config.BaseURL, "/")
+
+	return &Client{
+		rest: rest.NewClient(baseURL+"/api/v1", restConfig, func(req *http.Request) {
+			req.Header.Set("Authorization", "token "+config.Token)
+		}),
+	}
+}
+
+// PostPRComment posts a comment to a Gitea pull request and returns the
+// created comment
+func (c *Client) PostPRComment(owner, repo string, prNumber int, body string) (comments.Comment, error) {
+	log := logger.GetLogger()
+
+	var created comment
+	path := fmt.Sprintf("/repos/%s/%s/issues/%d/comments", url.PathEscape(owner), url.PathEscape(repo), prNumber)
+	if _, err := c.rest.Do("POST", path, map[string]string{"body": body}, &created); err != nil {
+		return comments.Comment{}, fmt.Errorf("failed to post comment: %w", err)
+	}
+
+	log.Infof("Successfully posted comment to PR #%d", prNumber)
+	return created.toComment(), nil
+}
+
+// ListPRComments returns all comments on a Gitea pull request, oldest first
+func (c *Client) ListPRComments(owner, repo string, prNumber int) ([]comments.Comment, error) {
+	var result []comments.Comment
+
+	path := fmt.Sprintf("/repos/%s/%s/issues/%d/comments", url.PathEscape(owner), url.PathEscape(repo), prNumber)
+	for path != "" {
+		var page []comment
+		header, err := c.rest.Do("GET", path, nil, &page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list comments: %w", err)
+		}
+
+		for _, cm := range page {
+			result = append(result, cm.toComment())
+		}
+
+		// Older Gitea versions return every comment at once without a Link header
+		path = ""
+		if match := nextLinkRegexp.FindStringSubmatch(header.Get("Link")); match != nil {
+			path = match[1]
+		}
+	}
+
+	return result, nil
+}
+
+// toComment converts a comment returned by the API
+func (cm comment) toComment() comments.Comment {
+	return comments.Comment{
+		ID:     cm.ID,
+		Body:   cm.Body,
+		Author: cm.User.Login,
+		URL:    cm.HTMLURL,
+	}
+}
+
+// UpdatePRComment replaces the body of an existing pull request comment
+func (c *Client) UpdatePRComment(owner, repo string, commentID int64, body string) error {
+	log := logger.GetLogger()
+
+	path := fmt.Sprintf("/repos/%s/%s/issues/comments/%d", url.PathEscape(owner), url.PathEscape(repo), commentID)
+	if _, err := c.rest.Do("PATCH", path, map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	log.Infof("Successfully updated comment %d", commentID)
+	return nil
+}
+
+// DeletePRComment deletes an existing pull request comment
+func (c *Client) DeletePRComment(owner, repo string, commentID int64) error {
+	log := logger.GetLogger()
+
+	path := fmt.Sprintf("/repos/%s/%s/issues/comments/%d", url.PathEscape(owner), url.PathEscape(repo), commentID)
+	if _, err := c.rest.Do("DELETE", path, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+
+	log.Infof("Successfully deleted comment %d", commentID)
+	return nil
+}
+
+// GetAuthenticatedLogin returns the login the token belongs to
+func (c *Client) GetAuthenticatedLogin() (string, error) {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if _, err := c.rest.Do("GET", "/user", nil, &user); err != nil {
+		return "", fmt.Errorf("failed to get authenticated user: %w", err)
+	}
+	return user.Login, nil
+}
+
+// ValidatePRReference validates and parses a Gitea pull request reference
+// Accepts formats: owner/repo#123, https://git.example.com/owner/repo/pulls/123
+func ValidatePRReference(ref string) (PullRequest, error) {
+	// Handle URL format: https://git.example.com[/subpath]/owner/repo/pulls/123
+	if strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://") {
+		u, err := url.Parse(ref)
+		if err != nil {
+			return PullRequest{}, fmt.Errorf("invalid Gitea PR URL: %w", err)
+		}
+
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		idx := -1
+		for i := len(parts) - 2; i >= 2; i-- {
+			if parts[i] == "pulls" {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			return PullRequest{}, fmt.Errorf("invalid Gitea PR URL format")
+		}
+
+		prNumber, err := strconv.Atoi(parts[idx+1])
+		if err != nil {
+			return PullRequest{}, fmt.Errorf("invalid PR number in URL: %s", parts[idx+1])
+		}
+
+		// Anything before owner/repo is the path Gitea is served under
+		u.Path = ""
+		if idx > 2 {
+			u.Path = "/" + strings.Join(parts[:idx-2], "/")
+		}
+		u.RawQuery = ""
+		u.Fragment = ""
+
+		return PullRequest{
+			Owner:   parts[idx-2],
+			Repo:    parts[idx-1],
+			Number:  prNumber,
+			BaseURL: u.String(),
+		}, nil
+	}
+
+	// Handle short format: owner/repo#123
+	if strings.Contains(ref, "#") {
+		parts := strings.Split(ref, "#")
+		if len(parts) != 2 {
+			return PullRequest{}, fmt.Errorf("invalid PR reference format")
+		}
+
+		repoParts := strings.Split(parts[0], "/")
+		if len(repoParts) != 2 || repoParts[0] == "" || repoParts[1] == "" {
+			return PullRequest{}, fmt.Errorf("invalid repository format, expected owner/repo")
+		}
+
+		prNumber, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return PullRequest{}, fmt.Errorf("invalid PR number: %s", parts[1])
+		}
+
+		return PullRequest{Owner: repoParts[0], Repo: repoParts[1], Number: prNumber}, nil
+	}
+
+	return PullRequest{}, fmt.Errorf("invalid PR reference format, expected owner/repo#123 or https://git.example.com/owner/repo/pulls/123")
+}
@@ -0,0 +1,220 @@
+package gitea
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+)
+
+func init() {
+	// Initialize logger for tests
+	logger.Initialize(logger.ErrorLevel)
+}
+
+func TestPostPRComment_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Expected POST request, got %s", r.Method)
+		}
+
+		if r.URL.Path != "/api/v1/repos/owner/repo/issues/12/comments" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+
+		if r.Header.Get("Authorization") != "token test-token" {
+			t.Errorf("Expected Authorization 'token test-token', got %q", r.Header.Get("Authorization"))
+		}
+
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["body"] != "Test comment" {
+			t.Errorf("Expected body 'Test comment', got %q", body["body"])
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":       1,
+			"body":     "Test comment",
+			"html_url": "https://gitea.example.com/owner/repo/pulls/12#issuecomment-1",
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{Token: "test-token", BaseURL: server.URL, RequestTimeout: 30 * time.Second})
+
+	comment, err := client.PostPRComment("owner", "repo", 12, "Test comment")
+	if err != nil {
+		t.Fatalf("PostPRComment failed: %v", err)
+	}
+
+	if comment.ID != 1 || comment.URL != "https://gitea.example.com/owner/repo/pulls/12#issuecomment-1" {
+		t.Errorf("Unexpected created comment: %+v", comment)
+	}
+}
+
+func TestListPRComments_Pagination(t *testing.T) {
+	var serverURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/owner/repo/issues/12/comments" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `<`+serverURL+`/api/v1/repos/owner/repo/issues/12/comments?page=2>; rel="next", <`+serverURL+`/api/v1/repos/owner/repo/issues/12/comments?page=2>; rel="last"`)
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"id": 1, "body": "first", "html_url": "https://git.example.com/owner/repo/pulls/12#issuecomment-1", "user": map[string]string{"login": "bot"}},
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"id": 2, "body": "second", "user": map[string]string{"login": "someone"}},
+		})
+	}))
+	defer server.Close()
+	serverURL = server.URL
+
+	client := NewClient(Config{Token: "test-token", BaseURL: server.URL, RequestTimeout: 30 * time.Second})
+
+	comments, err := client.ListPRComments("owner", "repo", 12)
+	if err != nil {
+		t.Fatalf("ListPRComments failed: %v", err)
+	}
+
+	if len(comments) != 2 {
+		t.Fatalf("Expected 2 comments, got %d", len(comments))
+	}
+
+	if comments[0].ID != 1 || comments[0].Author != "bot" || comments[0].URL == "" {
+		t.Errorf("Unexpected first comment: %+v", comments[0])
+	}
+
+	if comments[1].ID != 2 || comments[1].Author != "someone" {
+		t.Errorf("Unexpected second comment: %+v", comments[1])
+	}
+}
+
+func TestUpdateAndDeletePRComment(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/owner/repo/issues/comments/42" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		methods = append(methods, r.Method)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{Token: "test-token", BaseURL: server.URL, RequestTimeout: 30 * time.Second})
+
+	if err := client.UpdatePRComment("owner", "repo", 42, "Updated"); err != nil {
+		t.Errorf("UpdatePRComment failed: %v", err)
+	}
+
+	if err := client.DeletePRComment("owner", "repo", 42); err != nil {
+		t.Errorf("DeletePRComment failed: %v", err)
+	}
+
+	if len(methods) != 2 || methods[0] != "PATCH" || methods[1] != "DELETE" {
+		t.Errorf("Expected PATCH then DELETE, got %v", methods)
+	}
+}
+
+func TestGetAuthenticatedLogin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/user" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"login": "ci-bot"})
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{Token: "test-token", BaseURL: server.URL, RequestTimeout: 30 * time.Second})
+
+	login, err := client.GetAuthenticatedLogin()
+	if err != nil {
+		t.Fatalf("GetAuthenticatedLogin failed: %v", err)
+	}
+
+	if login != "ci-bot" {
+		t.Errorf("Expected login 'ci-bot', got %q", login)
+	}
+}
+
+func TestValidatePRReference(t *testing.T) {
+	tests := []struct {
+		name        string
+		ref         string
+		expected    PullRequest
+		shouldError bool
+	}{
+		{
+			name:     "Short format",
+			ref:      "owner/repo#12",
+			expected: PullRequest{Owner: "owner", Repo: "repo", Number: 12},
+		},
+		{
+			name:     "URL format",
+			ref:      "https://git.example.com/owner/repo/pulls/12",
+			expected: PullRequest{Owner: "owner", Repo: "repo", Number: 12, BaseURL: "https://git.example.com"},
+		},
+		{
+			name:     "URL with trailing path",
+			ref:      "https://git.example.com/owner/repo/pulls/12/files",
+			expected: PullRequest{Owner: "owner", Repo: "repo", Number: 12, BaseURL: "https://git.example.com"},
+		},
+		{
+			name:     "URL with sub path and port",
+			ref:      "http://git.example.com:3000/forgejo/owner/repo/pulls/7",
+			expected: PullRequest{Owner: "owner", Repo: "repo", Number: 7, BaseURL: "http://git.example.com:3000/forgejo"},
+		},
+		{
+			name:        "GitHub style URL",
+			ref:         "https://git.example.com/owner/repo/pull/12",
+			shouldError: true,
+		},
+		{
+			name:        "Invalid PR number in URL",
+			ref:         "https://git.example.com/owner/repo/pulls/abc",
+			shouldError: true,
+		},
+		{
+			name:        "Invalid PR number",
+			ref:         "owner/repo#abc",
+			shouldError: true,
+		},
+		{
+			name:        "Invalid format",
+			ref:         "invalid",
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr, err := ValidatePRReference(tt.ref)
+
+			if tt.shouldError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+
+			if pr != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, pr)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package gitea
+
+import "github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
+
+// PRPoster manages the comments of a single Gitea pull request. It
+// implements comments.Poster and comments.AuthorLookup
+type PRPoster struct {
+	client *Client
+	pr     PullRequest
+}
+
+// NewPRPoster creates a poster for the given pull request
+func NewPRPoster(client *Client, pr PullRequest) *PRPoster {
+	return &PRPoster{
+		client: client,
+		pr:     pr,
+	}
+}
+
+// PostComment creates a new comment on the pull request
+func (p *PRPoster) PostComment(body string) (comments.Comment, error) {
+	return p.client.PostPRComment(p.pr.Owner, p.pr.Repo, p.pr.Number, body)
+}
+
+// ListComments returns all comments on the pull request
+func (p *PRPoster) ListComments() ([]comments.Comment, error) {
+	return p.client.ListPRComments(p.pr.Owner, p.pr.Repo, p.pr.Number)
+}
+
+// UpdateComment replaces the body of an existing comment
+func (p *PRPoster) UpdateComment(id int64, body string) error {
+	return p.client.UpdatePRComment(p.pr.Owner, p.pr.Repo, id, body)
+}
+
+// DeleteComment deletes an existing comment
+func (p *PRPoster) DeleteComment(id int64) error {
+	return p.client.DeletePRComment(p.pr.Owner, p.pr.Repo, id)
+}
+
+// AuthenticatedLogin returns the login the token belongs to
+func (p *PRPoster) AuthenticatedLogin() (string, error) {
+	return p.client.GetAuthenticatedLogin()
+}
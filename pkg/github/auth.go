@@ -0,0 +1,250 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/google/go-github/v69/github"
+)
+
+// CredentialProvider supplies the token used to authenticate API requests.
+// Token is called for every request, so providers with short-lived tokens
+// can refresh them transparently
+type CredentialProvider interface {
+	Token() (string, error)
+}
+
+// StaticToken is a credential provider for a fixed token, such as a
+// personal access token or the Actions GITHUB_TOKEN
+type StaticToken string
+
+// Token returns the static token
+func (t StaticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+// authTransport sets the Authorization header of every request to the API
+// hosts from a credential provider
+type authTransport struct {
+	credentials CredentialProvider
+	// hosts are the API and uploads hosts. Requests to any other host, e.g.
+	// after a redirect to release asset storage, are sent without the token
+	hosts []string
+	base  http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if !slices.ContainsFunc(t.hosts, func(host string) bool { return strings.EqualFold(host, req.URL.Host) }) {
+		return base.RoundTrip(req)
+	}
+
+	token, err := t.credentials.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the original request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return base.RoundTrip(req)
+}
+
+// AppConfig identifies a GitHub App installation
+type AppConfig struct {
+	AppID int64
+	// InstallationID is discovered from Owner and Repo when zero
+	InstallationID int64
+	// PrivateKey is the PEM encoded private key of the app
+	PrivateKey []byte
+	Owner      string
+	Repo       string
+}
+
+const (
+	// jwtLifetime is how long app JWTs are valid. GitHub allows at most 10 minutes
+	jwtLifetime = 9 * time.Minute
+	// jwtClockSkew backdates the JWT issue time to allow for clock drift
+	jwtClockSkew = 60 * time.Second
+	// tokenRefreshMargin is how long before expiry an installation token is renewed
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// AppCredentials is a credential provider that authenticates as a GitHub App
+// installation. Installation tokens expire after an hour and are renewed
+// before they do
+type AppCredentials struct {
+	app        AppConfig
+	privateKey *rsa.PrivateKey
+	config     Config
+	now        func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewAppCredentials creates a credential provider for a GitHub App
+// installation. config supplies the API URLs and retry settings used to
+// request installation tokens
+func NewAppCredentials(app AppConfig, config Config) (*AppCredentials, error) {
+	if app.AppID == 0 {
+		return nil, fmt.Errorf("GitHub App ID is required")
+	}
+
+	if app.InstallationID == 0 && (app.Owner == "" || app.Repo == "") {
+		return nil, fmt.Errorf("GitHub App installation ID is required when the repository is unknown")
+	}
+
+	privateKey, err := parsePrivateKey(app.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AppCredentials{
+		app:        app,
+		privateKey: privateKey,
+		config:     config,
+		now:        time.Now,
+	}, nil
+}
+
+// AppID returns the ID of the GitHub App the credentials authenticate as
+func (a *AppCredentials) AppID() int64 {
+	return a.app.AppID
+}
+
+// Token returns a valid installation token, requesting a new one when the
+// current token is missing or about to expire
+func (a *AppCredentials) Token() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && a.now().Add(tokenRefreshMargin).Before(a.expiresAt) {
+		return a.token, nil
+	}
+
+	if err := a.refresh(); err != nil {
+		return "", err
+	}
+	return a.token, nil
+}
+
+// refresh exchanges an app JWT for a new installation token
+func (a *AppCredentials) refresh() error {
+	log := logger.GetLogger()
+	ctx := context.Background()
+
+	jwt, err := a.jwt()
+	if err != nil {
+		return err
+	}
+
+	client, err := newGitHubClient(&http.Client{Timeout: a.config.RequestTimeout}, a.config)
+	if err != nil {
+		return err
+	}
+	client = client.WithAuthToken(jwt)
+
+	if a.app.InstallationID == 0 {
+		err := withRetry(a.config, "find GitHub App installation", func() (*github.Response, error) {
+			installation, resp, err := client.Apps.FindRepositoryInstallation(ctx, a.app.Owner, a.app.Repo)
+			if err == nil {
+				a.app.InstallationID = installation.GetID()
+			}
+			return resp, err
+		})
+		if err != nil {
+			return err
+		}
+		log.Debugf("Found GitHub App installation %d for %s/%s", a.app.InstallationID, a.app.Owner, a.app.Repo)
+	}
+
+	var token *github.InstallationToken
+	err = withRetry(a.config, "create installation token", func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		token, resp, err = client.Apps.CreateInstallationToken(ctx, a.app.InstallationID, nil)
+		return resp, err
+	})
+	if err != nil {
+		return err
+	}
+
+	a.token = token.GetToken()
+	a.expiresAt = token.GetExpiresAt().Time
+	log.Debugf("Obtained installation token expiring at %v", a.expiresAt)
+	return nil
+}
+
+// jwt returns a signed RS256 JSON Web Token identifying the app
+func (a *AppCredentials) jwt() (string, error) {
+	now := a.now()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-jwtClockSkew).Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+		"iss": strconv.FormatInt(a.app.AppID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString(header) + "." + encoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+
+	return unsigned + "." + encoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey decodes a PEM encoded RSA private key in PKCS#1 or PKCS#8 form
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid GitHub App private key: no PEM data found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub App private key: %w", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid GitHub App private key: not an RSA key")
+	}
+	return rsaKey, nil
+}
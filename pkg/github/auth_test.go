@@ -0,0 +1,268 @@
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPrivateKey generates an RSA key and returns it with its PEM encoding
+func testPrivateKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return key, pemData
+}
+
+func TestStaticToken(t *testing.T) {
+	token, err := StaticToken("test-token").Token()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if token != "test-token" {
+		t.Errorf("Expected token 'test-token', got %q", token)
+	}
+}
+
+func TestNewAppCredentials_Validation(t *testing.T) {
+	_, pemData := testPrivateKey(t)
+
+	tests := []struct {
+		name        string
+		app         AppConfig
+		shouldError bool
+	}{
+		{
+			name: "Installation ID",
+			app:  AppConfig{AppID: 1, InstallationID: 2, PrivateKey: pemData},
+		},
+		{
+			name: "Repository for discovery",
+			app:  AppConfig{AppID: 1, PrivateKey: pemData, Owner: "owner", Repo: "repo"},
+		},
+		{
+			name:        "Missing app ID",
+			app:         AppConfig{InstallationID: 2, PrivateKey: pemData},
+			shouldError: true,
+		},
+		{
+			name:        "Missing installation ID and repository",
+			app:         AppConfig{AppID: 1, PrivateKey: pemData},
+			shouldError: true,
+		},
+		{
+			name:        "Invalid private key",
+			app:         AppConfig{AppID: 1, InstallationID: 2, PrivateKey: []byte("not a key")},
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAppCredentials(tt.app, Config{})
+
+			if tt.shouldError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.shouldError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAppCredentials_JWT(t *testing.T) {
+	key, pemData := testPrivateKey(t)
+
+	credentials, err := NewAppCredentials(AppConfig{AppID: 12345, InstallationID: 1, PrivateKey: pemData}, Config{})
+	if err != nil {
+		t.Fatalf("NewAppCredentials failed: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	credentials.now = func() time.Time { return now }
+
+	jwt, err := credentials.jwt()
+	if err != nil {
+		t.Fatalf("jwt failed: %v", err)
+	}
+
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Expected 3 JWT segments, got %d", len(parts))
+	}
+
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("JWT signature does not verify: %v", err)
+	}
+
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims struct {
+		IAT int64  `json:"iat"`
+		EXP int64  `json:"exp"`
+		ISS string `json:"iss"`
+	}
+	json.Unmarshal(payload, &claims)
+
+	if claims.ISS != "12345" {
+		t.Errorf("Expected iss '12345', got %q", claims.ISS)
+	}
+
+	if claims.IAT != now.Unix()-60 || claims.EXP != now.Add(9*time.Minute).Unix() {
+		t.Errorf("Unexpected iat/exp: %d/%d", claims.IAT, claims.EXP)
+	}
+}
+
+func TestAppCredentials_DiscoverAndRefresh(t *testing.T) {
+	_, pemData := testPrivateKey(t)
+
+	now := time.Now()
+	var tokenRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ey") {
+			t.Errorf("Expected JWT bearer auth, got %q", r.Header.Get("Authorization"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/repos/owner/repo/installation"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 99})
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/app/installations/99/access_tokens"):
+			tokenRequests++
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"token":      "installation-token-" + string(rune('0'+tokenRequests)),
+				"expires_at": now.Add(time.Hour).Format(time.RFC3339),
+			})
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := Config{APIURL: server.URL, RequestTimeout: 30 * time.Second}
+	credentials, err := NewAppCredentials(AppConfig{AppID: 1, PrivateKey: pemData, Owner: "owner", Repo: "repo"}, config)
+	if err != nil {
+		t.Fatalf("NewAppCredentials failed: %v", err)
+	}
+
+	clock := now
+	credentials.now = func() time.Time { return clock }
+
+	token, err := credentials.Token()
+	if err != nil {
+		t.Fatalf("Token failed: %v", err)
+	}
+	if token != "installation-token-1" {
+		t.Errorf("Expected first installation token, got %q", token)
+	}
+
+	// A token that is still valid is reused
+	clock = now.Add(30 * time.Minute)
+	token, _ = credentials.Token()
+	if token != "installation-token-1" || tokenRequests != 1 {
+		t.Errorf("Expected cached token, got %q after %d requests", token, tokenRequests)
+	}
+
+	// A token about to expire is renewed
+	clock = now.Add(56 * time.Minute)
+	token, _ = credentials.Token()
+	if token != "installation-token-2" || tokenRequests != 2 {
+		t.Errorf("Expected refreshed token, got %q after %d requests", token, tokenRequests)
+	}
+}
+
+func TestNewClient_UsesCredentialProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer provided-token" {
+			t.Errorf("Expected token from provider, got %q", r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	config := Config{Credentials: StaticToken("provided-token"), APIURL: server.URL, RequestTimeout: 30 * time.Second}
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	if err := client.DeletePRComment("owner", "repo", 42, config, false); err != nil {
+		t.Errorf("DeletePRComment failed: %v", err)
+	}
+}
+
+func TestNewClient_AppID(t *testing.T) {
+	_, pemData := testPrivateKey(t)
+
+	credentials, err := NewAppCredentials(AppConfig{AppID: 12345, InstallationID: 1, PrivateKey: pemData}, Config{})
+	if err != nil {
+		t.Fatalf("NewAppCredentials failed: %v", err)
+	}
+
+	client, err := NewClient(Config{Credentials: credentials})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if client.appID != 12345 {
+		t.Errorf("Expected app ID 12345, got %d", client.appID)
+	}
+
+	client, err = NewClient(Config{Token: "test-token"})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if client.appID != 0 {
+		t.Errorf("Expected no app ID with a token, got %d", client.appID)
+	}
+}
+
+func TestNewClient_TokenOnlySentToAPIHost(t *testing.T) {
+	// Serves the redirect target, e.g. release asset storage on another host
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("Expected no token on another host, got %q", auth)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer storage.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer provided-token" {
+			t.Errorf("Expected token from provider, got %q", r.Header.Get("Authorization"))
+		}
+		http.Redirect(w, r, storage.URL+"/asset", http.StatusFound)
+	}))
+	defer api.Close()
+
+	config := Config{Credentials: StaticToken("provided-token"), APIURL: api.URL, RequestTimeout: 30 * time.Second}
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	if err := client.DeletePRComment("owner", "repo", 42, config, false); err != nil {
+		t.Errorf("DeletePRComment failed: %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/diffparser"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/splitter"
+	"github.com/google/go-github/v69/github"
+)
+
+// CheckRunName is the name of the check run the diff is published to
+const CheckRunName = "ArgoCD Diff"
+
+// MaxCheckRunOutputLength is GitHub's limit, in characters, for each of the
+// summary and text fields of a check run output
+const MaxCheckRunOutputLength = 65535
+
+// maxAnnotationsPerRequest is the number of annotations GitHub accepts in a
+// single check run request
+const maxAnnotationsPerRequest = 50
+
+// checkRunTruncatedNotice is appended to the text when the diff doesn't fit
+// in the summary and text fields
+const checkRunTruncatedNotice = "\n\n> [!NOTE]\n> The diff is too large for a check run: %d more part(s) were omitted.\n"
+
+// CheckRunOutput is the content of the diff check run
+type CheckRunOutput struct {
+	Title   string
+	Summary string
+	Text    string
+	// Conclusion is neutral when applications changed and success otherwise
+	Conclusion  string
+	Annotations []*github.CheckRunAnnotation
+}
+
+// BuildCheckRunOutput renders a diff file as a check run output. The summary
+// holds the diff header and the text the application sections, with an
+// annotation for every application. When they don't fit, the diff is split
+// and the first two parts fill the summary and text, followed by a notice
+func BuildCheckRunOutput(diffFile string) (CheckRunOutput, error) {
+	report, err := diffparser.ParseFile(diffFile)
+	if err != nil {
+		return CheckRunOutput{}, fmt.Errorf("failed to parse diff file: %w", err)
+	}
+
+	var annotations []*github.CheckRunAnnotation
+	for _, app := range report.Apps {
+		// Annotations belong to a file, so apps without a path only count
+		// towards the title
+		if app.Path == "" {
+			continue
+		}
+		name := app.QualifiedName()
+		annotations = append(annotations, &github.CheckRunAnnotation{
+			Path:            github.Ptr(app.Path),
+			StartLine:       github.Ptr(1),
+			EndLine:         github.Ptr(1),
+			AnnotationLevel: github.Ptr("notice"),
+			Title:           github.Ptr(name),
+			Message:         github.Ptr(fmt.Sprintf("The rendered manifests of application %s changed", name)),
+		})
+	}
+
+	output := CheckRunOutput{
+		Title:       "No changes",
+		Conclusion:  "success",
+		Annotations: annotations,
+	}
+	if len(report.Apps) > 0 {
+		output.Title = fmt.Sprintf("%d application(s) changed", len(report.Apps))
+		output.Conclusion = "neutral"
+	}
+
+	header := report.RenderHeader()
+	sections := report.RenderBody() + report.RenderFooter()
+	if utf8.RuneCountInString(header) <= MaxCheckRunOutputLength && utf8.RuneCountInString(sections) <= MaxCheckRunOutputLength {
+		output.Summary = header
+		output.Text = sections
+		return output, nil
+	}
+
+	results, err := splitter.Split(diffFile, splitter.Options{
+		MaxLength: MaxCheckRunOutputLength - len(checkRunTruncatedNotice) - 4,
+		Unit:      splitter.SizeRunes,
+	})
+	if err != nil {
+		return CheckRunOutput{}, fmt.Errorf("failed to split diff file for the check run: %w", err)
+	}
+
+	output.Summary = results[0].Body()
+	if len(results) > 1 {
+		output.Text = results[1].Body()
+	}
+	if omitted := len(results) - 2; omitted > 0 {
+		output.Text += fmt.Sprintf(checkRunTruncatedNotice, omitted)
+	}
+
+	return output, nil
+}
+
+// PublishCheckRun creates the diff check run on the head commit of a PR, or
+// updates it if the authenticated GitHub App already created one for that
+// commit. Annotations
+// are only sent when the check run is created, as GitHub appends them on
+// every update
+func (c *Client) PublishCheckRun(owner, repo string, prNumber int, output CheckRunOutput, config Config, dryRun bool) error {
+	log := logger.GetLogger()
+	ctx := context.Background()
+
+	if dryRun {
+		log.Infof("[DRY RUN] Would publish check run %q on PR #%d in %s/%s: %s (%s)", CheckRunName, prNumber, owner, repo, output.Title, output.Conclusion)
+		log.Infof("[DRY RUN] Check run summary length: %d bytes, text length: %d bytes, annotations: %d",
+			len(output.Summary), len(output.Text), len(output.Annotations))
+		return nil
+	}
+
+	var headSHA string
+	err := withRetry(config, "get pull request", func() (*github.Response, error) {
+		pr, resp, err := c.client.PullRequests.Get(ctx, owner, repo, prNumber)
+		if err == nil {
+			headSHA = pr.GetHead().GetSHA()
+		}
+		return resp, err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Check runs can only be updated by the app that created them, so another
+	// app's check run with the same name is left alone. Token-authenticated
+	// clients don't know their app, and always create a new check run
+	var existing *github.CheckRun
+	if c.appID != 0 {
+		err = withRetry(config, "list check runs", func() (*github.Response, error) {
+			opts := &github.ListCheckRunsOptions{
+				CheckName: github.Ptr(CheckRunName),
+				AppID:     github.Ptr(c.appID),
+			}
+			result, resp, err := c.client.Checks.ListCheckRunsForRef(ctx, owner, repo, headSHA, opts)
+			if err == nil {
+				existing = ownCheckRun(result.CheckRuns, c.appID)
+			}
+			return resp, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	checkOutput := func(annotations []*github.CheckRunAnnotation) *github.CheckRunOutput {
+		return &github.CheckRunOutput{
+			Title:       github.Ptr(output.Title),
+			Summary:     github.Ptr(output.Summary),
+			Text:        github.Ptr(output.Text),
+			Annotations: annotations,
+		}
+	}
+	completedAt := &github.Timestamp{Time: time.Now()}
+
+	if existing != nil {
+		err := withRetry(config, "update check run", func() (*github.Response, error) {
+			_, resp, err := c.client.Checks.UpdateCheckRun(ctx, owner, repo, existing.GetID(), github.UpdateCheckRunOptions{
+				Name:        CheckRunName,
+				Status:      github.Ptr("completed"),
+				Conclusion:  github.Ptr(output.Conclusion),
+				CompletedAt: completedAt,
+				Output:      checkOutput(nil),
+			})
+			return resp, err
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Successfully updated check run %d on %s", existing.GetID(), headSHA)
+		return nil
+	}
+
+	annotations := output.Annotations
+	first := annotations[:min(len(annotations), maxAnnotationsPerRequest)]
+
+	var checkRun *github.CheckRun
+	err = withRetry(config, "create check run", func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		checkRun, resp, err = c.client.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+			Name:        CheckRunName,
+			HeadSHA:     headSHA,
+			Status:      github.Ptr("completed"),
+			Conclusion:  github.Ptr(output.Conclusion),
+			CompletedAt: completedAt,
+			Output:      checkOutput(first),
+		})
+		return resp, err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Remaining annotations are added in batches by updating the check run
+	for start := len(first); start < len(annotations); start += maxAnnotationsPerRequest {
+		batch := annotations[start:min(start+maxAnnotationsPerRequest, len(annotations))]
+		err := withRetry(config, "add check run annotations", func() (*github.Response, error) {
+			_, resp, err := c.client.Checks.UpdateCheckRun(ctx, owner, repo, checkRun.GetID(), github.UpdateCheckRunOptions{
+				Name:   CheckRunName,
+				Output: checkOutput(batch),
+			})
+			return resp, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Successfully created check run %d on %s", checkRun.GetID(), headSHA)
+	return nil
+}
+
+// ownCheckRun returns the first check run created by the app, or nil
+func ownCheckRun(runs []*github.CheckRun, appID int64) *github.CheckRun {
+	for _, run := range runs {
+		if run.GetApp().GetID() == appID {
+			return run
+		}
+	}
+	return nil
+}
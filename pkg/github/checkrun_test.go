@@ -0,0 +1,295 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCheckRunDiff creates a diff file with the given number of applications
+// and diff lines per application
+func writeCheckRunDiff(t *testing.T, apps, lines int) string {
+	t.Helper()
+
+	var b strings.Builder
+	b.WriteString("## Argo CD Diff Preview\n\nSummary:\n```yaml\nTotal: changes\n```\n\n")
+	for i := 1; i <= apps; i++ {
+		fmt.Fprintf(&b, "<details>\n<summary>app-%d (apps/app-%d.yaml)</summary>\n<br>\n\n```diff\n", i, i)
+		for j := 0; j < lines; j++ {
+			fmt.Fprintf(&b, "+  key-%d: value for application %d\n", j, i)
+		}
+		b.WriteString("```\n\n</details>\n\n")
+	}
+	b.WriteString("_Stats_:\n[Applications: many]\n")
+
+	path := filepath.Join(t.TempDir(), "diff.md")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatalf("Failed to create diff file: %v", err)
+	}
+	return path
+}
+
+func TestBuildCheckRunOutput_WithChanges(t *testing.T) {
+	output, err := BuildCheckRunOutput(writeCheckRunDiff(t, 2, 5))
+	if err != nil {
+		t.Fatalf("BuildCheckRunOutput failed: %v", err)
+	}
+
+	if output.Conclusion != "neutral" {
+		t.Errorf("Expected neutral conclusion, got %q", output.Conclusion)
+	}
+
+	if output.Title != "2 application(s) changed" {
+		t.Errorf("Unexpected title: %q", output.Title)
+	}
+
+	if !strings.HasPrefix(output.Summary, "## Argo CD Diff Preview") || strings.Contains(output.Summary, "<details>") {
+		t.Errorf("Summary should hold only the diff header, got %q", output.Summary)
+	}
+
+	if !strings.HasPrefix(output.Text, "<details>") || !strings.Contains(output.Text, "app-2 (apps/app-2.yaml)") {
+		t.Errorf("Text should hold the application sections, got %q", output.Text)
+	}
+
+	if len(output.Annotations) != 2 || output.Annotations[0].GetPath() != "apps/app-1.yaml" || output.Annotations[1].GetTitle() != "app-2" {
+		t.Errorf("Unexpected annotations: %+v", output.Annotations)
+	}
+}
+
+func TestBuildCheckRunOutput_NoChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "diff.md")
+	os.WriteFile(path, []byte("## Argo CD Diff Preview\n\nNo changes found\n"), 0644)
+
+	output, err := BuildCheckRunOutput(path)
+	if err != nil {
+		t.Fatalf("BuildCheckRunOutput failed: %v", err)
+	}
+
+	if output.Conclusion != "success" || output.Title != "No changes" {
+		t.Errorf("Expected success with no changes, got %q (%q)", output.Conclusion, output.Title)
+	}
+
+	if output.Text != "" || len(output.Annotations) != 0 {
+		t.Errorf("Expected no text and annotations, got %q and %d", output.Text, len(output.Annotations))
+	}
+}
+
+func TestBuildCheckRunOutput_HTMLInManifests(t *testing.T) {
+	content := "## Argo CD Diff Preview\n\n" +
+		"<details>\n<summary>web (apps/web.yaml)</summary>\n<br>\n\n```diff\n" +
+		" data:\n" +
+		"+  index.html: <details><summary>x (y)</summary></details>\n" +
+		"+  <summary>docs (docs/index.html)</summary>\n" +
+		"```\n\n</details>\n\n" +
+		"_Stats_:\n[Applications: 1]\n"
+	path := filepath.Join(t.TempDir(), "diff.md")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create diff file: %v", err)
+	}
+
+	output, err := BuildCheckRunOutput(path)
+	if err != nil {
+		t.Fatalf("BuildCheckRunOutput failed: %v", err)
+	}
+
+	if output.Title != "1 application(s) changed" {
+		t.Errorf("Unexpected title: %q", output.Title)
+	}
+	if len(output.Annotations) != 1 || output.Annotations[0].GetTitle() != "web" {
+		t.Errorf("Expected a single annotation for web, got %+v", output.Annotations)
+	}
+	if output.Summary+output.Text != content {
+		t.Errorf("Expected the summary and text to hold the whole diff, got %q and %q", output.Summary, output.Text)
+	}
+}
+
+func TestBuildCheckRunOutput_Split(t *testing.T) {
+	// About 300 KB of application sections
+	output, err := BuildCheckRunOutput(writeCheckRunDiff(t, 60, 130))
+	if err != nil {
+		t.Fatalf("BuildCheckRunOutput failed: %v", err)
+	}
+
+	if len(output.Summary) > MaxCheckRunOutputLength || len(output.Text) > MaxCheckRunOutputLength {
+		t.Errorf("Output exceeds limit: summary %d bytes, text %d bytes", len(output.Summary), len(output.Text))
+	}
+
+	if !strings.HasPrefix(output.Summary, "## Argo CD Diff Preview") || !strings.Contains(output.Summary, "<details>") {
+		t.Error("Summary should hold the first part of the split diff")
+	}
+
+	if strings.Contains(output.Summary+output.Text, "argocd-diff-preview-pr-comment:part=") {
+		t.Error("Check run output should not contain part markers")
+	}
+
+	if !strings.Contains(output.Text, "more part(s) were omitted") {
+		t.Error("Text should end with a truncation notice")
+	}
+
+	if len(output.Annotations) != 60 {
+		t.Errorf("Expected an annotation for each of the 60 applications, got %d", len(output.Annotations))
+	}
+}
+
+func TestPublishCheckRun_Create(t *testing.T) {
+	var created map[string]interface{}
+	var updates int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/repos/owner/repo/pulls/123"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"number": 123, "head": map[string]string{"sha": "abc123"}})
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/repos/owner/repo/commits/abc123/check-runs"):
+			if r.URL.Query().Get("check_name") != CheckRunName {
+				t.Errorf("Expected check_name filter %q, got %q", CheckRunName, r.URL.Query().Get("check_name"))
+			}
+			if r.URL.Query().Get("app_id") != "42" {
+				t.Errorf("Expected app_id filter 42, got %q", r.URL.Query().Get("app_id"))
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"total_count": 0, "check_runs": []interface{}{}})
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/repos/owner/repo/check-runs"):
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 7})
+		case r.Method == "PATCH" && strings.HasSuffix(r.URL.Path, "/repos/owner/repo/check-runs/7"):
+			updates++
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 7})
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := Config{Token: "test-token", RequestTimeout: 30 * time.Second}
+	testClient := newTestClient(t, server.URL, config)
+	testClient.appID = 42
+
+	output, err := BuildCheckRunOutput(writeCheckRunDiff(t, 60, 1))
+	if err != nil {
+		t.Fatalf("BuildCheckRunOutput failed: %v", err)
+	}
+
+	if err := testClient.PublishCheckRun("owner", "repo", 123, output, config, false); err != nil {
+		t.Fatalf("PublishCheckRun failed: %v", err)
+	}
+
+	if created["name"] != CheckRunName || created["head_sha"] != "abc123" || created["conclusion"] != "neutral" {
+		t.Errorf("Unexpected check run: %v", created)
+	}
+
+	annotations := created["output"].(map[string]interface{})["annotations"].([]interface{})
+	if len(annotations) != 50 {
+		t.Errorf("Expected the first 50 annotations on create, got %d", len(annotations))
+	}
+
+	if updates != 1 {
+		t.Errorf("Expected the remaining annotations in 1 update, got %d", updates)
+	}
+}
+
+func TestPublishCheckRun_UpdateExisting(t *testing.T) {
+	var updated map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/repos/owner/repo/pulls/123"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"number": 123, "head": map[string]string{"sha": "abc123"}})
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/repos/owner/repo/commits/abc123/check-runs"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"total_count": 1, "check_runs": []map[string]interface{}{
+				{"id": 8, "name": CheckRunName, "app": map[string]interface{}{"id": 99}},
+				{"id": 9, "name": CheckRunName, "app": map[string]interface{}{"id": 42}},
+			}})
+		case r.Method == "PATCH" && strings.HasSuffix(r.URL.Path, "/repos/owner/repo/check-runs/9"):
+			json.NewDecoder(r.Body).Decode(&updated)
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 9})
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := Config{Token: "test-token", RequestTimeout: 30 * time.Second}
+	testClient := newTestClient(t, server.URL, config)
+	testClient.appID = 42
+
+	output := CheckRunOutput{Title: "No changes", Summary: "header", Conclusion: "success"}
+	if err := testClient.PublishCheckRun("owner", "repo", 123, output, config, false); err != nil {
+		t.Fatalf("PublishCheckRun failed: %v", err)
+	}
+
+	if updated["conclusion"] != "success" || updated["status"] != "completed" {
+		t.Errorf("Unexpected update: %v", updated)
+	}
+}
+
+func TestPublishCheckRun_CreatesWhenNotOwned(t *testing.T) {
+	tests := []struct {
+		name      string
+		appID     int64
+		wantLists int
+	}{
+		{
+			name:      "check run of another app",
+			appID:     42,
+			wantLists: 1,
+		},
+		{
+			name:      "token without a known app",
+			appID:     0,
+			wantLists: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lists, creates int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+
+				switch {
+				case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/repos/owner/repo/pulls/123"):
+					json.NewEncoder(w).Encode(map[string]interface{}{"number": 123, "head": map[string]string{"sha": "abc123"}})
+				case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/repos/owner/repo/commits/abc123/check-runs"):
+					lists++
+					json.NewEncoder(w).Encode(map[string]interface{}{"total_count": 1, "check_runs": []map[string]interface{}{
+						{"id": 8, "name": CheckRunName, "app": map[string]interface{}{"id": 99}},
+					}})
+				case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/repos/owner/repo/check-runs"):
+					creates++
+					w.WriteHeader(http.StatusCreated)
+					json.NewEncoder(w).Encode(map[string]interface{}{"id": 7})
+				default:
+					t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			config := Config{Token: "test-token", RequestTimeout: 30 * time.Second}
+			testClient := newTestClient(t, server.URL, config)
+			testClient.appID = tt.appID
+
+			output := CheckRunOutput{Title: "No changes", Summary: "header", Conclusion: "success"}
+			if err := testClient.PublishCheckRun("owner", "repo", 123, output, config, false); err != nil {
+				t.Fatalf("PublishCheckRun failed: %v", err)
+			}
+
+			if lists != tt.wantLists {
+				t.Errorf("Expected %d check run lookup(s), got %d", tt.wantLists, lists)
+			}
+			if creates != 1 {
+				t.Errorf("Expected a new check run, got %d create(s)", creates)
+			}
+		})
+	}
+}
@@ -4,41 +4,147 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/apistats"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
 	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
 	"github.com/google/go-github/v69/github"
 )
 
 // Client represents a GitHub API client
 type Client struct {
-	client *github.Client
+	client  *github.Client
+	graphql *graphQLClient
+	// appID is the ID of the GitHub App the client authenticates as, or 0
+	// when authenticating with a token
+	appID int64
 }
 
+// DefaultAPIURL is the REST API URL of github.com
+const DefaultAPIURL = "https://api.github.com"
+
 // Config holds configuration for GitHub client
 type Config struct {
-	Token          string
+	Token string
+	// Credentials provides the token for every request. When nil, Token is
+	// used as a static token
+	Credentials CredentialProvider
+	// APIURL is the REST API URL, e.g. https://ghe.example.com/api/v3 for
+	// GitHub Enterprise Server. Empty means api.github.com
+	APIURL string
+	// UploadURL is the uploads API URL. Derived from APIURL when empty
+	UploadURL      string
 	MaxRetries     int
 	RetryDelay     time.Duration
 	BackoffFactor  float64
 	RequestTimeout time.Duration
 }
 
-// NewClient creates a new GitHub client
-func NewClient(config Config) *Client {
+// NewClient creates a new GitHub client. An APIURL other than api.github.com
+// configures it for GitHub Enterprise
+func NewClient(config Config) (*Client, error) {
+	credentials := config.Credentials
+	if credentials == nil {
+		credentials = StaticToken(config.Token)
+	}
+
+	transport := &authTransport{credentials: credentials}
 	httpClient := &http.Client{
-		Timeout: config.RequestTimeout,
+		Timeout:   config.RequestTimeout,
+		Transport: transport,
 	}
 
-	return &Client{
-		client: github.NewClient(httpClient).WithAuthToken(config.Token),
+	client, err := newGitHubClient(httpClient, config)
+	if err != nil {
+		return nil, err
+	}
+	transport.hosts = []string{client.BaseURL.Host, client.UploadURL.Host}
+
+	c := &Client{
+		client:  client,
+		graphql: &graphQLClient{client: client},
+	}
+	if app, ok := credentials.(*AppCredentials); ok {
+		c.appID = app.AppID()
 	}
+	return c, nil
 }
 
-// PostPRComment posts a comment to a GitHub PR with retry logic
-func (c *Client) PostPRComment(owner, repo string, prNumber int, comment string, config Config, dryRun bool) error {
+// newGitHubClient creates a go-github client using the API URLs from config
+func newGitHubClient(httpClient *http.Client, config Config) (*github.Client, error) {
+	client := github.NewClient(httpClient)
+
+	if config.APIURL == "" || strings.TrimSuffix(config.APIURL, "/") == DefaultAPIURL {
+		return client, nil
+	}
+
+	uploadURL := config.UploadURL
+	if uploadURL == "" {
+		derived, err := defaultUploadURL(config.APIURL)
+		if err != nil {
+			return nil, err
+		}
+		uploadURL = derived.String()
+	}
+
+	client, err := client.WithEnterpriseURLs(config.APIURL, uploadURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub API URL: %w", err)
+	}
+
+	// go-github appends /api/uploads/ to upload hosts without an api.
+	// prefix, which is wrong for a derived uploads. subdomain
+	if config.UploadURL == "" {
+		client.UploadURL, _ = defaultUploadURL(config.APIURL)
+	}
+
+	return client, nil
+}
+
+// defaultUploadURL returns the uploads API URL matching an API URL.
+// GitHub Enterprise Server serves it at /api/uploads, and hosts with an
+// api. subdomain (e.g. GHE.com) at the uploads. subdomain
+func defaultUploadURL(apiURL string) (*url.URL, error) {
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid GitHub API URL: %s", apiURL)
+	}
+
+	if host, ok := strings.CutPrefix(u.Host, "api."); ok {
+		return &url.URL{Scheme: u.Scheme, Host: "uploads." + host, Path: "/"}, nil
+	}
+	return &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/api/uploads/"}, nil
+}
+
+// WebHost returns the host serving the web UI for an API URL, used to
+// recognise PR URLs. An empty API URL means github.com
+func WebHost(apiURL string) (string, error) {
+	if apiURL == "" {
+		return "github.com", nil
+	}
+
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid GitHub API URL: %s", apiURL)
+	}
+
+	return strings.TrimPrefix(u.Host, "api."), nil
+}
+
+// MaxCommentLength is GitHub's limit, in characters, for the body of a single
+// comment
+const MaxCommentLength = 65536
+
+// Comment represents an existing comment on a GitHub PR
+type Comment = comments.Comment
+
+// PostPRComment posts a comment to a GitHub PR with retry logic and returns
+// the created comment. In dry-run mode the returned comment is empty
+func (c *Client) PostPRComment(owner, repo string, prNumber int, comment string, config Config, dryRun bool) (Comment, error) {
 	log := logger.GetLogger()
 	ctx := context.Background()
 
@@ -46,9 +152,131 @@ func (c *Client) PostPRComment(owner, repo string, prNumber int, comment string,
 		log.Infof("[DRY RUN] Would post comment to PR #%d in %s/%s", prNumber, owner, repo)
 		log.Infof("[DRY RUN] Comment length: %d bytes", len(comment))
 		log.Debugf("[DRY RUN] Comment content:\n%s", comment)
+		return Comment{}, nil
+	}
+
+	var created *github.IssueComment
+	err := withRetry(config, "post comment", func() (*github.Response, error) {
+		issueComment := &github.IssueComment{
+			Body: github.String(comment),
+		}
+		var resp *github.Response
+		var err error
+		created, resp, err = c.client.Issues.CreateComment(ctx, owner, repo, prNumber, issueComment)
+		return resp, err
+	})
+	if err != nil {
+		return Comment{}, err
+	}
+
+	log.Infof("Successfully posted comment to PR #%d", prNumber)
+	return toComment(created), nil
+}
+
+// toComment converts an issue comment returned by the API
+func toComment(ic *github.IssueComment) Comment {
+	return Comment{
+		ID:     ic.GetID(),
+		NodeID: ic.GetNodeID(),
+		Body:   ic.GetBody(),
+		Author: ic.GetUser().GetLogin(),
+		URL:    ic.GetHTMLURL(),
+	}
+}
+
+// ListPRComments returns all comments on a GitHub PR, following pagination
+func (c *Client) ListPRComments(owner, repo string, prNumber int, config Config) ([]Comment, error) {
+	ctx := context.Background()
+
+	opts := &github.IssueListCommentsOptions{
+		Sort:        github.String("created"),
+		Direction:   github.String("asc"),
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	var comments []Comment
+	for {
+		var page []*github.IssueComment
+		var nextPage int
+		err := withRetry(config, "list comments", func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			page, resp, err = c.client.Issues.ListComments(ctx, owner, repo, prNumber, opts)
+			if resp != nil {
+				nextPage = resp.NextPage
+			}
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ic := range page {
+			comments = append(comments, toComment(ic))
+		}
+
+		if nextPage == 0 {
+			break
+		}
+		opts.Page = nextPage
+	}
+
+	return comments, nil
+}
+
+// UpdatePRComment replaces the body of an existing PR comment with retry logic
+func (c *Client) UpdatePRComment(owner, repo string, commentID int64, comment string, config Config, dryRun bool) error {
+	log := logger.GetLogger()
+	ctx := context.Background()
+
+	if dryRun {
+		log.Infof("[DRY RUN] Would update comment %d in %s/%s", commentID, owner, repo)
+		log.Infof("[DRY RUN] Comment length: %d bytes", len(comment))
+		log.Debugf("[DRY RUN] Comment content:\n%s", comment)
 		return nil
 	}
 
+	err := withRetry(config, "update comment", func() (*github.Response, error) {
+		issueComment := &github.IssueComment{
+			Body: github.String(comment),
+		}
+		_, resp, err := c.client.Issues.EditComment(ctx, owner, repo, commentID, issueComment)
+		return resp, err
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Successfully updated comment %d", commentID)
+	return nil
+}
+
+// DeletePRComment deletes an existing PR comment with retry logic
+func (c *Client) DeletePRComment(owner, repo string, commentID int64, config Config, dryRun bool) error {
+	log := logger.GetLogger()
+	ctx := context.Background()
+
+	if dryRun {
+		log.Infof("[DRY RUN] Would delete comment %d in %s/%s", commentID, owner, repo)
+		return nil
+	}
+
+	err := withRetry(config, "delete comment", func() (*github.Response, error) {
+		return c.client.Issues.DeleteComment(ctx, owner, repo, commentID)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Successfully deleted comment %d", commentID)
+	return nil
+}
+
+// withRetry runs a GitHub API call, waiting for rate limit resets and
+// retrying failed attempts with backoff as configured
+func withRetry(config Config, action string, call func() (*github.Response, error)) error {
+	log := logger.GetLogger()
+
 	var lastErr error
 	for attempt := 0; attempt <= config.MaxRetries; attempt++ {
 		if attempt > 0 {
@@ -58,11 +286,15 @@ func (c *Client) PostPRComment(owner, repo string, prNumber int, comment string,
 			time.Sleep(delay)
 		}
 
-		issueComment := &github.IssueComment{
-			Body: github.String(comment),
+		apistats.RecordRequest(attempt > 0)
+		resp, err := call()
+		if resp != nil && resp.Rate.Limit > 0 {
+			apistats.RecordRateLimit(apistats.RateLimit{
+				Limit:     resp.Rate.Limit,
+				Remaining: resp.Rate.Remaining,
+				Reset:     resp.Rate.Reset.UTC(),
+			})
 		}
-
-		_, resp, err := c.client.Issues.CreateComment(ctx, owner, repo, prNumber, issueComment)
 		if err != nil {
 			lastErr = err
 
@@ -72,6 +304,7 @@ func (c *Client) PostPRComment(owner, repo string, prNumber int, comment string,
 					waitTime := time.Until(resp.Rate.Reset.Time)
 					if waitTime > 0 {
 						log.Warnf("Rate limited. Waiting %v until reset at %v", waitTime, resp.Rate.Reset.Time)
+						apistats.RecordRateLimitWait(waitTime)
 						time.Sleep(waitTime + time.Second) // Add 1 second buffer
 						continue
 					}
@@ -80,7 +313,7 @@ func (c *Client) PostPRComment(owner, repo string, prNumber int, comment string,
 
 			// For other errors, only retry if we haven't exhausted attempts
 			if attempt < config.MaxRetries {
-				log.Warnf("Failed to post comment: %v", err)
+				log.Warnf("Failed to %s: %v", action, err)
 			}
 			continue
 		}
@@ -90,20 +323,26 @@ func (c *Client) PostPRComment(owner, repo string, prNumber int, comment string,
 			log.Debugf("Rate limit remaining: %d, resets at: %v", resp.Rate.Remaining, resp.Rate.Reset.Time)
 		}
 
-		log.Infof("Successfully posted comment to PR #%d", prNumber)
 		return nil
 	}
 
-	return fmt.Errorf("failed to post comment after %d retries: %w", config.MaxRetries, lastErr)
+	return fmt.Errorf("failed to %s after %d retries: %w", action, config.MaxRetries, lastErr)
 }
 
 // ValidatePRReference validates and parses a GitHub PR reference
 // Accepts formats: owner/repo#123, https://github.com/owner/repo/pull/123
 func ValidatePRReference(ref string) (owner, repo string, prNumber int, err error) {
-	// Handle URL format: https://github.com/owner/repo/pull/123
-	if strings.HasPrefix(ref, "https://github.com/") || strings.HasPrefix(ref, "http://github.com/") {
-		ref = strings.TrimPrefix(ref, "https://github.com/")
-		ref = strings.TrimPrefix(ref, "http://github.com/")
+	return ValidatePRReferenceForHost(ref, "github.com")
+}
+
+// ValidatePRReferenceForHost validates and parses a GitHub PR reference whose
+// URL form points at the given host, e.g. a GitHub Enterprise Server instance
+// Accepts formats: owner/repo#123, https://host/owner/repo/pull/123
+func ValidatePRReferenceForHost(ref, host string) (owner, repo string, prNumber int, err error) {
+	// Handle URL format: https://host/owner/repo/pull/123
+	if strings.HasPrefix(ref, "https://"+host+"/") || strings.HasPrefix(ref, "http://"+host+"/") {
+		ref = strings.TrimPrefix(ref, "https://"+host+"/")
+		ref = strings.TrimPrefix(ref, "http://"+host+"/")
 
 		parts := strings.Split(ref, "/")
 		if len(parts) >= 4 && parts[2] == "pull" {
@@ -140,5 +379,5 @@ func ValidatePRReference(ref string) (owner, repo string, prNumber int, err erro
 		return owner, repo, prNumber, nil
 	}
 
-	return "", "", 0, fmt.Errorf("invalid PR reference format, expected owner/repo#123 or https://github.com/owner/repo/pull/123")
+	return "", "", 0, fmt.Errorf("invalid PR reference format, expected owner/repo#123 or https://%s/owner/repo/pull/123", host)
 }
@@ -17,7 +17,10 @@ func TestNewClient(t *testing.T) {
 		RequestTimeout: 30 * time.Second,
 	}
 
-	client := NewClient(config)
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
 
 	if client == nil {
 		t.Fatal("NewClient returned nil")
@@ -28,15 +31,118 @@ func TestNewClient(t *testing.T) {
 	}
 }
 
+func TestNewClient_Enterprise(t *testing.T) {
+	tests := []struct {
+		name              string
+		config            Config
+		expectedBaseURL   string
+		expectedUploadURL string
+		shouldError       bool
+	}{
+		{
+			name:              "Default",
+			config:            Config{},
+			expectedBaseURL:   "https://api.github.com/",
+			expectedUploadURL: "https://uploads.github.com/",
+		},
+		{
+			name:              "Explicit api.github.com",
+			config:            Config{APIURL: "https://api.github.com/"},
+			expectedBaseURL:   "https://api.github.com/",
+			expectedUploadURL: "https://uploads.github.com/",
+		},
+		{
+			name:              "GitHub Enterprise Server",
+			config:            Config{APIURL: "https://ghe.example.com/api/v3"},
+			expectedBaseURL:   "https://ghe.example.com/api/v3/",
+			expectedUploadURL: "https://ghe.example.com/api/uploads/",
+		},
+		{
+			name:              "GitHub Enterprise Server host only",
+			config:            Config{APIURL: "https://ghe.example.com"},
+			expectedBaseURL:   "https://ghe.example.com/api/v3/",
+			expectedUploadURL: "https://ghe.example.com/api/uploads/",
+		},
+		{
+			name:              "GitHub Enterprise Cloud with data residency",
+			config:            Config{APIURL: "https://api.octocorp.ghe.com"},
+			expectedBaseURL:   "https://api.octocorp.ghe.com/",
+			expectedUploadURL: "https://uploads.octocorp.ghe.com/",
+		},
+		{
+			name:              "Explicit upload URL",
+			config:            Config{APIURL: "https://ghe.example.com/api/v3", UploadURL: "https://uploads.ghe.example.com/api/uploads"},
+			expectedBaseURL:   "https://ghe.example.com/api/v3/",
+			expectedUploadURL: "https://uploads.ghe.example.com/api/uploads/",
+		},
+		{
+			name:        "Invalid API URL",
+			config:      Config{APIURL: "not a url"},
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(tt.config)
+
+			if tt.shouldError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if client.client.BaseURL.String() != tt.expectedBaseURL {
+				t.Errorf("Expected base URL %s, got %s", tt.expectedBaseURL, client.client.BaseURL)
+			}
+
+			if client.client.UploadURL.String() != tt.expectedUploadURL {
+				t.Errorf("Expected upload URL %s, got %s", tt.expectedUploadURL, client.client.UploadURL)
+			}
+		})
+	}
+}
+
+func TestWebHost(t *testing.T) {
+	tests := []struct {
+		apiURL   string
+		expected string
+	}{
+		{apiURL: "", expected: "github.com"},
+		{apiURL: "https://api.github.com", expected: "github.com"},
+		{apiURL: "https://ghe.example.com/api/v3", expected: "ghe.example.com"},
+		{apiURL: "https://ghe.example.com:8443/api/v3", expected: "ghe.example.com:8443"},
+		{apiURL: "https://api.octocorp.ghe.com", expected: "octocorp.ghe.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.apiURL, func(t *testing.T) {
+			host, err := WebHost(tt.apiURL)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if host != tt.expected {
+				t.Errorf("Expected host %s, got %s", tt.expected, host)
+			}
+		})
+	}
+}
+
 func TestPostPRComment_DryRun(t *testing.T) {
 	config := Config{
 		Token:          "test-token",
 		RequestTimeout: 30 * time.Second,
 	}
 
-	client := NewClient(config)
+	client, _ := NewClient(config)
 
-	err := client.PostPRComment("owner", "repo", 123, "Test comment", config, true)
+	_, err := client.PostPRComment("owner", "repo", 123, "Test comment", config, true)
 	if err != nil {
 		t.Errorf("DryRun should not return error, got: %v", err)
 	}
@@ -67,8 +173,10 @@ func TestPostPRComment_Success(t *testing.T) {
 		w.WriteHeader(http.StatusCreated)
 
 		response := map[string]interface{}{
-			"id":   123,
-			"body": "Test comment",
+			"id":       123,
+			"node_id":  "IC_123",
+			"body":     "Test comment",
+			"html_url": "https://github.com/owner/repo/pull/123#issuecomment-123",
 		}
 		json.NewEncoder(w).Encode(response)
 	}))
@@ -89,9 +197,141 @@ func TestPostPRComment_Success(t *testing.T) {
 	// Override the base URL to point to our test server
 	testClient.client, _ = testClient.client.WithEnterpriseURLs(server.URL, server.URL)
 
-	err := testClient.PostPRComment("owner", "repo", 123, "Test comment", config, false)
+	comment, err := testClient.PostPRComment("owner", "repo", 123, "Test comment", config, false)
+	if err != nil {
+		t.Fatalf("PostPRComment failed: %v", err)
+	}
+
+	if comment.ID != 123 || comment.NodeID != "IC_123" || comment.URL != "https://github.com/owner/repo/pull/123#issuecomment-123" {
+		t.Errorf("Unexpected created comment: %+v", comment)
+	}
+}
+
+// newTestClient returns a client whose API calls go to the given test server
+func newTestClient(t *testing.T, serverURL string, config Config) *Client {
+	t.Helper()
+
+	httpClient := &http.Client{Timeout: config.RequestTimeout}
+	ghClient, err := github.NewClient(httpClient).WithAuthToken(config.Token).WithEnterpriseURLs(serverURL, serverURL)
 	if err != nil {
-		t.Errorf("PostPRComment failed: %v", err)
+		t.Fatalf("Failed to configure test client: %v", err)
+	}
+	return &Client{client: ghClient, graphql: &graphQLClient{client: ghClient}}
+}
+
+func TestListPRComments_Pagination(t *testing.T) {
+	var serverURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected GET request, got %s", r.Method)
+		}
+
+		if !strings.Contains(r.URL.Path, "/repos/owner/repo/issues/123/comments") {
+			t.Errorf("Expected path to contain '/repos/owner/repo/issues/123/comments', got %s", r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		var response []map[string]interface{}
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `<`+serverURL+`/api/v3/repos/owner/repo/issues/123/comments?page=2>; rel="next"`)
+			response = []map[string]interface{}{
+				{"id": 1, "body": "first", "user": map[string]interface{}{"login": "bot"}},
+			}
+		} else {
+			response = []map[string]interface{}{
+				{"id": 2, "body": "second", "user": map[string]interface{}{"login": "someone"}},
+			}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+	serverURL = server.URL
+
+	config := Config{Token: "test-token", RequestTimeout: 30 * time.Second}
+	testClient := newTestClient(t, server.URL, config)
+
+	comments, err := testClient.ListPRComments("owner", "repo", 123, config)
+	if err != nil {
+		t.Fatalf("ListPRComments failed: %v", err)
+	}
+
+	if len(comments) != 2 {
+		t.Fatalf("Expected 2 comments, got %d", len(comments))
+	}
+
+	if comments[0].ID != 1 || comments[0].Body != "first" || comments[0].Author != "bot" {
+		t.Errorf("Unexpected first comment: %+v", comments[0])
+	}
+
+	if comments[1].ID != 2 || comments[1].Body != "second" || comments[1].Author != "someone" {
+		t.Errorf("Unexpected second comment: %+v", comments[1])
+	}
+}
+
+func TestUpdatePRComment_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" {
+			t.Errorf("Expected PATCH request, got %s", r.Method)
+		}
+
+		if !strings.Contains(r.URL.Path, "/repos/owner/repo/issues/comments/42") {
+			t.Errorf("Expected path to contain '/repos/owner/repo/issues/comments/42', got %s", r.URL.Path)
+		}
+
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["body"] != "Updated comment" {
+			t.Errorf("Expected body 'Updated comment', got %v", body["body"])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 42, "body": "Updated comment"})
+	}))
+	defer server.Close()
+
+	config := Config{Token: "test-token", RequestTimeout: 30 * time.Second}
+	testClient := newTestClient(t, server.URL, config)
+
+	err := testClient.UpdatePRComment("owner", "repo", 42, "Updated comment", config, false)
+	if err != nil {
+		t.Errorf("UpdatePRComment failed: %v", err)
+	}
+}
+
+func TestDeletePRComment_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Errorf("Expected DELETE request, got %s", r.Method)
+		}
+
+		if !strings.Contains(r.URL.Path, "/repos/owner/repo/issues/comments/42") {
+			t.Errorf("Expected path to contain '/repos/owner/repo/issues/comments/42', got %s", r.URL.Path)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	config := Config{Token: "test-token", RequestTimeout: 30 * time.Second}
+	testClient := newTestClient(t, server.URL, config)
+
+	err := testClient.DeletePRComment("owner", "repo", 42, config, false)
+	if err != nil {
+		t.Errorf("DeletePRComment failed: %v", err)
+	}
+}
+
+func TestUpdateAndDeletePRComment_DryRun(t *testing.T) {
+	config := Config{Token: "test-token", RequestTimeout: 30 * time.Second}
+	client, _ := NewClient(config)
+
+	if err := client.UpdatePRComment("owner", "repo", 42, "Test comment", config, true); err != nil {
+		t.Errorf("DryRun update should not return error, got: %v", err)
+	}
+
+	if err := client.DeletePRComment("owner", "repo", 42, config, true); err != nil {
+		t.Errorf("DryRun delete should not return error, got: %v", err)
 	}
 }
 
@@ -177,3 +417,63 @@ func TestValidatePRReference(t *testing.T) {
 		})
 	}
 }
+
+func TestValidatePRReferenceForHost(t *testing.T) {
+	tests := []struct {
+		name          string
+		ref           string
+		expectedOwner string
+		expectedRepo  string
+		expectedPR    int
+		shouldError   bool
+	}{
+		{
+			name:          "Enterprise URL",
+			ref:           "https://ghe.example.com/platform/gitops/pull/42",
+			expectedOwner: "platform",
+			expectedRepo:  "gitops",
+			expectedPR:    42,
+		},
+		{
+			name:          "Enterprise URL with trailing path",
+			ref:           "https://ghe.example.com/platform/gitops/pull/42/files",
+			expectedOwner: "platform",
+			expectedRepo:  "gitops",
+			expectedPR:    42,
+		},
+		{
+			name:          "Short format",
+			ref:           "platform/gitops#42",
+			expectedOwner: "platform",
+			expectedRepo:  "gitops",
+			expectedPR:    42,
+		},
+		{
+			name:        "github.com URL is not the configured host",
+			ref:         "https://github.com/platform/gitops/pull/42",
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, repo, prNumber, err := ValidatePRReferenceForHost(tt.ref, "ghe.example.com")
+
+			if tt.shouldError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+
+			if owner != tt.expectedOwner || repo != tt.expectedRepo || prNumber != tt.expectedPR {
+				t.Errorf("Expected %s/%s#%d, got %s/%s#%d", tt.expectedOwner, tt.expectedRepo, tt.expectedPR, owner, repo, prNumber)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/google/go-github/v69/github"
+)
+
+// graphQLClient sends GraphQL requests through the REST client so that
+// authentication, rate limit parsing and retries are shared with it
+type graphQLClient struct {
+	client *github.Client
+}
+
+// graphQLRequest is the JSON body of a GraphQL request
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// graphQLError is a single entry of the errors list in a GraphQL response
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+// endpoint returns the GraphQL URL matching the REST client's base URL.
+// api.github.com serves GraphQL at /graphql, GitHub Enterprise Server at /api/graphql
+func (g *graphQLClient) endpoint() string {
+	ref := "graphql"
+	if strings.HasSuffix(g.client.BaseURL.Path, "/api/v3/") {
+		ref = "../graphql"
+	}
+
+	endpoint, err := g.client.BaseURL.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return endpoint.String()
+}
+
+// do runs a GraphQL query or mutation and decodes its data into out
+func (g *graphQLClient) do(config Config, action, query string, variables map[string]interface{}, out interface{}) error {
+	ctx := context.Background()
+
+	return withRetry(config, action, func() (*github.Response, error) {
+		req, err := g.client.NewRequest("POST", g.endpoint(), graphQLRequest{Query: query, Variables: variables})
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Data   interface{}    `json:"data"`
+			Errors []graphQLError `json:"errors"`
+		}
+		result.Data = out
+
+		resp, err := g.client.Do(ctx, req, &result)
+		if err != nil {
+			return resp, err
+		}
+
+		if len(result.Errors) > 0 {
+			messages := make([]string, 0, len(result.Errors))
+			for _, e := range result.Errors {
+				messages = append(messages, e.Message)
+			}
+			return resp, fmt.Errorf("GraphQL error: %s", strings.Join(messages, "; "))
+		}
+
+		return resp, nil
+	})
+}
+
+// GetAuthenticatedLogin returns the login of the user or app the token belongs to
+func (c *Client) GetAuthenticatedLogin(config Config) (string, error) {
+	var data struct {
+		Viewer struct {
+			Login string `json:"login"`
+		} `json:"viewer"`
+	}
+
+	err := c.graphql.do(config, "get authenticated user", `query { viewer { login } }`, nil, &data)
+	if err != nil {
+		return "", err
+	}
+
+	return data.Viewer.Login, nil
+}
+
+// MinimizePRComments hides the given comments as outdated using the GraphQL
+// minimizeComment mutation. Comments that are already minimized are skipped.
+// Returns the number of comments that were minimized
+func (c *Client) MinimizePRComments(comments []Comment, config Config, dryRun bool) (int, error) {
+	log := logger.GetLogger()
+
+	if len(comments) == 0 {
+		return 0, nil
+	}
+
+	if dryRun {
+		for _, comment := range comments {
+			log.Infof("[DRY RUN] Would minimize comment %d as outdated", comment.ID)
+		}
+		return len(comments), nil
+	}
+
+	minimized, err := c.minimizedNodeIDs(comments, config)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, comment := range comments {
+		if minimized[comment.NodeID] {
+			log.Debugf("Comment %d is already minimized", comment.ID)
+			continue
+		}
+
+		mutation := `mutation($id: ID!) {
+  minimizeComment(input: {subjectId: $id, classifier: OUTDATED}) {
+    minimizedComment { isMinimized }
+  }
+}`
+		err := c.graphql.do(config, "minimize comment", mutation, map[string]interface{}{"id": comment.NodeID}, nil)
+		if err != nil {
+			return count, err
+		}
+
+		log.Infof("Successfully minimized comment %d as outdated", comment.ID)
+		count++
+	}
+
+	return count, nil
+}
+
+// minimizedNodeIDs returns the set of node IDs, among the given comments,
+// that are already minimized
+func (c *Client) minimizedNodeIDs(comments []Comment, config Config) (map[string]bool, error) {
+	// The nodes query accepts at most 100 IDs per request
+	const batchSize = 100
+
+	minimized := make(map[string]bool)
+	for start := 0; start < len(comments); start += batchSize {
+		end := min(start+batchSize, len(comments))
+
+		ids := make([]string, 0, end-start)
+		for _, comment := range comments[start:end] {
+			ids = append(ids, comment.NodeID)
+		}
+
+		var data struct {
+			Nodes []struct {
+				ID          string `json:"id"`
+				IsMinimized bool   `json:"isMinimized"`
+			} `json:"nodes"`
+		}
+
+		query := `query($ids: [ID!]!) {
+  nodes(ids: $ids) {
+    ... on IssueComment { id isMinimized }
+  }
+}`
+		if err := c.graphql.do(config, "query comment state", query, map[string]interface{}{"ids": ids}, &data); err != nil {
+			return nil, err
+		}
+
+		for _, node := range data.Nodes {
+			if node.IsMinimized {
+				minimized[node.ID] = true
+			}
+		}
+	}
+
+	return minimized, nil
+}
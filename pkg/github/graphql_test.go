@@ -0,0 +1,150 @@
+package github
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+)
+
+func TestGraphQLEndpoint(t *testing.T) {
+	g := &graphQLClient{client: github.NewClient(nil)}
+	if endpoint := g.endpoint(); endpoint != "https://api.github.com/graphql" {
+		t.Errorf("Expected endpoint %q, got %q", "https://api.github.com/graphql", endpoint)
+	}
+
+	enterprise, err := github.NewClient(nil).WithEnterpriseURLs("https://ghe.example.com/api/v3/", "https://ghe.example.com/api/uploads/")
+	if err != nil {
+		t.Fatalf("Failed to configure enterprise client: %v", err)
+	}
+
+	g = &graphQLClient{client: enterprise}
+	if endpoint := g.endpoint(); endpoint != "https://ghe.example.com/api/graphql" {
+		t.Errorf("Expected endpoint %q, got %q", "https://ghe.example.com/api/graphql", endpoint)
+	}
+}
+
+func TestGetAuthenticatedLogin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/graphql" {
+			t.Errorf("Expected path '/api/graphql', got %s", r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"viewer": map[string]interface{}{"login": "diff-bot"}},
+		})
+	}))
+	defer server.Close()
+
+	config := Config{Token: "test-token", RequestTimeout: 30 * time.Second}
+	testClient := newTestClient(t, server.URL, config)
+
+	login, err := testClient.GetAuthenticatedLogin(config)
+	if err != nil {
+		t.Fatalf("GetAuthenticatedLogin failed: %v", err)
+	}
+
+	if login != "diff-bot" {
+		t.Errorf("Expected login 'diff-bot', got %q", login)
+	}
+}
+
+func TestGraphQL_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"errors": []map[string]interface{}{{"message": "Resource not accessible by integration"}},
+		})
+	}))
+	defer server.Close()
+
+	config := Config{Token: "test-token", RequestTimeout: 30 * time.Second}
+	testClient := newTestClient(t, server.URL, config)
+
+	_, err := testClient.GetAuthenticatedLogin(config)
+	if err == nil {
+		t.Fatal("Expected error but got none")
+	}
+
+	if !strings.Contains(err.Error(), "Resource not accessible by integration") {
+		t.Errorf("Expected error to contain the GraphQL message, got: %v", err)
+	}
+}
+
+func TestMinimizePRComments(t *testing.T) {
+	var minimizedIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req graphQLRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if strings.Contains(req.Query, "nodes(ids:") {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"nodes": []map[string]interface{}{
+						{"id": "IC_1", "isMinimized": true},
+						{"id": "IC_2", "isMinimized": false},
+					},
+				},
+			})
+			return
+		}
+
+		if strings.Contains(req.Query, "minimizeComment") {
+			if !strings.Contains(req.Query, "classifier: OUTDATED") {
+				t.Errorf("Expected OUTDATED classifier in mutation, got: %s", req.Query)
+			}
+			minimizedIDs = append(minimizedIDs, req.Variables["id"].(string))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"minimizeComment": map[string]interface{}{"minimizedComment": map[string]interface{}{"isMinimized": true}},
+				},
+			})
+			return
+		}
+
+		t.Errorf("Unexpected query: %s", req.Query)
+	}))
+	defer server.Close()
+
+	config := Config{Token: "test-token", RequestTimeout: 30 * time.Second}
+	testClient := newTestClient(t, server.URL, config)
+
+	comments := []Comment{
+		{ID: 1, NodeID: "IC_1"},
+		{ID: 2, NodeID: "IC_2"},
+	}
+
+	count, err := testClient.MinimizePRComments(comments, config, false)
+	if err != nil {
+		t.Fatalf("MinimizePRComments failed: %v", err)
+	}
+
+	if count != 1 {
+		t.Errorf("Expected 1 comment minimized, got %d", count)
+	}
+
+	if len(minimizedIDs) != 1 || minimizedIDs[0] != "IC_2" {
+		t.Errorf("Expected only IC_2 to be minimized, got %v", minimizedIDs)
+	}
+}
+
+func TestMinimizePRComments_DryRun(t *testing.T) {
+	config := Config{Token: "test-token", RequestTimeout: 30 * time.Second}
+	client, _ := NewClient(config)
+
+	count, err := client.MinimizePRComments([]Comment{{ID: 1, NodeID: "IC_1"}}, config, true)
+	if err != nil {
+		t.Errorf("DryRun should not return error, got: %v", err)
+	}
+
+	if count != 1 {
+		t.Errorf("Expected 1 comment reported in dry-run, got %d", count)
+	}
+}
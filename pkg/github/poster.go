@@ -0,0 +1,54 @@
+package github
+
+import "github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
+
+// PRPoster manages the comments of a single GitHub PR. It implements
+// comments.Poster, comments.Minimizer and comments.AuthorLookup
+type PRPoster struct {
+	client   *Client
+	owner    string
+	repo     string
+	prNumber int
+	config   Config
+}
+
+// NewPRPoster creates a poster for the given PR
+func NewPRPoster(client *Client, owner, repo string, prNumber int, config Config) *PRPoster {
+	return &PRPoster{
+		client:   client,
+		owner:    owner,
+		repo:     repo,
+		prNumber: prNumber,
+		config:   config,
+	}
+}
+
+// PostComment creates a new comment on the PR
+func (p *PRPoster) PostComment(body string) (comments.Comment, error) {
+	return p.client.PostPRComment(p.owner, p.repo, p.prNumber, body, p.config, false)
+}
+
+// ListComments returns all comments on the PR
+func (p *PRPoster) ListComments() ([]comments.Comment, error) {
+	return p.client.ListPRComments(p.owner, p.repo, p.prNumber, p.config)
+}
+
+// UpdateComment replaces the body of an existing PR comment
+func (p *PRPoster) UpdateComment(id int64, body string) error {
+	return p.client.UpdatePRComment(p.owner, p.repo, id, body, p.config, false)
+}
+
+// DeleteComment deletes an existing PR comment
+func (p *PRPoster) DeleteComment(id int64) error {
+	return p.client.DeletePRComment(p.owner, p.repo, id, p.config, false)
+}
+
+// MinimizeComments hides the given comments as outdated
+func (p *PRPoster) MinimizeComments(outdated []comments.Comment) (int, error) {
+	return p.client.MinimizePRComments(outdated, p.config, false)
+}
+
+// AuthenticatedLogin returns the login the token belongs to
+func (p *PRPoster) AuthenticatedLogin() (string, error) {
+	return p.client.GetAuthenticatedLogin(p.config)
+}
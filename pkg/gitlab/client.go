@@ -0,0 +1,236 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/rest"
+)
+
+// DefaultBaseURL is the URL of gitlab.com
+const DefaultBaseURL = "https://gitlab.com"
+
+// MaxNoteLength is GitLab's limit, in characters, for the body of a single note
+const MaxNoteLength = 1000000
+
+// Client represents a GitLab API client
+type Client struct {
+	rest    *rest.Client
+	baseURL string
+}
+
+// Config holds configuration for GitLab client
+type Config struct {
+	Token          string
+	BaseURL        string
+	MaxRetries     int
+	RetryDelay     time.Duration
+	BackoffFactor  float64
+	RequestTimeout time.Duration
+}
+
+// note is the subset of the GitLab note resource used by this client
+type note struct {
+	ID     int64  `json:"id"`
+	Body   string `json:"body"`
+	System bool   `json:"system"`
+	Author struct {
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+// NewClient creates a new GitLab client
+func NewClient(config Config) *Client {
+	baseURL := strings.TrimSuffix(config.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	restConfig := rest.Config{
+		MaxRetries:     config.MaxRetries,
+		RetryDelay:     config.RetryDelay,
+		BackoffFactor:  config.BackoffFactor,
+		RequestTimeout: config.RequestTimeout,
+	}
+
+	return &Client{
+		rest: rest.NewClient(baseURL+"/api/v4", restConfig, func(req *http.Request) {
+			req.Header.Set("PRIVATE-TOKEN", config.Token)
+		}),
+		baseURL: baseURL,
+	}
+}
+
+// notesPath returns the API path of the notes of a merge request
+func notesPath(project string, mrIID int) string {
+	return fmt.Sprintf("/projects/%s/merge_requests/%d/notes", url.PathEscape(project), mrIID)
+}
+
+// PostMRNote posts a note to a GitLab merge request and returns the created note
+func (c *Client) PostMRNote(project string, mrIID int, body string) (comments.Comment, error) {
+	log := logger.GetLogger()
+
+	var created note
+	_, err := c.rest.Do("POST", notesPath(project, mrIID), map[string]string{"body": body}, &created)
+	if err != nil {
+		return comments.Comment{}, fmt.Errorf("failed to post note: %w", err)
+	}
+
+	log.Infof("Successfully posted note to MR !%d", mrIID)
+	return c.toComment(project, mrIID, created), nil
+}
+
+// ListMRNotes returns all user notes on a GitLab merge request, oldest first.
+// System notes (e.g. "added 1 commit") are skipped
+func (c *Client) ListMRNotes(project string, mrIID int) ([]comments.Comment, error) {
+	var result []comments.Comment
+
+	page := "1"
+	for page != "" {
+		var notes []note
+		path := fmt.Sprintf("%s?sort=asc&order_by=created_at&per_page=100&page=%s", notesPath(project, mrIID), page)
+		header, err := c.rest.Do("GET", path, nil, &notes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list notes: %w", err)
+		}
+
+		for _, n := range notes {
+			if n.System {
+				continue
+			}
+			result = append(result, c.toComment(project, mrIID, n))
+		}
+
+		page = header.Get("X-Next-Page")
+	}
+
+	return result, nil
+}
+
+// toComment converts a note returned by the API
+func (c *Client) toComment(project string, mrIID int, n note) comments.Comment {
+	return comments.Comment{
+		ID:     n.ID,
+		Body:   n.Body,
+		Author: n.Author.Username,
+		URL:    fmt.Sprintf("%s/%s/-/merge_requests/%d#note_%d", c.baseURL, project, mrIID, n.ID),
+	}
+}
+
+// UpdateMRNote replaces the body of an existing merge request note
+func (c *Client) UpdateMRNote(project string, mrIID int, noteID int64, body string) error {
+	log := logger.GetLogger()
+
+	path := fmt.Sprintf("%s/%d", notesPath(project, mrIID), noteID)
+	if _, err := c.rest.Do("PUT", path, map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to update note: %w", err)
+	}
+
+	log.Infof("Successfully updated note %d", noteID)
+	return nil
+}
+
+// DeleteMRNote deletes an existing merge request note
+func (c *Client) DeleteMRNote(project string, mrIID int, noteID int64) error {
+	log := logger.GetLogger()
+
+	path := fmt.Sprintf("%s/%d", notesPath(project, mrIID), noteID)
+	if _, err := c.rest.Do("DELETE", path, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete note: %w", err)
+	}
+
+	log.Infof("Successfully deleted note %d", noteID)
+	return nil
+}
+
+// GetAuthenticatedUsername returns the username the token belongs to
+func (c *Client) GetAuthenticatedUsername() (string, error) {
+	var user struct {
+		Username string `json:"username"`
+	}
+	if _, err := c.rest.Do("GET", "/user", nil, &user); err != nil {
+		return "", fmt.Errorf("failed to get authenticated user: %w", err)
+	}
+	return user.Username, nil
+}
+
+// ValidateMRReference validates and parses a GitLab merge request reference
+// Accepts formats: group/subgroup/project!123, https://gitlab.com/group/project/-/merge_requests/123
+// baseURL is the GitLab instance: the path it is served under, if any, is
+// not part of the project of an MR URL
+func ValidateMRReference(ref, baseURL string) (project string, mrIID int, err error) {
+	// Handle URL format: https://gitlab.example.com/group/project/-/merge_requests/123
+	if strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://") {
+		u, err := url.Parse(ref)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid GitLab MR URL: %w", err)
+		}
+
+		path := strings.Trim(u.Path, "/")
+		if base, err := url.Parse(baseURL); err == nil && strings.Trim(base.Path, "/") != "" {
+			var ok bool
+			path, ok = strings.CutPrefix(path, strings.Trim(base.Path, "/")+"/")
+			if !ok {
+				return "", 0, fmt.Errorf("GitLab MR URL is not under %s", baseURL)
+			}
+		}
+		idx := strings.Index(path, "/-/merge_requests/")
+		sepLen := len("/-/merge_requests/")
+		if idx == -1 {
+			// Older GitLab versions omit the "/-" separator
+			idx = strings.Index(path, "/merge_requests/")
+			sepLen = len("/merge_requests/")
+		}
+		if idx <= 0 {
+			return "", 0, fmt.Errorf("invalid GitLab MR URL format")
+		}
+
+		project = path[:idx]
+		iid := strings.SplitN(path[idx+sepLen:], "/", 2)[0]
+		mrIID, err = strconv.Atoi(iid)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid MR number in URL: %s", iid)
+		}
+		return project, mrIID, nil
+	}
+
+	// Handle short format: group/subgroup/project!123
+	if strings.Contains(ref, "!") {
+		idx := strings.LastIndex(ref, "!")
+		project = ref[:idx]
+
+		parts := strings.Split(project, "/")
+		if len(parts) < 2 || slices.Contains(parts, "") {
+			return "", 0, fmt.Errorf("invalid project format, expected group/project")
+		}
+
+		mrIID, err = strconv.Atoi(ref[idx+1:])
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid MR number: %s", ref[idx+1:])
+		}
+
+		return project, mrIID, nil
+	}
+
+	return "", 0, fmt.Errorf("invalid MR reference format, expected group/project!123 or https://gitlab.com/group/project/-/merge_requests/123")
+}
+
+// ReferenceBaseURL returns the URL of the GitLab instance an MR URL points
+// at, e.g. https://gitlab.example.com, or "" for a short reference
+func ReferenceBaseURL(ref string) string {
+	if !strings.HasPrefix(ref, "https://") && !strings.HasPrefix(ref, "http://") {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
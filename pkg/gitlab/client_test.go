@@ -0,0 +1,288 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+)
+
+func init() {
+	// Initialize logger for tests
+	logger.Initialize(logger.ErrorLevel)
+}
+
+func TestNewClient_DefaultBaseURL(t *testing.T) {
+	client := NewClient(Config{Token: "test-token"})
+
+	if client.baseURL != DefaultBaseURL {
+		t.Errorf("Expected base URL %q, got %q", DefaultBaseURL, client.baseURL)
+	}
+}
+
+func TestPostMRNote_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Expected POST request, got %s", r.Method)
+		}
+
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Fsubgroup%2Fproject/merge_requests/12/notes" {
+			t.Errorf("Unexpected path: %s", r.URL.EscapedPath())
+		}
+
+		if r.Header.Get("PRIVATE-TOKEN") != "test-token" {
+			t.Errorf("Expected PRIVATE-TOKEN header 'test-token', got %q", r.Header.Get("PRIVATE-TOKEN"))
+		}
+
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["body"] != "Test note" {
+			t.Errorf("Expected body 'Test note', got %q", body["body"])
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 1, "body": "Test note"})
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{Token: "test-token", BaseURL: server.URL, RequestTimeout: 30 * time.Second})
+
+	note, err := client.PostMRNote("group/subgroup/project", 12, "Test note")
+	if err != nil {
+		t.Fatalf("PostMRNote failed: %v", err)
+	}
+
+	expectedURL := server.URL + "/group/subgroup/project/-/merge_requests/12#note_1"
+	if note.ID != 1 || note.URL != expectedURL {
+		t.Errorf("Expected note 1 at %s, got %d at %s", expectedURL, note.ID, note.URL)
+	}
+}
+
+func TestListMRNotes_PaginationAndSystemNotes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected GET request, got %s", r.Method)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		var notes []map[string]interface{}
+		if r.URL.Query().Get("page") == "1" {
+			w.Header().Set("X-Next-Page", "2")
+			notes = []map[string]interface{}{
+				{"id": 1, "body": "first", "system": false, "author": map[string]string{"username": "bot"}},
+				{"id": 2, "body": "added 1 commit", "system": true, "author": map[string]string{"username": "bot"}},
+			}
+		} else {
+			notes = []map[string]interface{}{
+				{"id": 3, "body": "second", "system": false, "author": map[string]string{"username": "reviewer"}},
+			}
+		}
+		json.NewEncoder(w).Encode(notes)
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{Token: "test-token", BaseURL: server.URL, RequestTimeout: 30 * time.Second})
+
+	notes, err := client.ListMRNotes("group/project", 5)
+	if err != nil {
+		t.Fatalf("ListMRNotes failed: %v", err)
+	}
+
+	if len(notes) != 2 {
+		t.Fatalf("Expected 2 notes, got %d", len(notes))
+	}
+
+	if notes[0].ID != 1 || notes[0].Author != "bot" {
+		t.Errorf("Unexpected first note: %+v", notes[0])
+	}
+
+	if notes[0].URL != server.URL+"/group/project/-/merge_requests/5#note_1" {
+		t.Errorf("Unexpected note URL: %s", notes[0].URL)
+	}
+
+	if notes[1].ID != 3 || notes[1].Body != "second" {
+		t.Errorf("Unexpected second note: %+v", notes[1])
+	}
+}
+
+func TestUpdateAndDeleteMRNote(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Fproject/merge_requests/5/notes/42" {
+			t.Errorf("Unexpected path: %s", r.URL.EscapedPath())
+		}
+		methods = append(methods, r.Method)
+
+		if r.Method == "DELETE" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 42})
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{Token: "test-token", BaseURL: server.URL, RequestTimeout: 30 * time.Second})
+
+	if err := client.UpdateMRNote("group/project", 5, 42, "Updated"); err != nil {
+		t.Errorf("UpdateMRNote failed: %v", err)
+	}
+
+	if err := client.DeleteMRNote("group/project", 5, 42); err != nil {
+		t.Errorf("DeleteMRNote failed: %v", err)
+	}
+
+	if len(methods) != 2 || methods[0] != "PUT" || methods[1] != "DELETE" {
+		t.Errorf("Expected PUT then DELETE, got %v", methods)
+	}
+}
+
+func TestGetAuthenticatedUsername(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v4/user" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"username": "project_1_bot"})
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{Token: "test-token", BaseURL: server.URL, RequestTimeout: 30 * time.Second})
+
+	username, err := client.GetAuthenticatedUsername()
+	if err != nil {
+		t.Fatalf("GetAuthenticatedUsername failed: %v", err)
+	}
+
+	if username != "project_1_bot" {
+		t.Errorf("Expected username 'project_1_bot', got %q", username)
+	}
+}
+
+func TestValidateMRReference(t *testing.T) {
+	tests := []struct {
+		name            string
+		ref             string
+		baseURL         string
+		expectedProject string
+		expectedMR      int
+		shouldError     bool
+	}{
+		{
+			name:            "Valid short format",
+			ref:             "group/project!123",
+			expectedProject: "group/project",
+			expectedMR:      123,
+		},
+		{
+			name:            "Valid short format with subgroups",
+			ref:             "group/subgroup/project!7",
+			expectedProject: "group/subgroup/project",
+			expectedMR:      7,
+		},
+		{
+			name:            "Valid URL format",
+			ref:             "https://gitlab.com/group/subgroup/project/-/merge_requests/456",
+			expectedProject: "group/subgroup/project",
+			expectedMR:      456,
+		},
+		{
+			name:            "Valid self-hosted URL with trailing path",
+			ref:             "https://gitlab.example.com/team/app/-/merge_requests/9/diffs",
+			expectedProject: "team/app",
+			expectedMR:      9,
+		},
+		{
+			name:            "Valid legacy URL format",
+			ref:             "https://gitlab.example.com/team/app/merge_requests/10",
+			expectedProject: "team/app",
+			expectedMR:      10,
+		},
+		{
+			name:            "Valid URL of an instance served under a path",
+			ref:             "https://host.example.com/gitlab/group/proj/-/merge_requests/1",
+			baseURL:         "https://host.example.com/gitlab/",
+			expectedProject: "group/proj",
+			expectedMR:      1,
+		},
+		{
+			name:            "Valid URL of an instance served at the root",
+			ref:             "https://gitlab.example.com/gitlab/proj/-/merge_requests/2",
+			baseURL:         "https://gitlab.example.com",
+			expectedProject: "gitlab/proj",
+			expectedMR:      2,
+		},
+		{
+			name:        "Invalid URL - not under the instance path",
+			ref:         "https://host.example.com/group/proj/-/merge_requests/1",
+			baseURL:     "https://host.example.com/gitlab",
+			shouldError: true,
+		},
+		{
+			name:        "Invalid format - GitHub style",
+			ref:         "owner/repo#123",
+			shouldError: true,
+		},
+		{
+			name:        "Invalid format - missing group",
+			ref:         "project!123",
+			shouldError: true,
+		},
+		{
+			name:        "Invalid format - non-numeric MR",
+			ref:         "group/project!abc",
+			shouldError: true,
+		},
+		{
+			name:        "Invalid URL - not a merge request",
+			ref:         "https://gitlab.com/group/project/-/issues/1",
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project, mrIID, err := ValidateMRReference(tt.ref, tt.baseURL)
+
+			if tt.shouldError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+
+			if project != tt.expectedProject {
+				t.Errorf("Expected project %s, got %s", tt.expectedProject, project)
+			}
+
+			if mrIID != tt.expectedMR {
+				t.Errorf("Expected MR number %d, got %d", tt.expectedMR, mrIID)
+			}
+		})
+	}
+}
+
+func TestReferenceBaseURL(t *testing.T) {
+	tests := []struct {
+		ref      string
+		expected string
+	}{
+		{ref: "https://gitlab.com/group/project/-/merge_requests/1", expected: "https://gitlab.com"},
+		{ref: "https://gitlab.example.com:8443/group/project/-/merge_requests/1", expected: "https://gitlab.example.com:8443"},
+		{ref: "http://gitlab.internal/team/app/merge_requests/10", expected: "http://gitlab.internal"},
+		{ref: "group/project!1", expected: ""},
+	}
+
+	for _, tt := range tests {
+		if got := ReferenceBaseURL(tt.ref); got != tt.expected {
+			t.Errorf("ReferenceBaseURL(%q) = %q, expected %q", tt.ref, got, tt.expected)
+		}
+	}
+}
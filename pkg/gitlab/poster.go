@@ -0,0 +1,45 @@
+package gitlab
+
+import "github.com/belitre/argocd-diff-preview-pr-comment/pkg/comments"
+
+// MRPoster manages the notes of a single GitLab merge request. It implements
+// comments.Poster and comments.AuthorLookup
+type MRPoster struct {
+	client  *Client
+	project string
+	mrIID   int
+}
+
+// NewMRPoster creates a poster for the given merge request
+func NewMRPoster(client *Client, project string, mrIID int) *MRPoster {
+	return &MRPoster{
+		client:  client,
+		project: project,
+		mrIID:   mrIID,
+	}
+}
+
+// PostComment creates a new note on the merge request
+func (p *MRPoster) PostComment(body string) (comments.Comment, error) {
+	return p.client.PostMRNote(p.project, p.mrIID, body)
+}
+
+// ListComments returns all user notes on the merge request
+func (p *MRPoster) ListComments() ([]comments.Comment, error) {
+	return p.client.ListMRNotes(p.project, p.mrIID)
+}
+
+// UpdateComment replaces the body of an existing note
+func (p *MRPoster) UpdateComment(id int64, body string) error {
+	return p.client.UpdateMRNote(p.project, p.mrIID, id, body)
+}
+
+// DeleteComment deletes an existing note
+func (p *MRPoster) DeleteComment(id int64) error {
+	return p.client.DeleteMRNote(p.project, p.mrIID, id)
+}
+
+// AuthenticatedLogin returns the username the token belongs to
+func (p *MRPoster) AuthenticatedLogin() (string, error) {
+	return p.client.GetAuthenticatedUsername()
+}
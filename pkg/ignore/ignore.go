@@ -0,0 +1,193 @@
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/diffparser"
+)
+
+// Kinds of rules
+const (
+	// KindLine rules match the text of changed lines, without the + or -
+	KindLine = "line"
+	// KindKey rules match the dotted YAML key path of changed lines, e.g.
+	// metadata.labels.helm.sh/chart
+	KindKey = "key"
+)
+
+// ValidKinds returns all valid rule kinds
+func ValidKinds() []string {
+	return []string{KindLine, KindKey}
+}
+
+// Rule marks the changed lines matching a regular expression as noise
+type Rule struct {
+	Kind    string
+	Pattern *regexp.Regexp
+}
+
+// String renders the rule as it is written in a rules file
+func (r Rule) String() string {
+	return fmt.Sprintf("%s: %s", r.Kind, r.Pattern)
+}
+
+// Rules are the rules a diff is checked against. A changed line is noise
+// if any rule matches it
+type Rules []Rule
+
+// NewRule compiles a rule of the given kind
+func NewRule(kind, pattern string) (Rule, error) {
+	if !slices.Contains(ValidKinds(), kind) {
+		return Rule{}, fmt.Errorf("invalid ignore rule kind: %s (valid kinds: %s)", kind, strings.Join(ValidKinds(), ", "))
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid %s ignore rule %q: %w", kind, pattern, err)
+	}
+	return Rule{Kind: kind, Pattern: re}, nil
+}
+
+// ParseRules parses rules written one per line as "kind: regex", e.g.
+// "key: labels\.helm\.sh/chart$". Blank lines and lines starting with #
+// are skipped
+func ParseRules(text string) (Rules, error) {
+	var rules Rules
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kind, pattern, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"kind: regex\", got %q", number, line)
+		}
+		rule, err := NewRule(strings.TrimSpace(kind), strings.TrimSpace(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, scanner.Err()
+}
+
+// LoadRules reads and parses a rules file
+func LoadRules(path string) (Rules, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ignore rules file: %w", err)
+	}
+
+	rules, err := ParseRules(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ignore rules file %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Apply collapses the hunks whose changes are all noise into a note saying
+// how many were hidden. Consecutive noise-only hunks share a note. The rules
+// and the number of hunks hidden are reported in the stats footer. Returns
+// the number of hunks hidden
+func (r Rules) Apply(report *diffparser.Report) int {
+	if len(r) == 0 {
+		return 0
+	}
+
+	hidden := 0
+	for _, app := range report.Apps {
+		var hunks []diffparser.Hunk
+		collapsed := -1
+		count := 0
+		for _, hunk := range app.Hunks {
+			if !r.noiseOnly(hunk) {
+				hunks = append(hunks, hunk)
+				collapsed = -1
+				continue
+			}
+
+			hidden++
+			if collapsed == -1 {
+				// The note takes the place of the first hidden hunk, after
+				// its skipped-line marker
+				hunks = append(hunks, diffparser.Hunk{Skipped: hunk.Skipped})
+				collapsed = len(hunks) - 1
+				count = 0
+			}
+			count++
+			hunks[collapsed].Lines = []string{hiddenNote(count)}
+		}
+		app.Hunks = hunks
+	}
+
+	if report.Stats == nil {
+		report.Stats = &diffparser.Stats{}
+	}
+	report.Stats.Lines = append(report.Stats.Lines, r.Note(hidden))
+
+	return hidden
+}
+
+// hiddenNote is the line standing in for noise-only hunks
+func hiddenNote(count int) string {
+	if count == 1 {
+		return "@@ 1 noise-only hunk hidden @@"
+	}
+	return fmt.Sprintf("@@ %d noise-only hunks hidden @@", count)
+}
+
+// Note returns the line reporting the rules and how many hunks they hid,
+// for the stats footer
+func (r Rules) Note(hidden int) string {
+	rules := make([]string, 0, len(r))
+	for _, rule := range r {
+		rules = append(rules, fmt.Sprintf("`%s`", rule))
+	}
+	return fmt.Sprintf("[Noise-only hunks hidden: %d], [Ignore rules: %s]", hidden, strings.Join(rules, ", "))
+}
+
+// noiseOnly returns true if the hunk has changes and every one is noise
+func (r Rules) noiseOnly(hunk diffparser.Hunk) bool {
+	changed := false
+	paths := keyPaths(hunk.Lines)
+	for i, line := range hunk.Lines {
+		if !isChange(line) {
+			continue
+		}
+		changed = true
+		if !r.matches(line[1:], paths[i]) {
+			return false
+		}
+	}
+	return changed
+}
+
+// matches returns true if any rule matches the changed line or its key path
+func (r Rules) matches(text, path string) bool {
+	for _, rule := range r {
+		switch rule.Kind {
+		case KindLine:
+			if rule.Pattern.MatchString(text) {
+				return true
+			}
+		case KindKey:
+			if path != "" && rule.Pattern.MatchString(path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isChange returns true for added and removed lines
+func isChange(line string) bool {
+	return strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")
+}
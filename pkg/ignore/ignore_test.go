@@ -0,0 +1,207 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/diffparser"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		expected  []string
+		expectErr string
+	}{
+		{
+			name: "rules with comments",
+			text: "# Helm chart bumps\nkey: labels\\.helm\\.sh/chart$\n\nline:   checksum/config:  \n",
+			expected: []string{
+				`key: labels\.helm\.sh/chart$`,
+				"line: checksum/config:",
+			},
+		},
+		{
+			name:      "missing kind",
+			text:      "key: ok\nhelm.sh/chart\n",
+			expectErr: "line 2: expected",
+		},
+		{
+			name:      "unknown kind",
+			text:      "value: foo",
+			expectErr: "invalid ignore rule kind: value",
+		},
+		{
+			name:      "invalid regex",
+			text:      "line: (unclosed",
+			expectErr: "invalid line ignore rule",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ParseRules(tt.text)
+			if tt.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRules failed: %v", err)
+			}
+
+			var got []string
+			for _, rule := range rules {
+				got = append(got, rule.String())
+			}
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected rules %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ignore.rules")
+	if err := os.WriteFile(path, []byte("key: helm\\.sh/chart$\n"), 0644); err != nil {
+		t.Fatalf("Failed to create rules file: %v", err)
+	}
+
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+	if len(rules) != 1 {
+		t.Errorf("Expected 1 rule, got %d", len(rules))
+	}
+
+	if _, err := LoadRules(filepath.Join(t.TempDir(), "missing.rules")); err == nil {
+		t.Error("Expected error for a missing rules file but got none")
+	}
+}
+
+func TestKeyPaths(t *testing.T) {
+	lines := []string{
+		" metadata:",
+		"   labels:",
+		"-    helm.sh/chart: argo-cd-7.7.7",
+		"+    helm.sh/chart: argo-cd-9.1.4",
+		"   annotations:",
+		`+    "checksum/config": abc`,
+		" spec:",
+		"   containers:",
+		"   - name: server",
+		"     args:",
+		"+    - --insecure",
+		"",
+		"+  image: nginx:1.27",
+	}
+	expected := []string{
+		"metadata",
+		"metadata.labels",
+		"metadata.labels.helm.sh/chart",
+		"metadata.labels.helm.sh/chart",
+		"metadata.annotations",
+		"metadata.annotations.checksum/config",
+		"spec",
+		"spec.containers",
+		"spec.containers.name",
+		"spec.containers.args",
+		"spec.containers.args",
+		"",
+		"spec.image",
+	}
+
+	if got := keyPaths(lines); !slices.Equal(got, expected) {
+		t.Errorf("Expected paths %q, got %q", expected, got)
+	}
+}
+
+func TestRules_Apply(t *testing.T) {
+	rules, err := ParseRules("key: labels\\.helm\\.sh/chart$\nkey: labels\\.app\\.kubernetes\\.io/version$\nline: checksum/")
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+
+	app := &diffparser.Application{
+		Name: "web",
+		Hunks: []diffparser.Hunk{
+			{Lines: []string{
+				"   labels:",
+				"-    app.kubernetes.io/version: v2.13.1",
+				"-    helm.sh/chart: argo-cd-7.7.7",
+				"+    app.kubernetes.io/version: v3.2.0",
+				"+    helm.sh/chart: argo-cd-9.1.4",
+			}},
+			{Skipped: &diffparser.SkippedLines{Count: 10, From: 20, To: 30}, Lines: []string{
+				"   annotations:",
+				"-    checksum/config: abc",
+				"+    checksum/config: def",
+			}},
+			{Skipped: &diffparser.SkippedLines{Count: 5, From: 40, To: 45}, Lines: []string{
+				"   labels:",
+				"-    helm.sh/chart: argo-cd-7.7.7",
+				"+    helm.sh/chart: argo-cd-9.1.4",
+				"+    team: platform",
+			}},
+			{Skipped: &diffparser.SkippedLines{Count: 5, From: 60, To: 65}, Lines: []string{
+				"   labels:",
+				"+    helm.sh/chart: argo-cd-9.1.4",
+			}},
+			{Skipped: &diffparser.SkippedLines{Count: 5, From: 70, To: 75}, Lines: []string{
+				"   name: unchanged",
+			}},
+		},
+	}
+	report := &diffparser.Report{Apps: []*diffparser.Application{app}}
+
+	if hidden := rules.Apply(report); hidden != 3 {
+		t.Errorf("Expected 3 hidden hunks, got %d", hidden)
+	}
+
+	expected := []string{
+		"@@ 2 noise-only hunks hidden @@",
+		"@@ skipped 5 lines (40 -> 45) @@",
+		"   labels:",
+		"-    helm.sh/chart: argo-cd-7.7.7",
+		"+    helm.sh/chart: argo-cd-9.1.4",
+		"+    team: platform",
+		"@@ skipped 5 lines (60 -> 65) @@",
+		"@@ 1 noise-only hunk hidden @@",
+		"@@ skipped 5 lines (70 -> 75) @@",
+		"   name: unchanged",
+	}
+	if got := app.DiffLines(); !slices.Equal(got, expected) {
+		t.Errorf("Expected diff lines:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	if report.Stats == nil || !slices.Equal(report.Stats.Lines, []string{rules.Note(3)}) {
+		t.Errorf("Expected the rules in the stats footer, got %+v", report.Stats)
+	}
+
+	// The collapsed diff must still parse
+	reparsed, err := diffparser.Parse(report.Render())
+	if err != nil {
+		t.Fatalf("Parse of the collapsed diff failed: %v", err)
+	}
+	if got := reparsed.Apps[0].DiffLines(); !slices.Equal(got, expected) {
+		t.Errorf("Expected the collapsed diff to parse back unchanged, got:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestRules_Note(t *testing.T) {
+	rules, err := ParseRules("key: helm\\.sh/chart$\nline: checksum/")
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+
+	expected := "[Noise-only hunks hidden: 4], [Ignore rules: `key: helm\\.sh/chart$`, `line: checksum/`]"
+	if got := rules.Note(4); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,62 @@
+package ignore
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// indentRegexp splits a YAML line into its indentation, list item dashes
+	// and content
+	indentRegexp = regexp.MustCompile(`^( *)((?:- +)*)(.*)$`)
+	// yamlKeyRegexp matches the key of a "key: value" or "key:" line
+	yamlKeyRegexp = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s#'"][^:]*):(?:\s|$)`)
+)
+
+// yamlKey is a key enclosing the following lines
+type yamlKey struct {
+	indent int
+	name   string
+}
+
+// keyPaths returns the dotted YAML key path of every diff line, worked out
+// from indentation. Only the lines of the hunk are visible, so paths start
+// at the shallowest key the hunk shows, e.g. "labels.helm.sh/chart" for a
+// hunk that doesn't reach up to metadata
+func keyPaths(lines []string) []string {
+	paths := make([]string, len(lines))
+
+	var stack []yamlKey
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+
+		match := indentRegexp.FindStringSubmatch(line[1:])
+		indent := len(match[1]) + len(match[2])
+		content := strings.TrimSpace(match[3])
+		if content == "" || strings.HasPrefix(content, "#") {
+			paths[i] = joinKeys(stack)
+			continue
+		}
+
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		if key := yamlKeyRegexp.FindStringSubmatch(content); key != nil {
+			stack = append(stack, yamlKey{indent: indent, name: strings.Trim(key[1], `"'`)})
+		}
+		paths[i] = joinKeys(stack)
+	}
+
+	return paths
+}
+
+// joinKeys returns the path of the keys on the stack
+func joinKeys(stack []yamlKey) string {
+	names := make([]string, 0, len(stack))
+	for _, key := range stack {
+		names = append(names, key.name)
+	}
+	return strings.Join(names, ".")
+}
@@ -0,0 +1,108 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Stdin is the path that reads the input from standard input
+const Stdin = "-"
+
+// The first bytes of gzip and zstd streams
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Open opens the input at path, or standard input if path is Stdin. Inputs
+// compressed with gzip or zstd, e.g. diff.md.gz or diff.md.zst, are
+// decompressed transparently
+func Open(path string) (io.ReadCloser, error) {
+	if path == Stdin {
+		return NewReader(os.Stdin)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &readCloser{Reader: reader, closers: []io.Closer{reader, file}}, nil
+}
+
+// ReadFile reads the whole input at path, see Open
+func ReadFile(path string) ([]byte, error) {
+	reader, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name(path), err)
+	}
+	return content, nil
+}
+
+// NewReader returns a reader decompressing r if its first bytes show it is
+// compressed with gzip or zstd, or reading it as is otherwise. Closing the
+// reader doesn't close r
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+
+	// A short or failing read is left to the caller to report
+	head, _ := buffered.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		reader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip input: %w", err)
+		}
+		return reader, nil
+	case bytes.HasPrefix(head, zstdMagic):
+		decoder, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd input: %w", err)
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return io.NopCloser(buffered), nil
+	}
+}
+
+// name returns how the input at path is named in errors
+func name(path string) string {
+	if path == Stdin {
+		return "standard input"
+	}
+	return path
+}
+
+// readCloser closes all of its closers, in order
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes the decompressor and then the file it reads
+func (r *readCloser) Close() error {
+	var first error
+	for _, closer := range r.closers {
+		if err := closer.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
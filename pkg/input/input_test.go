@@ -0,0 +1,132 @@
+package input
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const diff = "## Argo CD Diff Preview\n\nSummary:\n```yaml\nTotal: 0 files changed\n```\n"
+
+// gzipped returns content compressed with gzip
+func gzipped(t *testing.T, content string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// zstded returns content compressed with zstd
+func zstded(t *testing.T, content string) []byte {
+	t.Helper()
+
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer encoder.Close()
+	return encoder.EncodeAll([]byte(content), nil)
+}
+
+func TestReadFile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+	}{
+		{name: "Plain", file: "diff.md", content: []byte(diff)},
+		{name: "Gzip", file: "diff.md.gz", content: gzipped(t, diff)},
+		{name: "Zstd", file: "diff.md.zst", content: zstded(t, diff)},
+		{name: "Compressed without extension", file: "diff-gzip.md", content: gzipped(t, diff)},
+		{name: "Empty", file: "empty.md", content: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, tt.file)
+			if err := os.WriteFile(path, tt.content, 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			got, err := ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile failed: %v", err)
+			}
+
+			expected := diff
+			if tt.content == nil {
+				expected = ""
+			}
+			if string(got) != expected {
+				t.Errorf("Expected %q, got %q", expected, got)
+			}
+		})
+	}
+}
+
+func TestReadFile_Stdin(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+
+	old := os.Stdin
+	os.Stdin = reader
+	defer func() { os.Stdin = old }()
+
+	go func() {
+		writer.Write(zstded(t, diff))
+		writer.Close()
+	}()
+
+	got, err := ReadFile(Stdin)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(got) != diff {
+		t.Errorf("Expected %q, got %q", diff, got)
+	}
+}
+
+func TestReadFile_Errors(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	corrupt := gzipped(t, diff)
+	corrupt = corrupt[:len(corrupt)-10]
+	corruptPath := filepath.Join(tmpDir, "corrupt.md.gz")
+	if err := os.WriteFile(corruptPath, corrupt, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{name: "Missing file", path: filepath.Join(tmpDir, "missing.md"), expected: "no such file"},
+		{name: "Truncated gzip", path: corruptPath, expected: corruptPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadFile(tt.path)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -11,6 +11,10 @@ import (
 var (
 	// Log is the global logger instance
 	Log *zap.SugaredLogger
+
+	// level and output are what the global logger was initialized with
+	level  = InfoLevel
+	output = "stdout"
 )
 
 // LogLevel represents the available log levels
@@ -73,15 +77,26 @@ func toZapLevel(level LogLevel) zapcore.Level {
 }
 
 // Initialize initializes the global logger with the specified log level
-func Initialize(level LogLevel) error {
-	zapLevel := toZapLevel(level)
+func Initialize(logLevel LogLevel) error {
+	return build(logLevel, output)
+}
+
+// SetOutput re-initializes the global logger at its current level to write
+// to the given path, e.g. "stderr" when stdout is used for data
+func SetOutput(path string) error {
+	return build(level, path)
+}
+
+// build builds the global logger
+func build(logLevel LogLevel, path string) error {
+	zapLevel := toZapLevel(logLevel)
 
 	config := zap.NewProductionConfig()
 	config.Level = zap.NewAtomicLevelAt(zapLevel)
 	config.Encoding = "console"
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-	config.OutputPaths = []string{"stdout"}
+	config.OutputPaths = []string{path}
 	config.ErrorOutputPaths = []string{"stderr"}
 
 	logger, err := config.Build()
@@ -90,6 +105,7 @@ func Initialize(level LogLevel) error {
 	}
 
 	Log = logger.Sugar()
+	level, output = logLevel, path
 	return nil
 }
 
@@ -1,6 +1,8 @@
 package logger
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -127,6 +129,29 @@ func TestInitialize(t *testing.T) {
 	}
 }
 
+func TestSetOutput(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "log")
+	if err := Initialize(WarnLevel); err != nil {
+		t.Fatalf("Failed to initialize logger: %v", err)
+	}
+	if err := SetOutput(logFile); err != nil {
+		t.Fatalf("SetOutput() error = %v", err)
+	}
+	defer SetOutput("stdout")
+
+	Log.Info("hidden")
+	Log.Warn("shown")
+	Sync()
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if strings.Contains(string(content), "hidden") || !strings.Contains(string(content), "shown") {
+		t.Errorf("Expected only the warning at the kept level, got: %s", content)
+	}
+}
+
 func TestGetLogger(t *testing.T) {
 	// Reset logger
 	Log = nil
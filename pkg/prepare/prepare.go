@@ -0,0 +1,274 @@
+package prepare
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/diffparser"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/filter"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/ignore"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/input"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/redact"
+)
+
+// Settings select what is done to the diff files, as given with flags
+type Settings struct {
+	Filter filter.Rules
+	// IgnoreRulesFile is a file of ignore rules, applied before IgnoreLines
+	// and IgnoreKeys
+	IgnoreRulesFile string
+	IgnoreLines     []string
+	IgnoreKeys      []string
+	Redact          bool
+	RedactPatterns  []string
+}
+
+// Preparation is what is done to the diff files before they are written to
+// any output
+type Preparation struct {
+	filter   filter.Rules
+	ignore   ignore.Rules
+	redactor *redact.Redactor
+}
+
+// New returns the preparation the settings select
+func New(s Settings) (*Preparation, error) {
+	ignoreRules, err := loadIgnoreRules(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Preparation{filter: s.Filter, ignore: ignoreRules}
+	// Invalid globs are reported even if the diff file can't be parsed
+	if _, err := filter.New(p.filter); err != nil {
+		return nil, err
+	}
+	if s.Redact {
+		p.redactor, err = redact.New(s.RedactPatterns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// loadIgnoreRules returns the ignore rules from the rules file followed by
+// the ignore lines and keys
+func loadIgnoreRules(s Settings) (ignore.Rules, error) {
+	var rules ignore.Rules
+	if s.IgnoreRulesFile != "" {
+		loaded, err := ignore.LoadRules(s.IgnoreRulesFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, loaded...)
+	}
+
+	for _, set := range []struct {
+		kind     string
+		patterns []string
+	}{
+		{ignore.KindLine, s.IgnoreLines},
+		{ignore.KindKey, s.IgnoreKeys},
+	} {
+		for _, pattern := range set.patterns {
+			rule, err := ignore.NewRule(set.kind, pattern)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+// empty returns true if the diff file is used as is
+func (p *Preparation) empty() bool {
+	return p.filter.IsEmpty() && len(p.ignore) == 0 && p.redactor == nil
+}
+
+// labelRegexp matches the labels of diff files combined into one report
+var labelRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Input is a diff file given with --file, labeled with its environment when
+// the diffs of several are combined
+type Input struct {
+	Label   string
+	Path    string
+	Content []byte
+}
+
+// String returns the path of the diff file, with its label if it has one
+func (in Input) String() string {
+	if in.Label == "" {
+		return in.Path
+	}
+	return fmt.Sprintf("%s (%s)", in.Path, in.Label)
+}
+
+// ParseInputs parses the --file values: a path, or label=path for each of
+// several diff files to combine
+func ParseInputs(values []string) ([]Input, error) {
+	var inputs []Input
+	labels := make(map[string]bool)
+	stdin := false
+	for _, value := range values {
+		in := Input{Path: value}
+		if label, path, ok := strings.Cut(value, "="); ok && labelRegexp.MatchString(label) {
+			in = Input{Label: label, Path: path}
+		}
+
+		if in.Path == "" {
+			return nil, fmt.Errorf("--file %s has no path", value)
+		}
+		if in.Path == input.Stdin {
+			if stdin {
+				return nil, fmt.Errorf("only one --file can be read from stdin")
+			}
+			stdin = true
+		}
+
+		if len(values) > 1 {
+			if in.Label == "" {
+				return nil, fmt.Errorf("--file %s needs a label to be combined with other diff files, e.g. --file prod=%s", value, value)
+			}
+			if labels[in.Label] {
+				return nil, fmt.Errorf("diff file label %s is used more than once", in.Label)
+			}
+			labels[in.Label] = true
+		}
+
+		inputs = append(inputs, in)
+	}
+
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf(`required flag(s) "file" not set`)
+	}
+	return inputs, nil
+}
+
+// ReadInputs reads the content of every diff file
+func ReadInputs(inputs []Input) error {
+	log := logger.GetLogger()
+
+	for i := range inputs {
+		log.Infof("Processing diff file: %s", inputs[i])
+
+		content, err := input.ReadFile(inputs[i].Path)
+		if err != nil {
+			return fmt.Errorf("failed to read input file: %w", err)
+		}
+		inputs[i].Content = content
+
+		log.Infof("Input file size: %d bytes", len(content))
+	}
+	return nil
+}
+
+// DiffFile writes the diff, without the applications the filter rules drop,
+// with noise-only hunks collapsed and sensitive values redacted, to a
+// temporary file so every output shows the same diff, even when it was read
+// from stdin or decompressed. Labeled diff files are combined into one
+// report. A single diff file that can't be parsed is used as is, only
+// redacted line by line. Returns the path of the prepared file, which the
+// caller must remove
+func DiffFile(inputs []Input, p *Preparation) (string, error) {
+	log := logger.GetLogger()
+
+	content := inputs[0].Content
+	if len(inputs) > 1 || inputs[0].Label != "" {
+		report, err := combine(inputs, p)
+		if err != nil {
+			return "", err
+		}
+		content = []byte(report.Render())
+	} else if !p.empty() {
+		report, err := diffparser.Parse(string(content))
+		switch {
+		case err != nil && p.redactor != nil:
+			// Secrets are never posted, even from a diff file cut off or
+			// malformed
+			log.Warnf("Failed to parse diff file, using it without filters or ignore rules, redacted line by line: %v", err)
+			text, redacted := p.redactor.ApplyText(string(content))
+			if redacted > 0 {
+				log.Infof("Redacted %d sensitive value(s)", redacted)
+			}
+			content = []byte(text)
+		case err != nil:
+			log.Warnf("Failed to parse diff file, using it as is without filters or ignore rules: %v", err)
+		default:
+			if err := p.apply(report); err != nil {
+				return "", err
+			}
+			content = []byte(report.Render())
+		}
+	}
+
+	file, err := os.CreateTemp("", "argocd-diff-*.md")
+	if err != nil {
+		return "", fmt.Errorf("failed to create prepared diff file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(content); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write prepared diff file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// combine parses and prepares labeled diff files, combining them into one
+// report
+func combine(inputs []Input, p *Preparation) (*diffparser.Report, error) {
+	log := logger.GetLogger()
+
+	labeled := make([]diffparser.LabeledReport, 0, len(inputs))
+	for _, in := range inputs {
+		report, err := diffparser.Parse(string(in.Content))
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to parse diff file: %w", in.Label, err)
+		}
+		if err := p.apply(report); err != nil {
+			return nil, fmt.Errorf("%s: %w", in.Label, err)
+		}
+		labeled = append(labeled, diffparser.LabeledReport{Label: in.Label, Report: report})
+	}
+
+	log.Infof("Combined %d diff file(s) into one report", len(labeled))
+	return diffparser.Combine(labeled), nil
+}
+
+// apply filters, hides the noise of and redacts a parsed diff file
+func (p *Preparation) apply(report *diffparser.Report) error {
+	log := logger.GetLogger()
+
+	f, err := filter.New(p.filter)
+	if err != nil {
+		return err
+	}
+
+	if !p.filter.IsEmpty() {
+		removed := report.Filter(f.Keep)
+		if len(removed) > 0 {
+			log.Infof("Filtered out %d application(s): %s", len(removed), strings.Join(removed, ", "))
+		}
+		log.Infof("%d application(s) left after filtering", len(report.Apps))
+	}
+
+	if len(p.ignore) > 0 {
+		hidden := p.ignore.Apply(report)
+		log.Infof("Hid %d noise-only hunk(s) with %d ignore rule(s)", hidden, len(p.ignore))
+	}
+
+	if p.redactor != nil {
+		if redacted := p.redactor.Apply(report); redacted > 0 {
+			log.Infof("Redacted %d sensitive value(s)", redacted)
+		}
+	}
+
+	return nil
+}
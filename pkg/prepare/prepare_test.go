@@ -0,0 +1,304 @@
+package prepare
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/filter"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/ignore"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/redact"
+)
+
+func init() {
+	// Initialize logger for tests
+	logger.Initialize(logger.ErrorLevel)
+}
+
+// readFile returns the contents of a test file
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return content
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    Settings
+		empty       bool
+		shouldError bool
+	}{
+		{name: "Nothing selected", settings: Settings{}, empty: true},
+		{name: "Redaction", settings: Settings{Redact: true, RedactPatterns: []string{"internal-[a-z]+"}}},
+		{name: "Ignore lines and keys", settings: Settings{IgnoreLines: []string{"^\\s*replicas:"}, IgnoreKeys: []string{"labels$"}}},
+		{name: "Invalid glob", settings: Settings{Filter: filter.Rules{ExcludeApps: []string{"app-[0-9"}}}, shouldError: true},
+		{name: "Invalid ignore rule", settings: Settings{IgnoreLines: []string{"(unclosed"}}, shouldError: true},
+		{name: "Missing ignore rules file", settings: Settings{IgnoreRulesFile: "does-not-exist"}, shouldError: true},
+		{name: "Invalid redaction pattern", settings: Settings{Redact: true, RedactPatterns: []string{"(unclosed"}}, shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.settings)
+			if tt.shouldError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.empty() != tt.empty {
+				t.Errorf("Expected empty() = %v", tt.empty)
+			}
+		})
+	}
+}
+
+// readInputs returns a single unlabeled diff input read from a test file
+func readInputs(t *testing.T, path string) []Input {
+	t.Helper()
+
+	return []Input{{Path: path, Content: readFile(t, path)}}
+}
+
+func TestDiffFile_Filters(t *testing.T) {
+	tests := []struct {
+		name        string
+		rules       filter.Rules
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:     "Application kept",
+			rules:    filter.Rules{IncludePaths: []string{"examples/**"}},
+			contains: []string{"Total: 1 files changed", "Modified (1):", "<summary>argocd-helm-chart"},
+		},
+		{
+			name:        "Application dropped",
+			rules:       filter.Rules{ExcludeApps: []string{"argocd-*"}},
+			contains:    []string{"Total: 0 files changed"},
+			notContains: []string{"Modified (", "argocd-helm-chart"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := DiffFile(readInputs(t, "../../testing/2-app-diff.md"), &Preparation{filter: tt.rules})
+			if err != nil {
+				t.Fatalf("DiffFile failed: %v", err)
+			}
+			defer os.Remove(path)
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read filtered file: %v", err)
+			}
+			for _, expected := range tt.contains {
+				if !strings.Contains(string(content), expected) {
+					t.Errorf("Expected filtered diff to contain %q", expected)
+				}
+			}
+			for _, unexpected := range tt.notContains {
+				if strings.Contains(string(content), unexpected) {
+					t.Errorf("Expected filtered diff not to contain %q", unexpected)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffFile_IgnoreRules(t *testing.T) {
+	rules, err := ignore.ParseRules("line: .*")
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+
+	path, err := DiffFile(readInputs(t, "../../testing/2-app-diff.md"), &Preparation{ignore: rules})
+	if err != nil {
+		t.Fatalf("DiffFile failed: %v", err)
+	}
+	defer os.Remove(path)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read prepared file: %v", err)
+	}
+	for _, expected := range []string{"noise-only hunks hidden @@", "[Ignore rules: `line: .*`]", "Total: 1 files changed"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected prepared diff to contain %q", expected)
+		}
+	}
+}
+
+func TestDiffFile_Redaction(t *testing.T) {
+	diff := "<details>\n<summary>db (apps/db.yaml)</summary>\n<br>\n\n```diff\n" +
+		" apiVersion: v1\n" +
+		" data:\n" +
+		"-  password: aHVudGVyMg==\n" +
+		"+  password: c3dvcmRmaXNo\n" +
+		" kind: Secret\n" +
+		"```\n\n</details>\n"
+	path := filepath.Join(t.TempDir(), "diff.md")
+	if err := os.WriteFile(path, []byte(diff), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	redactor, err := redact.New(nil)
+	if err != nil {
+		t.Fatalf("redact.New failed: %v", err)
+	}
+
+	prepared, err := DiffFile(readInputs(t, path), &Preparation{redactor: redactor})
+	if err != nil {
+		t.Fatalf("DiffFile failed: %v", err)
+	}
+	defer os.Remove(prepared)
+
+	content, err := os.ReadFile(prepared)
+	if err != nil {
+		t.Fatalf("Failed to read prepared file: %v", err)
+	}
+	for _, secret := range []string{"aHVudGVyMg==", "c3dvcmRmaXNo"} {
+		if strings.Contains(string(content), secret) {
+			t.Errorf("Expected %q to be redacted, got:\n%s", secret, content)
+		}
+	}
+	if !strings.Contains(string(content), "+  password: "+redactor.Placeholder("c3dvcmRmaXNo")) {
+		t.Errorf("Expected a placeholder for the new password, got:\n%s", content)
+	}
+}
+
+// unparseableDiff is a diff file cut off in the middle of an application
+const unparseableDiff = "## Argo CD Diff Preview\n\n<details>\n<summary>db (apps/db.yaml)</summary>\n<br>\n\n```diff\n" +
+	" apiVersion: v1\n" +
+	" data:\n" +
+	"+  password: c3dvcmRmaXNo\n" +
+	" kind: Secret\n"
+
+func TestDiffFile_Unparseable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "diff.md")
+	if err := os.WriteFile(path, []byte(unparseableDiff), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	redactor, err := redact.New(nil)
+	if err != nil {
+		t.Fatalf("redact.New failed: %v", err)
+	}
+
+	// Without a redactor it is used as is
+	prepared, err := DiffFile(readInputs(t, path), &Preparation{filter: filter.Rules{ExcludeApps: []string{"db"}}})
+	if err != nil {
+		t.Fatalf("DiffFile failed: %v", err)
+	}
+	defer os.Remove(prepared)
+
+	if content := string(readFile(t, prepared)); content != unparseableDiff {
+		t.Errorf("Expected the diff file as is, got:\n%s", content)
+	}
+
+	// With a redactor it is still redacted
+	redactedPath, err := DiffFile(readInputs(t, path), &Preparation{redactor: redactor})
+	if err != nil {
+		t.Fatalf("DiffFile failed: %v", err)
+	}
+	defer os.Remove(redactedPath)
+
+	content := string(readFile(t, redactedPath))
+	if strings.Contains(content, "c3dvcmRmaXNo") {
+		t.Errorf("Expected the Secret data to be redacted, got:\n%s", content)
+	}
+	if !strings.Contains(content, "+  password: "+redactor.Placeholder("c3dvcmRmaXNo")) {
+		t.Errorf("Expected a placeholder for the password, got:\n%s", content)
+	}
+
+	// Combining needs every diff file parsed
+	inputs := []Input{
+		{Label: "dev", Path: path, Content: []byte(unparseableDiff)},
+		{Label: "prod", Path: "prod.md", Content: readFile(t, "../../testing/2-app-diff.md")},
+	}
+	if _, err := DiffFile(inputs, &Preparation{redactor: redactor}); err == nil {
+		t.Error("Expected an error combining an unparseable diff file")
+	}
+}
+
+func TestParseInputs(t *testing.T) {
+	tests := []struct {
+		name        string
+		values      []string
+		expected    []Input
+		shouldError bool
+	}{
+		{name: "Single path", values: []string{"diff.md"}, expected: []Input{{Path: "diff.md"}}},
+		{name: "Single labeled path", values: []string{"prod=diff.md"}, expected: []Input{{Label: "prod", Path: "diff.md"}}},
+		{name: "Path with an equals sign", values: []string{"out/a=b.md"}, expected: []Input{{Path: "out/a=b.md"}}},
+		{
+			name:     "Several labeled paths",
+			values:   []string{"dev=dev.md", "prod=-"},
+			expected: []Input{{Label: "dev", Path: "dev.md"}, {Label: "prod", Path: "-"}},
+		},
+		{name: "Several without labels", values: []string{"dev.md", "prod.md"}, shouldError: true},
+		{name: "Duplicate labels", values: []string{"prod=a.md", "prod=b.md"}, shouldError: true},
+		{name: "Stdin twice", values: []string{"dev=-", "prod=-"}, shouldError: true},
+		{name: "Label without path", values: []string{"prod="}, shouldError: true},
+		{name: "No files", values: nil, shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputs, err := ParseInputs(tt.values)
+			if tt.shouldError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !slices.EqualFunc(inputs, tt.expected, func(a, b Input) bool { return a.Label == b.Label && a.Path == b.Path }) {
+				t.Errorf("Expected %v, got %v", tt.expected, inputs)
+			}
+		})
+	}
+}
+
+func TestDiffFile_Combined(t *testing.T) {
+	inputs := []Input{
+		{Label: "dev", Path: "dev.md", Content: readFile(t, "../../testing/2-app-diff.md")},
+		{Label: "prod", Path: "prod.md", Content: readFile(t, "../../testing/too-long-diff.md")},
+	}
+
+	path, err := DiffFile(inputs, &Preparation{})
+	if err != nil {
+		t.Fatalf("DiffFile failed: %v", err)
+	}
+	defer os.Remove(path)
+
+	content := string(readFile(t, path))
+	for _, expected := range []string{
+		"| Environment | Applications |",
+		"| dev | 1 | 0 | 1 | 0 |",
+		"| prod | 1 | 0 | 1 | 0 |",
+		"dev (1 files changed):",
+		"### Environment: dev",
+		"### Environment: prod",
+	} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("Expected the combined report to contain %q", expected)
+		}
+	}
+	if strings.Index(content, "### Environment: dev") > strings.Index(content, "### Environment: prod") {
+		t.Error("Expected the environments in the order of the files")
+	}
+}
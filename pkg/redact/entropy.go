@@ -0,0 +1,44 @@
+package redact
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	// minEntropy is the Shannon entropy, in bits per character, above which
+	// a string is considered random. Random base64 is close to 6, hex digests
+	// stay below 4 and identifiers and words well below
+	minEntropy = 4.5
+)
+
+// highEntropy returns true if the string looks like a random token or key:
+// letters mixed with digits, with a high Shannon entropy
+func highEntropy(s string) bool {
+	if !strings.ContainsAny(s, "0123456789") || strings.IndexFunc(s, isLetter) < 0 {
+		return false
+	}
+	return entropy(s) >= minEntropy
+}
+
+// entropy returns the Shannon entropy of the string in bits per character
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+
+	var bits float64
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+// isLetter returns true for ASCII letters
+func isLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
@@ -0,0 +1,200 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/apistats"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+)
+
+// Config holds retry and timeout settings for REST API requests
+type Config struct {
+	MaxRetries     int
+	RetryDelay     time.Duration
+	BackoffFactor  float64
+	RequestTimeout time.Duration
+}
+
+// Client is a minimal JSON REST API client with retry logic, shared by the
+// comment backends that don't have a dedicated SDK
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	authorize  func(req *http.Request)
+	config     Config
+}
+
+// Error is returned when the API responds with a non-2xx status code
+type Error struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, strings.TrimSpace(e.Body))
+}
+
+// NewClient creates a new REST client. authorize is called on every request
+// to set authentication headers
+func NewClient(baseURL string, config Config, authorize func(req *http.Request)) *Client {
+	return &Client{
+		httpClient: &http.Client{
+			Timeout: config.RequestTimeout,
+		},
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		authorize: authorize,
+		config:    config,
+	}
+}
+
+// Do sends a request to path (relative to the base URL, or absolute) with an
+// optional JSON body, decodes a JSON response into out if it is not nil and
+// returns the response headers. Rate limited and failed requests are retried
+func (c *Client) Do(method, path string, body, out interface{}) (http.Header, error) {
+	log := logger.GetLogger()
+
+	url := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		url = c.baseURL + path
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request body: %w", err)
+		}
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			// Calculate exponential backoff delay
+			delay := time.Duration(float64(c.config.RetryDelay) * float64(attempt) * c.config.BackoffFactor)
+			log.Warnf("Retry attempt %d/%d after %v", attempt, c.config.MaxRetries, delay)
+			time.Sleep(delay)
+		}
+
+		apistats.RecordRequest(attempt > 0)
+		header, waitTime, err := c.do(method, url, payload, out)
+		if err == nil {
+			return header, nil
+		}
+		lastErr = err
+
+		if waitTime > 0 {
+			log.Warnf("Rate limited. Waiting %v before retrying", waitTime)
+			apistats.RecordRateLimitWait(waitTime)
+			time.Sleep(waitTime + time.Second) // Add 1 second buffer
+			continue
+		}
+
+		// Client errors other than rate limits won't succeed on retry
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 &&
+			apiErr.StatusCode != http.StatusTooManyRequests && apiErr.StatusCode != http.StatusRequestTimeout {
+			return nil, err
+		}
+
+		if attempt < c.config.MaxRetries {
+			log.Warnf("Request failed: %v", err)
+		}
+	}
+
+	return nil, fmt.Errorf("request failed after %d retries: %w", c.config.MaxRetries, lastErr)
+}
+
+// do performs a single request. When the request was rate limited, the time
+// to wait before retrying is returned along with the error
+func (c *Client) do(method, url string, payload []byte, out interface{}) (http.Header, time.Duration, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.authorize != nil {
+		c.authorize(req)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	apistats.RecordRateLimitHeaders(resp.Header)
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{Method: method, URL: url, StatusCode: resp.StatusCode, Body: string(respBody)}
+		return nil, rateLimitWait(resp), apiErr
+	}
+
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return resp.Header, 0, nil
+}
+
+// rateLimitWait returns how long to wait before retrying a rate limited
+// response, based on the Retry-After or rate limit reset headers. Returns 0
+// if the response was not rate limited
+func rateLimitWait(resp *http.Response) time.Duration {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+	case http.StatusForbidden:
+		// A 403 is only a rate limit when the remaining quota is exhausted
+		if resp.Header.Get("RateLimit-Remaining") != "0" && resp.Header.Get("X-RateLimit-Remaining") != "0" {
+			return 0
+		}
+	default:
+		return 0
+	}
+
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	for _, name := range []string{"RateLimit-Reset", "X-RateLimit-Reset"} {
+		if reset := resp.Header.Get(name); reset != "" {
+			if epoch, err := strconv.ParseInt(reset, 10, 64); err == nil {
+				if wait := time.Until(time.Unix(epoch, 0)); wait > 0 {
+					return wait
+				}
+			}
+		}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return time.Second
+	}
+	return 0
+}
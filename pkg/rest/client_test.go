@@ -0,0 +1,167 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/apistats"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+)
+
+func init() {
+	// Initialize logger for tests
+	logger.Initialize(logger.ErrorLevel)
+}
+
+func TestDo_DecodesResponseAndAuthorizes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("Expected Authorization header 'Bearer test-token', got %q", r.Header.Get("Authorization"))
+		}
+		if r.URL.Path != "/api/items" {
+			t.Errorf("Expected path '/api/items', got %s", r.URL.Path)
+		}
+		w.Header().Set("X-Next", "2")
+		w.Write([]byte(`{"name":"item"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/api/", Config{RequestTimeout: 30 * time.Second}, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer test-token")
+	})
+
+	var out struct {
+		Name string `json:"name"`
+	}
+	header, err := client.Do("GET", "/items", nil, &out)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+
+	if out.Name != "item" {
+		t.Errorf("Expected name 'item', got %q", out.Name)
+	}
+
+	if header.Get("X-Next") != "2" {
+		t.Errorf("Expected response headers to be returned, got %v", header)
+	}
+}
+
+func TestDo_RetriesServerErrors(t *testing.T) {
+	apistats.Reset()
+	defer apistats.Reset()
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("RateLimit-Limit", "2000")
+		w.Header().Set("RateLimit-Remaining", fmt.Sprint(2000-attempts))
+		if attempts < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, Config{MaxRetries: 3, RetryDelay: time.Millisecond, BackoffFactor: 1, RequestTimeout: 30 * time.Second}, nil)
+
+	if _, err := client.Do("DELETE", "/items/1", nil, nil); err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+
+	stats := apistats.Get()
+	if stats.Requests != 3 || stats.Retries != 2 {
+		t.Errorf("Expected 3 requests and 2 retries recorded, got %+v", stats)
+	}
+	if stats.RateLimit == nil || stats.RateLimit.Limit != 2000 || stats.RateLimit.Remaining != 1997 {
+		t.Errorf("Expected the last rate limit state recorded, got %+v", stats.RateLimit)
+	}
+}
+
+func TestDo_DoesNotRetryClientErrors(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not found"))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, Config{MaxRetries: 3, RetryDelay: time.Millisecond, BackoffFactor: 1, RequestTimeout: 30 * time.Second}, nil)
+
+	_, err := client.Do("GET", "/items/1", nil, nil)
+	if err == nil {
+		t.Fatal("Expected error but got none")
+	}
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected *Error with status 404, got %v", err)
+	}
+
+	if attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		headers    map[string]string
+		expectWait bool
+	}{
+		{
+			name:       "Retry-After on 429",
+			statusCode: http.StatusTooManyRequests,
+			headers:    map[string]string{"Retry-After": "3"},
+			expectWait: true,
+		},
+		{
+			name:       "403 with exhausted quota",
+			statusCode: http.StatusForbidden,
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     "9999999999",
+			},
+			expectWait: true,
+		},
+		{
+			name:       "403 permission error",
+			statusCode: http.StatusForbidden,
+			headers:    map[string]string{"X-RateLimit-Remaining": "10"},
+			expectWait: false,
+		},
+		{
+			name:       "Server error",
+			statusCode: http.StatusInternalServerError,
+			expectWait: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.statusCode, Header: http.Header{}}
+			for k, v := range tt.headers {
+				resp.Header.Set(k, v)
+			}
+
+			wait := rateLimitWait(resp)
+			if tt.expectWait && wait <= 0 {
+				t.Errorf("Expected a wait time, got %v", wait)
+			}
+			if !tt.expectWait && wait != 0 {
+				t.Errorf("Expected no wait time, got %v", wait)
+			}
+		})
+	}
+}
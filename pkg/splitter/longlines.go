@@ -0,0 +1,98 @@
+package splitter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LongLineMode is how lines too long to fit in a part are handled
+type LongLineMode string
+
+// Supported long line modes
+const (
+	// LongLinesWrap continues the line on the following lines, each starting
+	// with wrapMarker after the diff prefix
+	LongLinesWrap LongLineMode = "wrap"
+	// LongLinesTruncate cuts the line short, noting how much was elided
+	LongLinesTruncate LongLineMode = "truncate"
+	// LongLinesError fails the split
+	LongLinesError LongLineMode = "error"
+)
+
+// wrapMarker starts every continuation of a wrapped line
+const wrapMarker = "↪ "
+
+// elidedFormat replaces the end of a truncated line, with the size elided
+// and its unit
+const elidedFormat = " … %d %s elided"
+
+// ValidLongLineModes returns all valid long line modes
+func ValidLongLineModes() []string {
+	return []string{string(LongLinesWrap), string(LongLinesTruncate), string(LongLinesError)}
+}
+
+// ParseLongLineMode parses and validates a long line mode name
+func ParseLongLineMode(s string) (LongLineMode, error) {
+	switch mode := LongLineMode(strings.ToLower(s)); mode {
+	case LongLinesWrap, LongLinesTruncate, LongLinesError:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid long line mode: %s (valid modes: %s)", s, strings.Join(ValidLongLineModes(), ", "))
+	}
+}
+
+// fitLine returns the line as one or more lines of at most room each,
+// according to the mode. The zero mode wraps
+func fitLine(line string, room int, unit SizeUnit, mode LongLineMode) ([]string, error) {
+	size := unit.Measure(line)
+	if size <= room {
+		return []string{line}, nil
+	}
+
+	switch mode {
+	case LongLinesError:
+		return nil, fmt.Errorf("a line of %d %s doesn't fit in a part (at most %d %s)", size, unit, room, unit)
+	case LongLinesTruncate:
+		// Reserve room for the largest possible note
+		note := func(elided int) string { return fmt.Sprintf(elidedFormat, elided, unit) }
+		cut := prefixLength(line, room-unit.Measure(note(size)), unit)
+		return []string{line[:cut] + note(size-unit.Measure(line[:cut]))}, nil
+	default:
+		return wrapLine(line, room, unit), nil
+	}
+}
+
+// wrapLine cuts a line into lines of at most room each. Continuations keep
+// the diff prefix of the line, so they are highlighted the same way, and are
+// marked with wrapMarker
+func wrapLine(line string, room int, unit SizeUnit) []string {
+	prefix := ""
+	if line != "" && strings.ContainsRune("+- ", rune(line[0])) {
+		prefix = line[:1]
+	}
+	continuation := prefix + wrapMarker
+
+	cut := prefixLength(line, room, unit)
+	lines := []string{line[:cut]}
+	rest := line[cut:]
+	for rest != "" {
+		cut = prefixLength(rest, room-unit.Measure(continuation), unit)
+		lines = append(lines, continuation+rest[:cut])
+		rest = rest[cut:]
+	}
+	return lines
+}
+
+// prefixLength returns the length in bytes of the longest prefix of s, made
+// of whole runes, that measures at most limit. At least one rune is kept so
+// callers always make progress
+func prefixLength(s string, limit int, unit SizeUnit) int {
+	size := 0
+	for i, r := range s {
+		size += unit.Measure(string(r))
+		if size > limit && i > 0 {
+			return i
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,156 @@
+package splitter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+)
+
+// MaxLinkLength is the longest link to another part that fits in the room
+// reserved by Options.Navigation. Longer links are rendered as plain text
+const MaxLinkLength = 128
+
+// partLayout holds the pieces a part is assembled from, so it can be
+// rendered again once the links to the other parts are known
+type partLayout struct {
+	header string
+	body   string
+	footer string
+	// toc lists every application in the first part, nil for no table of
+	// contents
+	toc        []string
+	navigation bool
+	maxLength  int
+	renderer   *renderer
+}
+
+// tocEntry is a line of the table of contents
+type tocEntry struct {
+	name string
+	part int
+	link string
+}
+
+// renderTOC renders the table of contents listing every application and the
+// part it starts in
+func renderTOC(entries []tocEntry) string {
+	var b strings.Builder
+	b.WriteString("**Applications**\n\n")
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "- %s (part %d)\n", markdownLink(entry.name, entry.link), entry.part)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// navIndicator is the part indicator with links to the previous and next
+// parts
+func navIndicator(partNumber, totalParts int, previous, next string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\n---\n**Part %d of %d**", partNumber, totalParts)
+	if partNumber > 1 {
+		b.WriteString(" · " + markdownLink("← Previous", previous))
+	}
+	if partNumber < totalParts {
+		b.WriteString(" · " + markdownLink("Next →", next))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// markdownLink renders text linked to link, or the plain text if the link
+// is missing or longer than the room reserved for it
+func markdownLink(text, link string) string {
+	if link == "" || len(link) > MaxLinkLength {
+		return text
+	}
+	return fmt.Sprintf("[%s](%s)", text, link)
+}
+
+// tocReserve returns the room to keep free in the first part for the table
+// of contents of the given applications, for up to largest parts
+func tocReserve(apps []string, largest int, unit SizeUnit) int {
+	placeholder := strings.Repeat("x", MaxLinkLength)
+
+	entries := make([]tocEntry, 0, len(apps))
+	for _, app := range apps {
+		entries = append(entries, tocEntry{name: app, part: largest, link: placeholder})
+	}
+	return unit.Measure(renderTOC(entries))
+}
+
+// render assembles the content of a part from its layout. links holds the
+// link to every part, empty when unknown
+func (l *partLayout) render(partNumber int, results []SplitResult, links []string) (string, error) {
+	totalParts := len(results)
+	link := func(partNumber int) string {
+		if partNumber < 1 || partNumber > len(links) {
+			return ""
+		}
+		return links[partNumber-1]
+	}
+
+	content := l.header
+	if len(l.toc) > 0 {
+		entries := make([]tocEntry, 0, len(l.toc))
+		for _, app := range l.toc {
+			part := partContaining(results, app)
+			entries = append(entries, tocEntry{name: app, part: part, link: link(part)})
+		}
+		content += renderTOC(entries)
+	}
+	content += l.body + l.footer
+
+	if totalParts > 1 {
+		indicator, err := l.renderer.indicator(partNumber, totalParts, l.navigation, link(partNumber-1), link(partNumber+1))
+		if err != nil {
+			return "", err
+		}
+		content += indicator
+	}
+
+	content = withMarker(content, partNumber)
+	if size := l.renderer.unit.Measure(content); size > l.maxLength {
+		return "", fmt.Errorf("part %d is %d %s, over the max length of %d %s",
+			partNumber, size, l.renderer.unit, l.maxLength, l.renderer.unit)
+	}
+	return content, nil
+}
+
+// partContaining returns the number of the first part with content of the
+// application
+func partContaining(results []SplitResult, app string) int {
+	for _, result := range results {
+		if slices.Contains(result.Apps, app) {
+			return result.PartNumber
+		}
+	}
+	return 1
+}
+
+// Link renders the parts again with links between them: the part indicator
+// links to the previous and next parts and the first part's table of
+// contents links every application to the part containing it. links holds
+// the link to every part in order, an empty link leaves that part unlinked.
+// Parts split without Options.Navigation are returned unchanged, as are
+// parts that would not render within the max length with the links
+func Link(results []SplitResult, links []string) []SplitResult {
+	log := logger.GetLogger()
+
+	linked := make([]SplitResult, 0, len(results))
+	for _, result := range results {
+		if result.layout != nil && result.layout.navigation {
+			content, err := result.layout.render(result.PartNumber, results, links)
+			if err != nil {
+				log.Warnf("Leaving part %d unlinked: %v", result.PartNumber, err)
+			} else {
+				result.Content = content
+				result.Size = result.layout.renderer.unit.Measure(content)
+			}
+		}
+		linked = append(linked, result)
+	}
+	return linked
+}
@@ -0,0 +1,362 @@
+package splitter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/diffparser"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+)
+
+// chunk is a comment body being filled with application sections
+type chunk struct {
+	unit     SizeUnit
+	capacity int
+	size     int
+	sections []string
+	apps     []string
+	// environment is the environment of the last application section of a
+	// combined report, whose heading is in effect at the end of the chunk
+	environment string
+}
+
+// packed is the body of a part and the applications it has content of
+type packed struct {
+	body string
+	apps []string
+}
+
+// fits reports whether a section of the given size can be added to the chunk
+func (c *chunk) fits(size int) bool {
+	return c.size+size+1 <= c.capacity
+}
+
+// add appends a section of the given application, or of no application
+// when app is nil, to the chunk. Sections are separated by a blank line
+func (c *chunk) add(section string, app *diffparser.Application) {
+	c.sections = append(c.sections, section)
+	c.size += c.unit.Measure(section) + 1
+	if app == nil {
+		return
+	}
+	if name := app.QualifiedName(); !slices.Contains(c.apps, name) {
+		c.apps = append(c.apps, name)
+	}
+	c.environment = app.Environment
+}
+
+// needsHeading reports whether a section of the application added to the
+// chunk must be preceded by its environment's heading
+func (c *chunk) needsHeading(app *diffparser.Application) bool {
+	return app != nil && app.Environment != "" && app.Environment != c.environment
+}
+
+// withHeading prefixes the section with the heading of the application's
+// environment if the chunk needs it
+func (c *chunk) withHeading(section string, app *diffparser.Application) string {
+	if !c.needsHeading(app) {
+		return section
+	}
+	return environmentHeading(app.Environment) + section
+}
+
+// environmentHeading returns the heading of an environment, as it precedes
+// an application section
+func environmentHeading(environment string) string {
+	return diffparser.EnvironmentHeading(environment) + "\n\n"
+}
+
+// packer bin-packs application sections into as few chunks as possible
+type packer struct {
+	unit          SizeUnit
+	longLines     LongLineMode
+	chunks        []*chunk
+	firstCapacity int
+	capacity      int
+	// ordered keeps the sections in report order, so the applications of a
+	// combined report stay under their environment's heading
+	ordered bool
+}
+
+// newChunk starts a new chunk. The first chunk has less room because it
+// also carries the header and footer
+func (p *packer) newChunk() *chunk {
+	capacity := p.capacity
+	if len(p.chunks) == 0 {
+		capacity = p.firstCapacity
+	}
+	c := &chunk{unit: p.unit, capacity: capacity}
+	p.chunks = append(p.chunks, c)
+	return c
+}
+
+// addWhole places a section in the first chunk with enough room left, or
+// only the last one when the packer is ordered, starting a new chunk if none
+// has
+func (p *packer) addWhole(section string, app *diffparser.Application) {
+	candidates := p.chunks
+	if p.ordered {
+		candidates = p.chunks[len(p.chunks)-1:]
+	}
+	for _, c := range candidates {
+		if s := c.withHeading(section, app); c.fits(p.unit.Measure(s)) {
+			c.add(s, app)
+			return
+		}
+	}
+	c := p.newChunk()
+	c.add(c.withHeading(section, app), app)
+}
+
+// splitCost ranks the ways of splitting an application: fewer chunks first,
+// then fewer cuts that fall inside a hunk
+type splitCost struct {
+	chunks   int
+	lineCuts int
+}
+
+func (c splitCost) less(other splitCost) bool {
+	if c.chunks != other.chunks {
+		return c.chunks < other.chunks
+	}
+	return c.lineCuts < other.lineCuts
+}
+
+// splitPlan is where an application's diff lines are cut into pieces
+type splitPlan struct {
+	cost splitCost
+	// cuts are the indices of the lines starting each piece after the first
+	cuts []int
+}
+
+// addSplit spreads an application, whose diff lines are given, that doesn't
+// fit in a single chunk over consecutive chunks. It uses as few chunks as possible and, among the ways
+// of doing so, cuts at hunk boundaries wherever it can. In a combined report
+// every piece starting a chunk is preceded by its environment's heading
+func (p *packer) addSplit(app *diffparser.Application, diffLines []string, first, continued frame) error {
+	log := logger.GetLogger()
+
+	firstIn := func(c *chunk) frame { return first }
+	if app.Environment != "" {
+		heading := environmentHeading(app.Environment)
+		headed := frame{opening: heading + first.opening, closing: first.closing}
+		firstIn = func(c *chunk) frame {
+			if c.needsHeading(app) {
+				return headed
+			}
+			return first
+		}
+		continued.opening = heading + continued.opening
+	}
+
+	starts := hunkStartIndices(app, len(diffLines))
+
+	// Every line, with its newline, must fit in a chunk of its own
+	room := p.capacity - continued.overhead(p.unit) - 2
+	lines := make([]string, 0, len(diffLines))
+	hunkStarts := make([]bool, 0, len(diffLines))
+	for i, line := range diffLines {
+		fitted, err := fitLine(line, room, p.unit, p.longLines)
+		if err != nil {
+			return fmt.Errorf("application %s: %w", app.Name, err)
+		}
+		for j, segment := range fitted {
+			lines = append(lines, segment)
+			hunkStarts = append(hunkStarts, j == 0 && starts[i])
+		}
+	}
+
+	// The first piece can go into the space left in the last chunk or
+	// start a new one, whichever ends up using fewer chunks. An empty chunk
+	// is only skipped if not even the first line fits in it
+	current := p.chunks[len(p.chunks)-1]
+	section := firstIn(current)
+	plan, ok := p.planSplit(lines, hunkStarts, section, continued, current.capacity-current.size)
+	if !ok || len(current.sections) > 0 {
+		freshSection := firstIn(&chunk{})
+		fresh, _ := p.planSplit(lines, hunkStarts, freshSection, continued, p.capacity)
+		fresh.cost.chunks++
+		if !ok || fresh.cost.less(plan.cost) {
+			plan, section = fresh, freshSection
+			current = p.newChunk()
+		}
+	}
+
+	start := 0
+	for _, cut := range append(plan.cuts, len(lines)) {
+		if start > 0 {
+			current = p.newChunk()
+			section = continued
+		}
+		current.add(section.render(lines[start:cut]), app)
+		start = cut
+	}
+
+	log.Debugf("Split application %s into %d piece(s), %d cut(s) inside a hunk", app.Name, len(plan.cuts)+1, plan.cost.lineCuts)
+	return nil
+}
+
+// planSplit cuts lines into as few pieces as possible, in linear time.
+// firstRoom is the space available for the first piece, later pieces each
+// get a whole chunk. Every line must fit in a chunk on its own. Each cut is
+// moved back to the last hunk start before it when that doesn't take more
+// pieces. The second return value is false if the first line doesn't fit
+// in firstRoom
+func (p *packer) planSplit(lines []string, hunkStarts []bool, first, continued frame, firstRoom int) (splitPlan, bool) {
+	n := len(lines)
+
+	// offsets[i] is the size of the first i lines
+	offsets := make([]int, n+1)
+	for i, line := range lines {
+		offsets[i+1] = offsets[i] + p.unit.Measure(line) + 1
+	}
+
+	// The first piece is lines[0:firstEnd] at the most
+	firstEnd := 0
+	for firstEnd < n && first.overhead(p.unit)+1+offsets[firstEnd+1] <= firstRoom {
+		firstEnd++
+	}
+	if firstEnd == 0 {
+		return splitPlan{}, false
+	}
+
+	// far[i] is the end of the longest later piece starting at line i
+	room := p.capacity - continued.overhead(p.unit) - 1
+	far := make([]int, n)
+	end := 0
+	for i := range far {
+		end = max(end, i+1)
+		for end < n && offsets[end+1]-offsets[i] <= room {
+			end++
+		}
+		far[i] = end
+	}
+
+	// pieces[i] is the fewest later pieces covering lines[i:], which never
+	// grows with i
+	pieces := make([]int, n+1)
+	for i := n - 1; i >= 0; i-- {
+		pieces[i] = pieces[far[i]] + 1
+	}
+
+	// lastStart[i] is the last hunk start at or before line i, or -1
+	lastStart := make([]int, n+1)
+	last := -1
+	for i := range lastStart {
+		if i < len(hunkStarts) && hunkStarts[i] {
+			last = i
+		}
+		lastStart[i] = last
+	}
+
+	// cut returns where to cut a piece starting at start that can reach
+	// up to end, needing no more than target later pieces for the rest
+	cut := func(start, end, target int) int {
+		if h := lastStart[end]; h > start && pieces[h] <= target {
+			return h
+		}
+		return end
+	}
+
+	plan := splitPlan{}
+	next := cut(0, firstEnd, pieces[firstEnd])
+	for next < n {
+		plan.cuts = append(plan.cuts, next)
+		if next >= len(hunkStarts) || !hunkStarts[next] {
+			plan.cost.lineCuts++
+		}
+		next = cut(next, far[next], pieces[next]-1)
+	}
+	plan.cost.chunks = len(plan.cuts)
+	return plan, true
+}
+
+// packChunks bin-packs the report body into chunks. Applications are kept
+// whole whenever they fit in a chunk. firstCapacity is the room available
+// in the first chunk and capacity the room in the others, both measured in
+// the options' unit. With PerApp, every application starts a chunk of its
+// own instead
+func packChunks(report *diffparser.Report, firstCapacity, capacity int, options Options, r *renderer) ([]packed, error) {
+	log := logger.GetLogger()
+
+	perApp := options.PerApp
+	p := &packer{unit: options.Unit, longLines: options.LongLines, firstCapacity: firstCapacity, capacity: capacity, ordered: report.HasEnvironments()}
+	p.newChunk()
+
+	for _, app := range report.Apps {
+		if perApp && len(p.chunks[len(p.chunks)-1].sections) > 0 {
+			p.newChunk()
+		}
+
+		first, continued, err := r.frames(app)
+		if err != nil {
+			return nil, err
+		}
+
+		// Measure the section before rendering it, since a large
+		// application is split instead. A fresh chunk has room for the
+		// section with its heading, if any
+		diffLines := app.DiffLines()
+		size := p.unit.Measure((&chunk{}).withHeading("", app)) + first.overhead(p.unit)
+		for _, line := range diffLines {
+			size += p.unit.Measure(line) + 1
+		}
+		switch {
+		case perApp && size+1 <= capacity:
+			section := first.render(diffLines)
+			current := p.chunks[len(p.chunks)-1]
+			if !current.fits(p.unit.Measure(current.withHeading(section, app))) {
+				current = p.newChunk()
+			}
+			current.add(current.withHeading(section, app), app)
+		case !perApp && size+1 <= capacity:
+			p.addWhole(first.render(diffLines), app)
+		default:
+			if err := p.addSplit(app, diffLines, first, continued); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, line := range slices.Concat(report.Other, report.Warnings) {
+		fitted, err := fitLine(line, capacity-2, p.unit, p.longLines)
+		if err != nil {
+			return nil, err
+		}
+		for _, segment := range fitted {
+			p.addWhole(segment+"\n", nil)
+		}
+	}
+
+	// The first chunk is kept even when empty, as it is where the header
+	// and footer go
+	chunks := make([]packed, 0, len(p.chunks))
+	for i, c := range p.chunks {
+		if i > 0 && len(c.sections) == 0 {
+			continue
+		}
+		body := strings.Join(c.sections, "\n")
+		chunks = append(chunks, packed{body: body, apps: c.apps})
+		log.Debugf("Created chunk %d with size %d %s", len(chunks)-1, p.unit.Measure(body), p.unit)
+	}
+
+	return chunks, nil
+}
+
+// hunkStartIndices reports, for each of the application's count diff lines,
+// whether it is a skipped-line marker starting a hunk
+func hunkStartIndices(app *diffparser.Application, count int) []bool {
+	starts := make([]bool, count)
+	index := 0
+	if app.Header != "" {
+		index++
+	}
+	for _, hunk := range app.Hunks {
+		if hunk.Skipped != nil {
+			starts[index] = true
+			index++
+		}
+		index += len(hunk.Lines)
+	}
+	return starts
+}
@@ -3,235 +3,341 @@ package splitter
 import (
 	"bufio"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/diffparser"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/input"
 	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
 )
 
+// markerFormat is the hidden HTML comment embedded in every part so comments
+// posted by previous runs can be found and updated
+const markerFormat = "<!-- argocd-diff-preview-pr-comment:part=%d -->"
+
+// markdownMarkerFormat is the same marker written as an empty markdown link
+// reference definition, for renderers that display HTML comments as text
+const markdownMarkerFormat = "[//]: # (argocd-diff-preview-pr-comment:part=%d)"
+
+var markerRegexp = regexp.MustCompile(`(?:<!-- argocd-diff-preview-pr-comment:part=(\d+) -->|\[//\]: # \(argocd-diff-preview-pr-comment:part=(\d+)\))`)
+
+// SizeUnit is the unit a destination measures its length limit in
+type SizeUnit string
+
+// Supported size units
+const (
+	// SizeBytes counts the bytes of the UTF-8 encoded content
+	SizeBytes SizeUnit = "bytes"
+	// SizeRunes counts characters (Unicode code points), as GitHub does
+	SizeRunes SizeUnit = "runes"
+)
+
+// ValidSizeUnits returns all valid size units
+func ValidSizeUnits() []string {
+	return []string{string(SizeBytes), string(SizeRunes)}
+}
+
+// ParseSizeUnit parses and validates a size unit name
+func ParseSizeUnit(s string) (SizeUnit, error) {
+	switch unit := SizeUnit(strings.ToLower(s)); unit {
+	case SizeBytes, SizeRunes:
+		return unit, nil
+	default:
+		return "", fmt.Errorf("invalid size unit: %s (valid units: %s)", s, strings.Join(ValidSizeUnits(), ", "))
+	}
+}
+
+// Measure returns the size of s in the unit. The zero value measures bytes
+func (u SizeUnit) Measure(s string) int {
+	if u == SizeRunes {
+		return utf8.RuneCountInString(s)
+	}
+	return len(s)
+}
+
+// String returns the unit name, defaulting to bytes
+func (u SizeUnit) String() string {
+	if u == "" {
+		return string(SizeBytes)
+	}
+	return string(u)
+}
+
 // SplitResult represents a split part of the diff
 type SplitResult struct {
 	PartNumber int
 	TotalParts int
 	Content    string
-	Size       int
+	// Size is the size of Content, in Unit
+	Size int
+	// Unit is the unit the split was measured in
+	Unit SizeUnit
+	// Apps are the names of the applications with content in this part
+	Apps []string
+
+	layout *partLayout
+}
+
+// Options configures how a diff file is split
+type Options struct {
+	// MaxLength is the maximum size of a part, in Unit
+	MaxLength int
+	// PerApp puts every application in its own part(s). The header and
+	// footer are only included in the first part
+	PerApp bool
+	// Unit is what MaxLength is measured in, bytes by default
+	Unit SizeUnit
+	// LongLines is how lines too long to fit in a part are handled, wrapped
+	// by default
+	LongLines LongLineMode
+	// Navigation reserves room for links between parts and a table of
+	// contents in the first part, filled in by Link once the parts are posted
+	Navigation bool
+	// Templates customise the layout of the parts, nil for the default
+	// layout. Their output counts towards MaxLength
+	Templates *Templates
+	// PRNumber and CommitSHA are made available to the templates
+	PRNumber  int
+	CommitSHA string
 }
 
 // SplitDiffFile splits a markdown diff file if it exceeds the max length
 // Returns the list of split results
 func SplitDiffFile(inputPath string, maxLength int) ([]SplitResult, error) {
+	return Split(inputPath, Options{MaxLength: maxLength})
+}
+
+// Split splits a markdown diff file according to the options. The path may
+// be input.Stdin, and the file may be compressed, see input.Open
+func Split(inputPath string, options Options) ([]SplitResult, error) {
+	reader, err := input.Open(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+	defer reader.Close()
+
+	return SplitReader(reader, options)
+}
+
+// SplitReader splits the markdown diff read from reader according to the
+// options
+func SplitReader(reader io.Reader, options Options) ([]SplitResult, error) {
 	log := logger.GetLogger()
 
-	// Read the entire file
-	content, err := os.ReadFile(inputPath)
+	maxLength := options.MaxLength
+	unit := options.Unit
+	if maxLength <= 0 {
+		return nil, fmt.Errorf("max length too small to split file: %d %s", maxLength, unit)
+	}
+
+	// Read the entire diff
+	raw, err := io.ReadAll(reader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read input file: %w", err)
 	}
 
-	// If the file is within the limit, return the original path
-	if len(content) <= maxLength {
-		log.Infof("File size (%d bytes) is within the limit (%d bytes). No splitting needed.", len(content), maxLength)
+	// Parts are only cut between lines, so valid input keeps every part valid
+	content := string(raw)
+	if !utf8.ValidString(content) {
+		log.Warn("Input file is not valid UTF-8, invalid bytes were replaced")
+		content = strings.ToValidUTF8(content, "\uFFFD")
+	}
+
+	// Templates render every part from the parsed report
+	report, err := diffparser.Parse(content)
+	if err != nil && (options.PerApp || options.Templates != nil) {
+		return nil, fmt.Errorf("failed to parse diff file: %w", err)
+	}
+
+	// If the file is within the limit, return the original content
+	single := withMarker(content, 1)
+	if unit.Measure(single) <= maxLength && (!options.PerApp || len(report.Apps) <= 1) && options.Templates == nil {
+		log.Infof("File size (%d %s) is within the limit (%d %s). No splitting needed.", unit.Measure(content), unit, maxLength, unit)
+		var apps []string
+		if report != nil {
+			apps = report.AppNames()
+		}
 		return []SplitResult{
 			{
 				PartNumber: 1,
 				TotalParts: 1,
-				Content:    string(content),
-				Size:       len(content),
+				Content:    single,
+				Size:       unit.Measure(single),
+				Unit:       unit,
+				Apps:       apps,
 			},
 		}, nil
 	}
 
-	log.Infof("File size (%d bytes) exceeds limit (%d bytes). Splitting file...", len(content), maxLength)
+	switch {
+	case options.PerApp:
+		log.Infof("Splitting file by application (%d applications)...", len(report.Apps))
+	case unit.Measure(single) > maxLength:
+		log.Infof("File size (%d %s) exceeds limit (%d %s). Splitting file...", unit.Measure(content), unit, maxLength, unit)
+	}
 
-	// Parse the file structure
-	lines := strings.Split(string(content), "\n")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse diff file: %w", err)
+	}
 
-	// Find the header (everything before first <details>)
-	headerEndIdx := -1
-	for i, line := range lines {
-		if strings.Contains(line, "<details>") {
-			headerEndIdx = i - 1
-			break
-		}
+	if len(report.Apps) == 0 && options.Templates == nil {
+		return nil, fmt.Errorf("could not find any application sections in the file")
 	}
 
-	if headerEndIdx == -1 {
-		return nil, fmt.Errorf("could not find <details> tag in the file")
+	r := newRenderer(report, options)
+	header, err := r.header(report)
+	if err != nil {
+		return nil, err
+	}
+	footer, err := r.footer(report)
+	if err != nil {
+		return nil, err
 	}
 
-	header := strings.Join(lines[:headerEndIdx+1], "\n") + "\n"
+	// The part indicator and marker grow with the number of parts, which is
+	// only known after packing. Reserve room for a number of digits and
+	// pack again with more if the parts outgrow them. No part holds more
+	// than maxLength, which gives the fewest digits worth trying
+	digits := len(strconv.Itoa(max(1, unit.Measure(content)/maxLength)))
+	for ; ; digits++ {
+		largest := int(math.Pow10(digits)) - 1
+		indicator, err := r.indicatorReserve(largest, options.Navigation)
+		if err != nil {
+			return nil, err
+		}
 
-	// Find the footer (stats at the end)
-	footerStartIdx := -1
-	for i := len(lines) - 1; i >= 0; i-- {
-		if strings.Contains(lines[i], "_Stats_:") {
-			footerStartIdx = i
-			break
+		layout := &partLayout{header: header, footer: footer, navigation: options.Navigation, maxLength: maxLength, renderer: r}
+		toc := 0
+		if options.Navigation {
+			toc = tocReserve(report.AppNames(), largest, unit)
+			// A table of contents crowding out the diff isn't worth it
+			if toc > maxLength/2 {
+				log.Infof("Too many applications for a table of contents, leaving it out")
+				toc = 0
+			} else {
+				layout.toc = report.AppNames()
+			}
 		}
-	}
+		reserve := indicator + unit.Measure(PartMarker(largest)) + 1
 
-	footer := ""
-	if footerStartIdx != -1 {
-		footer = "\n" + strings.Join(lines[footerStartIdx:], "\n")
-	}
+		// For the first chunk: maxLength - header - footer - toc - reserve
+		// For subsequent chunks: maxLength - reserve
+		effectiveMaxLength := maxLength - unit.Measure(header) - unit.Measure(footer) - toc - reserve
+		if effectiveMaxLength < 100 {
+			return nil, fmt.Errorf("max length too small to split file (effective content space: %d %s)", effectiveMaxLength, unit)
+		}
 
-	// Reserve space for part indicator (e.g., "\n\n---\n**Part 999 of 999**\n")
-	// This accounts for the maximum possible part numbers (3 digits each)
-	// Total: 2 newlines + 3 chars (---) + 1 newline + 2 stars + "Part " (5) +
-	//        3 digits + " of " (4) + 3 digits + 2 stars + 1 newline = ~25 bytes
-	// Use 35 to be extra safe
-	partIndicatorMaxSize := 35
-
-	// Split the content into chunks
-	// For the first chunk: maxLength - header - footer - partIndicator
-	// For subsequent chunks: maxLength - partIndicator
-	// We use the more restrictive first chunk size for all chunks to keep logic simple
-	effectiveMaxLength := maxLength - len(header) - len(footer) - partIndicatorMaxSize
-	if effectiveMaxLength < 100 {
-		return nil, fmt.Errorf("max length too small to split file (effective content space: %d bytes)", effectiveMaxLength)
-	}
+		chunks, err := packChunks(report, effectiveMaxLength, maxLength-reserve, options, r)
+		if err != nil {
+			return nil, err
+		}
 
-	chunks := splitIntoChunks(lines, headerEndIdx+1, footerStartIdx, effectiveMaxLength)
+		if len(chunks) > largest {
+			log.Debugf("Split into %d parts, more than %d digits allow for, packing again", len(chunks), digits)
+			continue
+		}
 
-	if len(chunks) == 0 {
-		return nil, fmt.Errorf("failed to split file into valid chunks")
+		return renderParts(layout, chunks)
 	}
+}
 
-	totalParts := len(chunks)
+// partIndicator returns the "Part X of Y" line appended to every part of a
+// split diff
+func partIndicator(partNumber, totalParts int) string {
+	return fmt.Sprintf("\n\n---\n**Part %d of %d**\n", partNumber, totalParts)
+}
 
-	// Build split results
-	results := make([]SplitResult, 0, len(chunks))
+// renderParts assembles the final content of every part and checks that it
+// is within the max length. first is the layout of the first part, which
+// carries the header, footer and table of contents
+func renderParts(first *partLayout, chunks []packed) ([]SplitResult, error) {
+	totalParts := len(chunks)
+	results := make([]SplitResult, 0, totalParts)
 
 	for i, chunk := range chunks {
-		partIndicator := ""
-		if totalParts > 1 {
-			partIndicator = fmt.Sprintf("\n\n---\n**Part %d of %d**\n", i+1, totalParts)
-		}
-
-		var fileContent string
+		layout := &partLayout{body: chunk.body, navigation: first.navigation, maxLength: first.maxLength, renderer: first.renderer}
 		if i == 0 {
-			// First part: include header, footer, and part indicator
-			fileContent = header + chunk + footer + partIndicator
-		} else {
-			// Subsequent parts: only chunk content and part indicator
-			fileContent = chunk + partIndicator
-		}
-
-		// Verify size doesn't exceed max length
-		if len(fileContent) > maxLength {
-			log.Warnf("File part %d size (%d bytes) exceeds max length (%d bytes) by %d bytes",
-				i+1, len(fileContent), maxLength, len(fileContent)-maxLength)
+			// First part: include header and footer
+			layout.header, layout.footer = first.header, first.footer
+			if totalParts > 1 {
+				layout.toc = first.toc
+			}
 		}
 
 		results = append(results, SplitResult{
 			PartNumber: i + 1,
 			TotalParts: totalParts,
-			Content:    fileContent,
-			Size:       len(fileContent),
+			Apps:       chunk.apps,
+			layout:     layout,
 		})
 	}
 
-	return results, nil
-}
-
-// splitIntoChunks splits the content between start and end indices into chunks
-// maxChunkSize is the maximum size for each chunk's content (not including header/footer)
-func splitIntoChunks(lines []string, startIdx, endIdx, maxChunkSize int) []string {
-	log := logger.GetLogger()
-
-	if endIdx == -1 {
-		endIdx = len(lines)
-	}
-
-	chunks := make([]string, 0)
-	currentChunk := make([]string, 0)
-	currentSize := 0
-
-	// Track if we're inside an application's <details> block
-	insideDetails := false
-	currentApp := ""
-
-	for i := startIdx; i < endIdx; i++ {
-		line := lines[i]
-
-		// Check if we're starting a new application details block
-		if strings.Contains(line, "<details>") {
-			insideDetails = true
-			// Extract application name from the next line (summary)
-			if i+1 < endIdx && strings.Contains(lines[i+1], "<summary>") {
-				currentApp = extractAppName(lines[i+1])
-			}
+	for i := range results {
+		result := &results[i]
+		content, err := result.layout.render(result.PartNumber, results, nil)
+		if err != nil {
+			return nil, err
 		}
+		result.Content = content
+		result.Size = first.renderer.unit.Measure(content)
+		result.Unit = first.renderer.unit
+	}
 
-		lineSize := len(line) + 1 // +1 for newline
-
-		// Reserve space for closing tags if we're inside a details block
-		closingTagsSize := 0
-		if insideDetails {
-			closingTagsSize = len("\n```\n\n</details>\n")
-		}
+	return results, nil
+}
 
-		// Check if adding this line would exceed the limit
-		if currentSize+lineSize+closingTagsSize > maxChunkSize && len(currentChunk) > 0 {
-			// Close the current details block if we're inside one
-			chunkContent := strings.Join(currentChunk, "\n")
-			if insideDetails {
-				chunkContent += "\n```\n\n</details>\n"
-			}
-			chunks = append(chunks, chunkContent)
-			log.Debugf("Created chunk %d with size %d", len(chunks)-1, len(chunkContent))
-
-			// Start new chunk with continuation notice if we're splitting an app
-			currentChunk = make([]string, 0)
-			if insideDetails && currentApp != "" {
-				continuationHeader := fmt.Sprintf("<details>\n<summary>%s (continuation...)</summary>\n<br>\n\n```diff", currentApp)
-				currentChunk = append(currentChunk, continuationHeader)
-				currentSize = len(continuationHeader) + 1
-			} else {
-				currentSize = 0
-			}
-		}
+// PartMarker returns the hidden marker that identifies the given part number
+func PartMarker(partNumber int) string {
+	return fmt.Sprintf(markerFormat, partNumber)
+}
 
-		currentChunk = append(currentChunk, line)
-		currentSize += lineSize
+// MarkdownPartMarker returns the part marker in its markdown-only form
+func MarkdownPartMarker(partNumber int) string {
+	return fmt.Sprintf(markdownMarkerFormat, partNumber)
+}
 
-		// Check if we're closing a details block
-		if strings.Contains(line, "</details>") {
-			insideDetails = false
-			currentApp = ""
-		}
+// ParsePartMarker extracts the part number from a comment body containing a
+// part marker in either form. The second return value is false if no marker
+// is present
+func ParsePartMarker(content string) (int, bool) {
+	match := markerRegexp.FindStringSubmatch(content)
+	if match == nil {
+		return 0, false
 	}
 
-	// Add the last chunk
-	if len(currentChunk) > 0 {
-		chunkContent := strings.Join(currentChunk, "\n")
-		chunks = append(chunks, chunkContent)
-		log.Debugf("Created final chunk %d with size %d", len(chunks)-1, len(chunkContent))
+	partNumber, err := strconv.Atoi(match[1] + match[2])
+	if err != nil {
+		return 0, false
 	}
-
-	return chunks
+	return partNumber, true
 }
 
-// extractAppName extracts the application name from a summary line
-func extractAppName(summaryLine string) string {
-	// Format: <summary>app-name (path)</summary>
-	start := strings.Index(summaryLine, "<summary>")
-	if start == -1 {
-		return ""
+// withMarker appends the part marker on its own line at the end of content
+func withMarker(content string, partNumber int) string {
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
 	}
-	start += len("<summary>")
+	return content + PartMarker(partNumber)
+}
 
-	end := strings.Index(summaryLine, "</summary>")
-	if end == -1 {
-		return ""
+// Body returns the content of the part without what only comments need:
+// the table of contents, the part indicator and the hidden part marker. It
+// is for destinations that are written once rather than updated in place
+func (r SplitResult) Body() string {
+	if r.layout == nil {
+		return strings.TrimSuffix(r.Content, PartMarker(r.PartNumber))
 	}
-
-	return strings.TrimSpace(summaryLine[start:end])
+	return r.layout.header + r.layout.body + r.layout.footer
 }
 
-// CountFileSize returns the size of a file in bytes
+// CountFileSize returns the size of a file in bytes, once decompressed
 func CountFileSize(filePath string) (int, error) {
-	content, err := os.ReadFile(filePath)
+	content, err := input.ReadFile(filePath)
 	if err != nil {
 		return 0, fmt.Errorf("failed to read file: %w", err)
 	}
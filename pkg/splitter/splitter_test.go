@@ -1,11 +1,19 @@
 package splitter
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/diffparser"
 	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
 )
 
@@ -60,11 +68,12 @@ _Stats_:
 		t.Errorf("Expected part 1 of 1, got part %d of %d", results[0].PartNumber, results[0].TotalParts)
 	}
 
-	if results[0].Size != len(content) {
-		t.Errorf("Expected size %d, got %d", len(content), results[0].Size)
+	expectedContent := content + PartMarker(1)
+	if results[0].Size != len(expectedContent) {
+		t.Errorf("Expected size %d, got %d", len(expectedContent), results[0].Size)
 	}
 
-	if results[0].Content != content {
+	if results[0].Content != expectedContent {
 		t.Error("Content mismatch in single result")
 	}
 }
@@ -169,6 +178,11 @@ _Stats_:
 			t.Errorf("Result %d missing part indicator %q", i, expectedPartIndicator)
 		}
 
+		// All parts should end with their hidden part marker
+		if partNumber, ok := ParsePartMarker(result.Content); !ok || partNumber != i+1 {
+			t.Errorf("Result %d has marker for part %d (found: %v), expected part %d", i, partNumber, ok, i+1)
+		}
+
 		// Verify content is not empty
 		if len(result.Content) < 10 {
 			t.Errorf("Result %d content too short", i)
@@ -176,6 +190,645 @@ _Stats_:
 	}
 }
 
+func TestSplitDiffFile_TagsInsideDiff(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Manifest values that look like the markdown structure must not be
+	// mistaken for section boundaries or the stats footer
+	diffLines := ""
+	for i := 1; i <= 30; i++ {
+		diffLines += fmt.Sprintf("+  line-%d: <details> </details> _Stats_:\n", i)
+	}
+
+	content := `## Argo CD Diff Preview
+
+Summary:
+` + "```yaml" + `
+Total: 1 files changed
+` + "```" + `
+
+<details>
+<summary>app-name (path/to/app)</summary>
+<br>
+
+` + "```diff" + `
+` + diffLines + "```" + `
+
+</details>
+
+_Stats_:
+[Applications: 1]
+`
+
+	testFile := filepath.Join(tmpDir, "test-tags.md")
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	results, err := SplitDiffFile(testFile, 1000)
+	if err != nil {
+		t.Fatalf("SplitDiffFile failed: %v", err)
+	}
+	if len(results) < 2 {
+		t.Fatalf("Expected at least 2 results, got %d", len(results))
+	}
+
+	total := 0
+	for i, result := range results {
+		if result.Size > 1000 {
+			t.Errorf("Result %d size %d exceeds max length 1000", i, result.Size)
+		}
+
+		// Every part must be a well-formed diff on its own
+		report, err := diffparser.Parse(result.Content)
+		if err != nil {
+			t.Fatalf("Result %d does not parse: %v", i, err)
+		}
+		if len(report.Apps) != 1 {
+			t.Errorf("Result %d has %d application sections, want 1", i, len(report.Apps))
+		}
+		if i > 0 && report.Stats != nil {
+			t.Errorf("Result %d should not have footer (_Stats_:)", i)
+		}
+		for _, line := range report.Apps[0].DiffLines() {
+			if strings.HasPrefix(line, "+  line-") {
+				total++
+			}
+		}
+	}
+
+	if total != 30 {
+		t.Errorf("Parts contain %d diff lines in total, want 30", total)
+	}
+}
+
+// writeAppsDiff creates a diff file with an application section per entry of
+// hunks, each hunk holding the given number of diff lines
+func writeAppsDiff(t testing.TB, dir string, hunks ...[]int) string {
+	t.Helper()
+
+	var b strings.Builder
+	b.WriteString("## Argo CD Diff Preview\n\nSummary:\n```yaml\nTotal: changes\n```\n\n")
+	for i, appHunks := range hunks {
+		fmt.Fprintf(&b, "<details>\n<summary>app-%d (apps/app-%d.yaml)</summary>\n<br>\n\n```diff\n", i+1, i+1)
+		fmt.Fprintf(&b, "@@ Application modified: app-%d (apps/app-%d.yaml) @@\n", i+1, i+1)
+		for h, lines := range appHunks {
+			if h > 0 {
+				fmt.Fprintf(&b, "@@ skipped 10 lines (%d -> %d) @@\n", h*100, h*100+9)
+			}
+			for j := 0; j < lines; j++ {
+				fmt.Fprintf(&b, "+  key-%d-%d: value\n", h, j)
+			}
+		}
+		b.WriteString("```\n\n</details>\n\n")
+	}
+	b.WriteString("_Stats_:\n[Applications: many]\n")
+
+	path := filepath.Join(dir, "diff.md")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatalf("Failed to create diff file: %v", err)
+	}
+	return path
+}
+
+func TestSplitDiffFile_BinPacking(t *testing.T) {
+	// app-2 doesn't fit next to app-1 and fills a part on its own, but app-3
+	// fits next to app-1. Filling parts in order would need three
+	diffFile := writeAppsDiff(t, t.TempDir(), []int{25}, []int{55}, []int{8})
+
+	results, err := SplitDiffFile(diffFile, 1400)
+	if err != nil {
+		t.Fatalf("SplitDiffFile failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	seen := make(map[string]int)
+	for i, result := range results {
+		if result.Size > 1400 {
+			t.Errorf("Result %d size %d exceeds max length 1400", i, result.Size)
+		}
+		if strings.Contains(result.Content, "(continuation...)") {
+			t.Errorf("Result %d splits an application that fits in a single part", i)
+		}
+
+		report, err := diffparser.Parse(result.Content)
+		if err != nil {
+			t.Fatalf("Result %d does not parse: %v", i, err)
+		}
+		for _, app := range report.Apps {
+			seen[app.Name] = i + 1
+		}
+	}
+
+	want := map[string]int{"app-1": 1, "app-2": 2, "app-3": 1}
+	for name, part := range want {
+		if seen[name] != part {
+			t.Errorf("%s is in part %d, want part %d", name, seen[name], part)
+		}
+	}
+}
+
+func TestSplit_Environments(t *testing.T) {
+	// In dev, app-3 would fit next to app-1 if the applications could be
+	// reordered. In prod, app-2 is too large for a single part
+	var reports []diffparser.LabeledReport
+	for _, env := range []struct {
+		label string
+		hunks [][]int
+	}{
+		{"dev", [][]int{{25}, {55}, {8}}},
+		{"prod", [][]int{{5}, {30, 30, 30}}},
+	} {
+		report, err := diffparser.ParseFile(writeAppsDiff(t, t.TempDir(), env.hunks...))
+		if err != nil {
+			t.Fatalf("ParseFile failed: %v", err)
+		}
+		reports = append(reports, diffparser.LabeledReport{Label: env.label, Report: report})
+	}
+
+	diffFile := filepath.Join(t.TempDir(), "combined.md")
+	if err := os.WriteFile(diffFile, []byte(diffparser.Combine(reports).Render()), 0644); err != nil {
+		t.Fatalf("Failed to create diff file: %v", err)
+	}
+
+	results, err := Split(diffFile, Options{MaxLength: 2000, Unit: SizeRunes, Navigation: true})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	var apps []string
+	continued := false
+	for i, result := range results {
+		continued = continued || strings.Contains(result.Content, "(continuation...)")
+		if result.Size > 2000 {
+			t.Errorf("Result %d size %d exceeds max length 2000", i, result.Size)
+		}
+		for _, app := range result.Apps {
+			if !slices.Contains(apps, app) {
+				apps = append(apps, app)
+			}
+		}
+
+		// Every section is under the heading of its environment, repeated in
+		// every part
+		report, err := diffparser.Parse(result.Content)
+		if err != nil {
+			t.Fatalf("Result %d does not parse: %v", i, err)
+		}
+		for _, app := range report.Apps {
+			if !strings.HasPrefix(app.Name, "app-") || !slices.Contains(result.Apps, app.Environment+"/"+strings.Fields(app.Name)[0]) {
+				t.Errorf("Result %d has %s under the heading of %q", i, app.Name, app.Environment)
+			}
+		}
+	}
+
+	expected := []string{"dev/app-1", "dev/app-2", "dev/app-3", "prod/app-1", "prod/app-2"}
+	if !slices.Equal(apps, expected) {
+		t.Errorf("Expected the applications in report order %q, got %q", expected, apps)
+	}
+	if !continued {
+		t.Error("Expected prod/app-2 to be split over several parts")
+	}
+}
+
+func TestSplitDiffFile_HunkBoundaries(t *testing.T) {
+	// A single application too large for one part, made of hunks that each
+	// fit comfortably
+	diffFile := writeAppsDiff(t, t.TempDir(), []int{12, 12, 12, 12, 12, 12})
+
+	results, err := SplitDiffFile(diffFile, 1200)
+	if err != nil {
+		t.Fatalf("SplitDiffFile failed: %v", err)
+	}
+	if len(results) < 2 {
+		t.Fatalf("Expected at least 2 results, got %d", len(results))
+	}
+
+	for i, result := range results {
+		if result.Size > 1200 {
+			t.Errorf("Result %d size %d exceeds max length 1200", i, result.Size)
+		}
+
+		report, err := diffparser.Parse(result.Content)
+		if err != nil {
+			t.Fatalf("Result %d does not parse: %v", i, err)
+		}
+
+		// Continuations must start at a hunk's skipped-line marker
+		if i > 0 {
+			lines := report.Apps[0].DiffLines()
+			if len(lines) == 0 || !strings.HasPrefix(lines[0], "@@ skipped") {
+				t.Errorf("Result %d does not start at a hunk boundary: %q", i, lines)
+			}
+		}
+	}
+}
+
+func TestSplitDiffFile_FewerParts(t *testing.T) {
+	// Splitting line by line with the first part's space for every part
+	// used to take 15 parts at this size
+	results, err := SplitDiffFile("../../testing/too-long-diff.md", 5000)
+	if err != nil {
+		t.Fatalf("SplitDiffFile failed: %v", err)
+	}
+	if len(results) >= 15 {
+		t.Errorf("Expected fewer than 15 parts, got %d", len(results))
+	}
+
+	for i, result := range results {
+		if result.Size > 5000 {
+			t.Errorf("Result %d size %d exceeds max length 5000", i, result.Size)
+		}
+		if _, err := diffparser.Parse(result.Content); err != nil {
+			t.Errorf("Result %d does not parse: %v", i, err)
+		}
+	}
+}
+
+func TestSplit_PerApp(t *testing.T) {
+	// The whole file fits in a single part, but every application still
+	// gets its own
+	diffFile := writeAppsDiff(t, t.TempDir(), []int{5}, []int{5}, []int{5})
+
+	results, err := Split(diffFile, Options{MaxLength: 65536, PerApp: true})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+
+	for i, result := range results {
+		report, err := diffparser.Parse(result.Content)
+		if err != nil {
+			t.Fatalf("Result %d does not parse: %v", i, err)
+		}
+		if len(report.Apps) != 1 || report.Apps[0].Name != fmt.Sprintf("app-%d", i+1) {
+			t.Errorf("Result %d has applications %v, want only app-%d", i, report.Apps, i+1)
+		}
+
+		// Only the first part has the summary and stats
+		hasHeader := report.Summary != nil && report.Stats != nil
+		if hasHeader != (i == 0) {
+			t.Errorf("Result %d has header and footer: %v", i, hasHeader)
+		}
+
+		if partNumber, ok := ParsePartMarker(result.Content); !ok || partNumber != i+1 {
+			t.Errorf("Result %d has marker for part %d, expected part %d", i, partNumber, i+1)
+		}
+	}
+}
+
+func TestSplit_PerAppLargeApp(t *testing.T) {
+	// app-2 alone exceeds the max length and is continued in the next part
+	diffFile := writeAppsDiff(t, t.TempDir(), []int{5}, []int{20, 20, 20, 20}, []int{5})
+
+	results, err := Split(diffFile, Options{MaxLength: 1200, PerApp: true})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(results) < 4 {
+		t.Fatalf("Expected at least 4 results, got %d", len(results))
+	}
+
+	var names []string
+	for i, result := range results {
+		if result.Size > 1200 {
+			t.Errorf("Result %d size %d exceeds max length 1200", i, result.Size)
+		}
+
+		report, err := diffparser.Parse(result.Content)
+		if err != nil {
+			t.Fatalf("Result %d does not parse: %v", i, err)
+		}
+		if len(report.Apps) != 1 {
+			t.Fatalf("Result %d has %d applications, want 1", i, len(report.Apps))
+		}
+		names = append(names, report.Apps[0].Name)
+	}
+
+	if names[0] != "app-1" || names[len(names)-1] != "app-3" {
+		t.Errorf("Unexpected application order: %q", names)
+	}
+	for _, name := range names[1 : len(names)-1] {
+		if !strings.HasPrefix(name, "app-2") {
+			t.Errorf("Unexpected application order: %q", names)
+		}
+	}
+}
+
+func TestSplit_SizeUnits(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Every diff line holds multi-byte characters, so measuring in runes
+	// fits more lines in each part than measuring in bytes
+	var b strings.Builder
+	b.WriteString("## Argo CD Diff Preview\n\nSummary:\n```yaml\nTotal: 1 files changed\n```\n\n")
+	b.WriteString("<details>\n<summary>app-name (path/to/app)</summary>\n<br>\n\n```diff\n")
+	for i := 1; i <= 60; i++ {
+		fmt.Fprintf(&b, "+  note-%d: ⚠️ überprüfen 日本語テキスト\n", i)
+	}
+	b.WriteString("```\n\n</details>\n\n⚠️⚠️⚠️ Diff exceeds max length\n\n_Stats_:\n[Applications: 1]\n")
+
+	testFile := filepath.Join(tmpDir, "test-utf8.md")
+	if err := os.WriteFile(testFile, []byte(b.String()), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		unit    SizeUnit
+		measure func(string) int
+	}{
+		{unit: SizeBytes, measure: func(s string) int { return len(s) }},
+		{unit: SizeRunes, measure: utf8.RuneCountInString},
+	}
+
+	parts := make(map[SizeUnit]int)
+	for _, tt := range tests {
+		t.Run(string(tt.unit), func(t *testing.T) {
+			results, err := Split(testFile, Options{MaxLength: 1000, Unit: tt.unit})
+			if err != nil {
+				t.Fatalf("Split failed: %v", err)
+			}
+			parts[tt.unit] = len(results)
+
+			for i, result := range results {
+				if !utf8.ValidString(result.Content) {
+					t.Errorf("Result %d is not valid UTF-8", i)
+				}
+				if result.Size != tt.measure(result.Content) {
+					t.Errorf("Result %d Size %d doesn't match its content (%d)", i, result.Size, tt.measure(result.Content))
+				}
+				if result.Size > 1000 {
+					t.Errorf("Result %d size %d exceeds max length 1000", i, result.Size)
+				}
+			}
+		})
+	}
+
+	if parts[SizeRunes] >= parts[SizeBytes] {
+		t.Errorf("Expected fewer parts measuring runes (%d) than bytes (%d)", parts[SizeRunes], parts[SizeBytes])
+	}
+}
+
+func TestSplit_InvalidUTF8(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test-invalid.md")
+	if err := os.WriteFile(testFile, []byte("## Diff\n\n+value: \xff\xfe\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	results, err := Split(testFile, Options{MaxLength: 1000, Unit: SizeRunes})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if !utf8.ValidString(results[0].Content) {
+		t.Errorf("Result is not valid UTF-8: %q", results[0].Content)
+	}
+}
+
+func TestSplitReader(t *testing.T) {
+	content, err := os.ReadFile("../../testing/too-long-diff.md")
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+
+	expected, err := Split("../../testing/too-long-diff.md", Options{MaxLength: 20000, Unit: SizeRunes})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	results, err := SplitReader(strings.NewReader(string(content)), Options{MaxLength: 20000, Unit: SizeRunes})
+	if err != nil {
+		t.Fatalf("SplitReader failed: %v", err)
+	}
+
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d parts, got %d", len(expected), len(results))
+	}
+	for i := range results {
+		if results[i].Content != expected[i].Content {
+			t.Errorf("Part %d differs from splitting the file", i+1)
+		}
+	}
+}
+
+func TestSplitReader_MaxLengthTooSmall(t *testing.T) {
+	content, err := os.ReadFile("../../testing/too-long-diff.md")
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+
+	for _, maxLength := range []int{0, -1} {
+		_, err := SplitReader(strings.NewReader(string(content)), Options{MaxLength: maxLength, Unit: SizeRunes})
+		if err == nil || !strings.Contains(err.Error(), "max length too small") {
+			t.Errorf("MaxLength %d: expected a max length too small error, got %v", maxLength, err)
+		}
+	}
+}
+
+func TestSplit_CompressedFile(t *testing.T) {
+	content, err := os.ReadFile("../../testing/2-app-diff.md")
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	writer.Write(content)
+	writer.Close()
+
+	testFile := filepath.Join(t.TempDir(), "diff.md.gz")
+	if err := os.WriteFile(testFile, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	results, err := Split(testFile, Options{MaxLength: 100000, Unit: SizeRunes})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Content != withMarker(string(content), 1) {
+		t.Error("Expected the decompressed diff in a single part")
+	}
+}
+
+func TestParseSizeUnit(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    SizeUnit
+		wantErr bool
+	}{
+		{input: "bytes", want: SizeBytes},
+		{input: "runes", want: SizeRunes},
+		{input: "RUNES", want: SizeRunes},
+		{input: "chars", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSizeUnit(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSizeUnit(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSizeUnit(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplit_NeverExceedsMaxLength(t *testing.T) {
+	// Sizes around the point where the part count reaches two digits
+	for _, unit := range []SizeUnit{SizeBytes, SizeRunes} {
+		for maxLength := 2500; maxLength <= 8000; maxLength += 250 {
+			results, err := Split("../../testing/too-long-diff.md", Options{MaxLength: maxLength, Unit: unit})
+			if err != nil {
+				t.Fatalf("Split(%d %s) failed: %v", maxLength, unit, err)
+			}
+
+			for _, result := range results {
+				if result.Size > maxLength {
+					t.Errorf("Split(%d %s): part %d of %d is %d %s", maxLength, unit, result.PartNumber, result.TotalParts, result.Size, unit)
+				}
+				if result.Size != unit.Measure(result.Content) {
+					t.Errorf("Split(%d %s): part %d Size %d doesn't match its content", maxLength, unit, result.PartNumber, result.Size)
+				}
+			}
+		}
+	}
+}
+
+func TestSplit_LongLines(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	long := "+  data: " + strings.Repeat("x", 2000) + strings.Repeat("é", 1000)
+	content := "## Argo CD Diff Preview\n\n<details>\n<summary>app-name (path/to/app)</summary>\n<br>\n\n```diff\n" +
+		"+  short: value\n" + long + "\n+  other: value\n```\n\n</details>\n"
+
+	testFile := filepath.Join(tmpDir, "test-long.md")
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// split returns the diff lines of all parts
+	split := func(t *testing.T, mode LongLineMode) []string {
+		t.Helper()
+
+		results, err := Split(testFile, Options{MaxLength: 1000, LongLines: mode})
+		if err != nil {
+			t.Fatalf("Split failed: %v", err)
+		}
+
+		var lines []string
+		for i, result := range results {
+			if result.Size > 1000 {
+				t.Errorf("Result %d size %d exceeds max length 1000", i, result.Size)
+			}
+			if !utf8.ValidString(result.Content) {
+				t.Errorf("Result %d is not valid UTF-8", i)
+			}
+
+			report, err := diffparser.Parse(result.Content)
+			if err != nil {
+				t.Fatalf("Result %d does not parse: %v", i, err)
+			}
+			lines = append(lines, report.Apps[0].DiffLines()...)
+		}
+
+		if lines[0] != "+  short: value" || lines[len(lines)-1] != "+  other: value" {
+			t.Errorf("Expected the other lines to be kept, got %q and %q", lines[0], lines[len(lines)-1])
+		}
+		return lines[1 : len(lines)-1]
+	}
+
+	t.Run("wrap", func(t *testing.T) {
+		lines := split(t, LongLinesWrap)
+		if len(lines) < 2 {
+			t.Fatalf("Expected the long line to be wrapped, got %d line(s)", len(lines))
+		}
+
+		joined := lines[0]
+		for _, line := range lines[1:] {
+			if !strings.HasPrefix(line, "+↪ ") {
+				t.Fatalf("Continuation line is missing the wrap marker: %.20q", line)
+			}
+			joined += strings.TrimPrefix(line, "+↪ ")
+		}
+		if joined != long {
+			t.Error("Wrapped lines don't add up to the original line")
+		}
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		lines := split(t, LongLinesTruncate)
+		if len(lines) != 1 {
+			t.Fatalf("Expected a single truncated line, got %d", len(lines))
+		}
+
+		match := regexp.MustCompile(`^(.*) … (\d+) bytes elided$`).FindStringSubmatch(lines[0])
+		if match == nil {
+			t.Fatalf("Expected a note of the elided bytes: %.40q", lines[0])
+		}
+		if elided, _ := strconv.Atoi(match[2]); match[1]+long[len(match[1]):] != long || elided != len(long)-len(match[1]) {
+			t.Errorf("Truncated line doesn't match the original: kept %d bytes, elided %s", len(match[1]), match[2])
+		}
+	})
+
+	t.Run("truncate in runes", func(t *testing.T) {
+		line := "+" + strings.Repeat("é", 500)
+		lines, err := fitLine(line, 100, SizeRunes, LongLinesTruncate)
+		if err != nil {
+			t.Fatalf("fitLine failed: %v", err)
+		}
+
+		match := regexp.MustCompile(`^(.*) … (\d+) runes elided$`).FindStringSubmatch(lines[0])
+		if match == nil {
+			t.Fatalf("Expected a note of the elided runes: %.40q", lines[0])
+		}
+		kept := SizeRunes.Measure(match[1])
+		if elided, _ := strconv.Atoi(match[2]); kept+elided != SizeRunes.Measure(line) || SizeRunes.Measure(lines[0]) > 100 {
+			t.Errorf("Expected %d runes kept and elided in 100, got %d kept, %d elided in %d", SizeRunes.Measure(line), kept, elided, SizeRunes.Measure(lines[0]))
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := Split(testFile, Options{MaxLength: 1000, LongLines: LongLinesError})
+		if err == nil {
+			t.Fatal("Expected an error for a line longer than a part")
+		}
+		if !strings.Contains(err.Error(), "app-name") {
+			t.Errorf("Error should name the application: %v", err)
+		}
+	})
+}
+
+func TestParseLongLineMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    LongLineMode
+		wantErr bool
+	}{
+		{input: "wrap", want: LongLinesWrap},
+		{input: "truncate", want: LongLinesTruncate},
+		{input: "Error", want: LongLinesError},
+		{input: "skip", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseLongLineMode(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLongLineMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLongLineMode(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
 // Helper function to check if string contains substring
 func containsString(s, substr string) bool {
 	return len(s) >= len(substr) &&
@@ -194,6 +847,117 @@ func findInString(s, substr string) bool {
 	return false
 }
 
+func TestSplit_Navigation(t *testing.T) {
+	diffFile := writeAppsDiff(t, t.TempDir(), []int{30}, []int{30}, []int{30})
+
+	results, err := Split(diffFile, Options{MaxLength: 2000, Navigation: true})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(results) < 2 {
+		t.Fatalf("Expected at least 2 parts, got %d", len(results))
+	}
+
+	links := make([]string, len(results))
+	for i := range links {
+		links[i] = fmt.Sprintf("#comment-%d", i+1)
+	}
+	linked := Link(results, links)
+
+	for _, app := range []string{"app-1", "app-2", "app-3"} {
+		part := 0
+		for _, result := range linked {
+			if slices.Contains(result.Apps, app) {
+				part = result.PartNumber
+				break
+			}
+		}
+		entry := fmt.Sprintf("- [%s](#comment-%d) (part %d)", app, part, part)
+		if !strings.Contains(linked[0].Content, entry) {
+			t.Errorf("Expected table of contents entry %q, got:\n%s", entry, linked[0].Content)
+		}
+	}
+
+	if !strings.Contains(linked[0].Content, "**Part 1 of") || !strings.Contains(linked[0].Content, "[Next →](#comment-2)") {
+		t.Errorf("Expected first part to link to the next one, got:\n%s", linked[0].Content)
+	}
+	if !strings.Contains(linked[1].Content, "[← Previous](#comment-1)") {
+		t.Errorf("Expected second part to link to the previous one, got:\n%s", linked[1].Content)
+	}
+	if strings.Contains(linked[1].Content, "**Applications**") {
+		t.Error("Expected the table of contents only in the first part")
+	}
+
+	// Unlinked parts name the applications without links
+	if !strings.Contains(results[0].Content, "- app-1 (part 1)") {
+		t.Errorf("Expected unlinked table of contents, got:\n%s", results[0].Content)
+	}
+}
+
+func TestSplitResult_Body(t *testing.T) {
+	diffFile := writeAppsDiff(t, t.TempDir(), []int{30}, []int{30}, []int{30})
+
+	for _, maxLength := range []int{2000, 100000} {
+		results, err := Split(diffFile, Options{MaxLength: maxLength, Navigation: true})
+		if err != nil {
+			t.Fatalf("Split(%d) failed: %v", maxLength, err)
+		}
+
+		body := results[0].Body()
+		if _, ok := ParsePartMarker(body); ok {
+			t.Errorf("Split(%d): expected no part marker in the body:\n%s", maxLength, body)
+		}
+		if strings.Contains(body, "**Part 1 of") || strings.Contains(body, "**Applications**") {
+			t.Errorf("Split(%d): expected neither part indicator nor table of contents in the body:\n%s", maxLength, body)
+		}
+		if !strings.HasPrefix(body, "## Argo CD Diff Preview") || !strings.Contains(body, "app-1 (apps/app-1.yaml)") {
+			t.Errorf("Split(%d): expected the header and first application in the body:\n%s", maxLength, body)
+		}
+	}
+}
+
+func TestLink_NeverExceedsMaxLength(t *testing.T) {
+	for _, maxLength := range []int{3000, 5000, 8000} {
+		results, err := Split("../../testing/too-long-diff.md", Options{MaxLength: maxLength, Unit: SizeRunes, Navigation: true})
+		if err != nil {
+			t.Fatalf("Split(%d) failed: %v", maxLength, err)
+		}
+
+		longest := make([]string, len(results))
+		tooLong := make([]string, len(results))
+		for i := range results {
+			longest[i] = strings.Repeat("é", MaxLinkLength/2)
+			tooLong[i] = strings.Repeat("x", MaxLinkLength+1)
+		}
+
+		for _, result := range Link(results, longest) {
+			if result.Size > maxLength || result.Size != SizeRunes.Measure(result.Content) || result.Unit != SizeRunes {
+				t.Errorf("Split(%d): linked part %d is %d %s", maxLength, result.PartNumber, result.Size, result.Unit)
+			}
+		}
+
+		for _, result := range Link(results, tooLong) {
+			if strings.Contains(result.Content, tooLong[0]) {
+				t.Errorf("Split(%d): expected links over %d bytes to be left out", maxLength, MaxLinkLength)
+			}
+		}
+	}
+}
+
+func TestLink_WithoutNavigation(t *testing.T) {
+	results, err := Split("../../testing/too-long-diff.md", Options{MaxLength: 5000})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	linked := Link(results, make([]string, len(results)))
+	for i := range results {
+		if linked[i].Content != results[i].Content {
+			t.Errorf("Expected part %d to be unchanged", results[i].PartNumber)
+		}
+	}
+}
+
 func TestCountFileSize(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -222,39 +986,51 @@ func TestCountFileSize_NonExistent(t *testing.T) {
 	}
 }
 
-func TestExtractAppName(t *testing.T) {
+func TestParsePartMarker(t *testing.T) {
 	tests := []struct {
-		name     string
-		input    string
-		expected string
+		name         string
+		input        string
+		expectedPart int
+		expectedOK   bool
 	}{
 		{
-			name:     "Standard format",
-			input:    "<summary>app-name (path/to/app)</summary>",
-			expected: "app-name (path/to/app)",
+			name:         "Marker generated by PartMarker",
+			input:        "Some diff\n" + PartMarker(3),
+			expectedPart: 3,
+			expectedOK:   true,
+		},
+		{
+			name:         "Marker in the middle of the body",
+			input:        "before\n<!-- argocd-diff-preview-pr-comment:part=12 -->\nafter",
+			expectedPart: 12,
+			expectedOK:   true,
 		},
 		{
-			name:     "With continuation",
-			input:    "<summary>app-name (path/to/app) (continuation...)</summary>",
-			expected: "app-name (path/to/app) (continuation...)",
+			name:         "Markdown marker",
+			input:        "Some diff\n\n" + MarkdownPartMarker(2),
+			expectedPart: 2,
+			expectedOK:   true,
 		},
 		{
-			name:     "Missing closing tag",
-			input:    "<summary>app-name",
-			expected: "",
+			name:       "No marker",
+			input:      "LGTM!",
+			expectedOK: false,
 		},
 		{
-			name:     "Missing opening tag",
-			input:    "app-name</summary>",
-			expected: "",
+			name:       "Marker from another tool",
+			input:      "<!-- some-other-tool:part=1 -->",
+			expectedOK: false,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := extractAppName(tt.input)
-			if result != tt.expected {
-				t.Errorf("Expected %q, got %q", tt.expected, result)
+			partNumber, ok := ParsePartMarker(tt.input)
+			if ok != tt.expectedOK {
+				t.Errorf("Expected ok=%v, got %v", tt.expectedOK, ok)
+			}
+			if partNumber != tt.expectedPart {
+				t.Errorf("Expected part %d, got %d", tt.expectedPart, partNumber)
 			}
 		})
 	}
@@ -284,3 +1060,19 @@ func TestReadFileLines(t *testing.T) {
 		t.Errorf("Lines content mismatch: got %v", lines)
 	}
 }
+
+func BenchmarkSplit_LargeApplication(b *testing.B) {
+	// A single application of 300k diff lines in hunks of 50, about 6 MB
+	hunks := make([]int, 6000)
+	for i := range hunks {
+		hunks[i] = 50
+	}
+	diffFile := writeAppsDiff(b, b.TempDir(), hunks)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Split(diffFile, Options{MaxLength: 65536, Unit: SizeRunes, Navigation: true}); err != nil {
+			b.Fatalf("Split failed: %v", err)
+		}
+	}
+}
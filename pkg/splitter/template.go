@@ -0,0 +1,292 @@
+package splitter
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/diffparser"
+)
+
+// Names of the templates that can be defined in a template file
+const (
+	// TemplateHeader is rendered at the top of the first part
+	TemplateHeader = "header"
+	// TemplateApp wraps the diff of an application, or of a piece of it
+	TemplateApp = "app"
+	// TemplateContinuation renders the title of the pieces of an application
+	// continued from a previous part
+	TemplateContinuation = "continuation"
+	// TemplatePart is the part indicator at the end of every part of a split diff
+	TemplatePart = "part"
+	// TemplateFooter is rendered at the end of the first part's diff
+	TemplateFooter = "footer"
+)
+
+// diffPlaceholder stands in for the diff lines when the app template is
+// executed, so sections can be measured and cut around them
+const diffPlaceholder = "\x00diff\x00"
+
+// ValidTemplateNames returns the names of all templates
+func ValidTemplateNames() []string {
+	return []string{TemplateHeader, TemplateApp, TemplateContinuation, TemplatePart, TemplateFooter}
+}
+
+// Templates customise the layout of the parts. Templates that are not
+// defined keep the default layout
+type Templates struct {
+	byName map[string]*template.Template
+}
+
+// TemplateData is what templates are executed with. Fields that don't apply
+// to a template are left empty
+type TemplateData struct {
+	// PRNumber and CommitSHA identify what the diff is for, when known
+	PRNumber  int
+	CommitSHA string
+
+	// Title is the report title and Summary and Stats the raw lines of the
+	// summary block and stats footer. Intro is the text between the title and
+	// the summary, e.g. the environment table of a combined report
+	Title    string
+	Intro    string
+	Summary  string
+	Stats    string
+	AppCount int
+	// Added and Removed are the line counts of the summary block
+	Added   int
+	Removed int
+
+	// AppName, AppPath, AppTitle and Change describe the application of the
+	// app and continuation templates. In the app template, AppTitle is the
+	// continuation title for continued pieces. Environment is the label of
+	// the application's diff file in a combined report
+	AppName     string
+	AppPath     string
+	AppTitle    string
+	Change      string
+	Environment string
+	Continued   bool
+	// Diff is the diff lines of the section, each ending in a newline. The
+	// app template must output it exactly once
+	Diff string
+
+	// PartNumber and TotalParts are set for the part template, with links to
+	// the previous and next parts once they are known
+	PartNumber int
+	TotalParts int
+	Previous   string
+	Next       string
+}
+
+// ParseTemplates parses templates defined with {{define "name"}} blocks,
+// named after ValidTemplateNames
+func ParseTemplates(text string) (*Templates, error) {
+	root, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+
+	templates := &Templates{byName: make(map[string]*template.Template)}
+	for _, t := range root.Templates() {
+		name := t.Name()
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(ValidTemplateNames(), name) {
+			return nil, fmt.Errorf("unknown template %q (valid templates: %s)", name, strings.Join(ValidTemplateNames(), ", "))
+		}
+		templates.byName[name] = t
+	}
+
+	if len(templates.byName) == 0 {
+		return nil, fmt.Errorf("no templates defined, expected {{define \"name\"}} blocks for: %s", strings.Join(ValidTemplateNames(), ", "))
+	}
+
+	return templates, nil
+}
+
+// LoadTemplates reads and parses a template file
+func LoadTemplates(path string) (*Templates, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+	return ParseTemplates(string(text))
+}
+
+// execute runs the named template, returning false if it isn't defined
+func (t *Templates) execute(name string, data TemplateData) (string, bool, error) {
+	if t == nil || t.byName[name] == nil {
+		return "", false, nil
+	}
+
+	var b strings.Builder
+	if err := t.byName[name].Execute(&b, data); err != nil {
+		return "", true, fmt.Errorf("failed to execute %s template: %w", name, err)
+	}
+	return b.String(), true, nil
+}
+
+// frame is the text around the diff lines of a section
+type frame struct {
+	opening string
+	closing string
+}
+
+// render wraps diff lines in the frame
+func (f frame) render(lines []string) string {
+	var b strings.Builder
+	b.WriteString(f.opening)
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString(f.closing)
+	return b.String()
+}
+
+// overhead returns the size of the frame
+func (f frame) overhead(unit SizeUnit) int {
+	return unit.Measure(f.opening) + unit.Measure(f.closing)
+}
+
+// renderer renders the pieces parts are assembled from, with the templates
+// or the default layout
+type renderer struct {
+	templates *Templates
+	data      TemplateData
+	unit      SizeUnit
+}
+
+// newRenderer returns a renderer for the report
+func newRenderer(report *diffparser.Report, options Options) *renderer {
+	data := TemplateData{
+		PRNumber:  options.PRNumber,
+		CommitSHA: options.CommitSHA,
+		Title:     report.Title,
+		Intro:     strings.Join(report.Intro, "\n"),
+		AppCount:  len(report.Apps),
+	}
+	if report.Summary != nil {
+		data.Summary = strings.Join(report.Summary.Lines, "\n")
+		for _, entry := range report.Summary.Entries {
+			data.Added += entry.Added
+			data.Removed += entry.Removed
+		}
+	}
+	if report.Stats != nil {
+		data.Stats = strings.Join(report.Stats.Lines, "\n")
+	}
+
+	return &renderer{templates: options.Templates, data: data, unit: options.Unit}
+}
+
+// header renders the top of the first part
+func (r *renderer) header(report *diffparser.Report) (string, error) {
+	if header, ok, err := r.templates.execute(TemplateHeader, r.data); ok {
+		return header, err
+	}
+	return report.RenderHeader(), nil
+}
+
+// footer renders the end of the first part's diff
+func (r *renderer) footer(report *diffparser.Report) (string, error) {
+	if footer, ok, err := r.templates.execute(TemplateFooter, r.data); ok {
+		return footer, err
+	}
+	if report.Stats == nil {
+		return "", nil
+	}
+	return "\n" + report.RenderFooter(), nil
+}
+
+// frames returns the frames of the application's first and continued pieces
+func (r *renderer) frames(app *diffparser.Application) (first, continued frame, err error) {
+	data := r.data
+	data.AppName = app.Name
+	data.AppPath = app.Path
+	data.AppTitle = app.Title()
+	data.Change = string(app.Change)
+	data.Environment = app.Environment
+
+	continuation, ok, err := r.templates.execute(TemplateContinuation, data)
+	if err != nil {
+		return frame{}, frame{}, err
+	}
+	if !ok {
+		continuation = fmt.Sprintf("%s (continuation...)", app.Title())
+	}
+
+	first, err = r.frame(data)
+	if err != nil {
+		return frame{}, frame{}, err
+	}
+
+	data.AppTitle = continuation
+	data.Continued = true
+	continued, err = r.frame(data)
+	return first, continued, err
+}
+
+// frame renders the app template around a placeholder and cuts it there
+func (r *renderer) frame(data TemplateData) (frame, error) {
+	data.Diff = diffPlaceholder
+	section, ok, err := r.templates.execute(TemplateApp, data)
+	if err != nil {
+		return frame{}, err
+	}
+	if !ok {
+		opening := strings.Join(diffparser.SectionOpening(data.AppTitle), "\n") + "\n"
+		closing := strings.Join(diffparser.SectionClosing(), "\n") + "\n"
+		return frame{opening: opening, closing: closing}, nil
+	}
+
+	if strings.Count(section, diffPlaceholder) != 1 {
+		return frame{}, fmt.Errorf("the %s template must output {{.Diff}} exactly once", TemplateApp)
+	}
+	opening, closing, _ := strings.Cut(section, diffPlaceholder)
+	return frame{opening: opening, closing: closing}, nil
+}
+
+// indicator renders the part indicator. Links are left out when empty
+func (r *renderer) indicator(partNumber, totalParts int, navigation bool, previous, next string) (string, error) {
+	data := r.data
+	data.PartNumber = partNumber
+	data.TotalParts = totalParts
+	if partNumber > 1 && len(previous) <= MaxLinkLength {
+		data.Previous = previous
+	}
+	if partNumber < totalParts && len(next) <= MaxLinkLength {
+		data.Next = next
+	}
+
+	if indicator, ok, err := r.templates.execute(TemplatePart, data); ok {
+		return indicator, err
+	}
+	if navigation {
+		return navIndicator(partNumber, totalParts, previous, next), nil
+	}
+	return partIndicator(partNumber, totalParts), nil
+}
+
+// indicatorReserve returns the room to keep free in every part for the part
+// indicator of up to largest parts, with links as long as allowed when
+// navigating
+func (r *renderer) indicatorReserve(largest int, navigation bool) (int, error) {
+	link := ""
+	if navigation {
+		link = strings.Repeat("x", MaxLinkLength)
+	}
+
+	reserve := 0
+	for partNumber := 1; partNumber <= largest; partNumber++ {
+		indicator, err := r.indicator(partNumber, largest, navigation, link, link)
+		if err != nil {
+			return 0, err
+		}
+		reserve = max(reserve, r.unit.Measure(indicator))
+	}
+	return reserve, nil
+}
@@ -0,0 +1,282 @@
+package splitter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fenced replaces ”' with a markdown code fence, which can't appear in a
+// raw string literal
+func fenced(text string) string {
+	return strings.ReplaceAll(text, "'''", "```")
+}
+
+// defaultTemplates reproduce the default layout
+var defaultTemplates = fenced(`{{define "header"}}{{if .Title}}## {{.Title}}
+
+{{end}}{{if .Summary}}Summary:
+'''yaml
+{{.Summary}}
+'''
+
+{{end}}{{end}}
+{{define "app"}}<details>
+<summary>{{.AppTitle}}</summary>
+<br>
+
+'''diff
+{{.Diff}}'''
+
+</details>
+{{end}}
+{{define "continuation"}}{{.AppTitle}} (continuation...){{end}}
+{{define "part"}}
+
+---
+**Part {{.PartNumber}} of {{.TotalParts}}**
+{{end}}
+{{define "footer"}}{{if .Stats}}
+_Stats_:
+{{.Stats}}
+{{end}}{{end}}`)
+
+func TestParseTemplates(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		expectErr string
+	}{
+		{
+			name: "all templates",
+			text: defaultTemplates,
+		},
+		{
+			name: "single template",
+			text: `{{define "part"}}Part {{.PartNumber}}{{end}}`,
+		},
+		{
+			name:      "unknown template",
+			text:      `{{define "heading"}}{{.Title}}{{end}}`,
+			expectErr: `unknown template "heading"`,
+		},
+		{
+			name:      "syntax error",
+			text:      `{{define "header"}}{{.Title}{{end}}`,
+			expectErr: "failed to parse templates",
+		},
+		{
+			name:      "no templates",
+			text:      "just text",
+			expectErr: "no templates defined",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTemplates(tt.text)
+			if tt.expectErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestSplit_DefaultTemplates(t *testing.T) {
+	templates, err := ParseTemplates(defaultTemplates)
+	if err != nil {
+		t.Fatalf("ParseTemplates failed: %v", err)
+	}
+
+	for _, options := range []Options{
+		{MaxLength: 5000},
+		{MaxLength: 5000, Unit: SizeRunes},
+	} {
+		expected, err := Split("../../testing/too-long-diff.md", options)
+		if err != nil {
+			t.Fatalf("Split failed: %v", err)
+		}
+
+		options.Templates = templates
+		results, err := Split("../../testing/too-long-diff.md", options)
+		if err != nil {
+			t.Fatalf("Split with templates failed: %v", err)
+		}
+
+		if len(results) != len(expected) {
+			t.Fatalf("Expected %d parts, got %d", len(expected), len(results))
+		}
+		for i := range results {
+			if results[i].Content != expected[i].Content {
+				t.Errorf("Part %d differs from the default layout:\n%s\n---\n%s", i+1, results[i].Content, expected[i].Content)
+			}
+		}
+	}
+}
+
+func TestSplit_CustomTemplates(t *testing.T) {
+	templates, err := ParseTemplates(fenced(`
+{{define "header"}}### Diff for #{{.PRNumber}} at {{.CommitSHA}}: {{.AppCount}} app(s), +{{.Added}} -{{.Removed}}
+
+{{end}}
+{{define "app"}}#### {{.AppTitle}}{{if .Continued}} (cont.){{end}} [{{.Change}}]
+'''diff
+{{.Diff}}'''
+{{end}}
+{{define "continuation"}}{{.AppName}}, continued{{end}}
+{{define "part"}}
+_{{.PartNumber}}/{{.TotalParts}}_
+{{end}}
+{{define "footer"}}
+Generated for {{.CommitSHA}}
+{{end}}`))
+	if err != nil {
+		t.Fatalf("ParseTemplates failed: %v", err)
+	}
+
+	options := Options{MaxLength: 4000, Templates: templates, PRNumber: 42, CommitSHA: "abc123"}
+	results, err := Split("../../testing/too-long-diff.md", options)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(results) < 2 {
+		t.Fatalf("Expected the diff to be split, got %d part(s)", len(results))
+	}
+
+	first := results[0].Content
+	for _, expected := range []string{
+		"### Diff for #42 at abc123: 1 app(s), +2436 -64",
+		"#### argocd-helm-chart (examples/with-crds/applicaiton.yaml) [modified]",
+		"Generated for abc123",
+		fmt.Sprintf("_1/%d_", len(results)),
+	} {
+		if !strings.Contains(first, expected) {
+			t.Errorf("Expected first part to contain %q, got:\n%s", expected, first)
+		}
+	}
+
+	if !strings.Contains(results[1].Content, "#### argocd-helm-chart, continued (cont.) [modified]") {
+		t.Errorf("Expected continuation title in the second part, got:\n%s", results[1].Content)
+	}
+	if strings.Contains(results[1].Content, "Diff for #42") {
+		t.Error("Expected the header only in the first part")
+	}
+
+	for _, result := range results {
+		if result.Size > options.MaxLength {
+			t.Errorf("Part %d is %d bytes, over %d", result.PartNumber, result.Size, options.MaxLength)
+		}
+	}
+}
+
+func TestLink_PartTemplate(t *testing.T) {
+	templates, err := ParseTemplates(`{{define "part"}}
+{{.PartNumber}}/{{.TotalParts}} previous={{.Previous}} next={{.Next}}
+{{end}}`)
+	if err != nil {
+		t.Fatalf("ParseTemplates failed: %v", err)
+	}
+
+	results, err := Split("../../testing/too-long-diff.md", Options{MaxLength: 5000, Templates: templates, Navigation: true})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	links := make([]string, len(results))
+	for i := range links {
+		links[i] = fmt.Sprintf("#comment-%d", i+1)
+	}
+	linked := Link(results, links)
+
+	expected := fmt.Sprintf("2/%d previous=#comment-1 next=#comment-3", len(results))
+	if !strings.Contains(linked[1].Content, expected) {
+		t.Errorf("Expected second part to contain %q, got:\n%s", expected, linked[1].Content)
+	}
+
+	if !strings.Contains(results[1].Content, fmt.Sprintf("2/%d previous= next=\n", len(results))) {
+		t.Errorf("Expected unlinked parts to have empty links, got:\n%s", results[1].Content)
+	}
+}
+
+func TestSplit_TemplatesRenderSmallDiffs(t *testing.T) {
+	templates, err := ParseTemplates(`{{define "header"}}Preview for {{.CommitSHA}}
+{{end}}`)
+	if err != nil {
+		t.Fatalf("ParseTemplates failed: %v", err)
+	}
+
+	results, err := Split("../../testing/2-app-diff.md", Options{MaxLength: 65536, Templates: templates, CommitSHA: "abc123"})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	if len(results) != 1 || !strings.HasPrefix(results[0].Content, "Preview for abc123\n<details>") {
+		t.Errorf("Expected a single part starting with the header template, got %d part(s)", len(results))
+	}
+}
+
+func TestSplit_TemplateErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		expectErr string
+	}{
+		{
+			name:      "app template without diff",
+			text:      `{{define "app"}}{{.AppTitle}}{{end}}`,
+			expectErr: "must output {{.Diff}} exactly once",
+		},
+		{
+			name:      "app template with diff twice",
+			text:      `{{define "app"}}{{.Diff}}{{.Diff}}{{end}}`,
+			expectErr: "must output {{.Diff}} exactly once",
+		},
+		{
+			name:      "unknown field",
+			text:      `{{define "part"}}{{.Page}}{{end}}`,
+			expectErr: "failed to execute part template",
+		},
+		{
+			name:      "header too large",
+			text:      `{{define "header"}}{{range 5000}}x{{end}}{{end}}`,
+			expectErr: "max length too small",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templates, err := ParseTemplates(tt.text)
+			if err != nil {
+				t.Fatalf("ParseTemplates failed: %v", err)
+			}
+
+			_, err = Split("../../testing/too-long-diff.md", Options{MaxLength: 5000, Templates: templates})
+			if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "comment.tmpl")
+	if err := os.WriteFile(path, []byte(defaultTemplates), 0644); err != nil {
+		t.Fatalf("Failed to create template file: %v", err)
+	}
+
+	if _, err := LoadTemplates(path); err != nil {
+		t.Errorf("LoadTemplates failed: %v", err)
+	}
+
+	if _, err := LoadTemplates(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Error("Expected error for a missing template file but got none")
+	}
+}
@@ -0,0 +1,76 @@
+package summary
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/splitter"
+)
+
+// MaxSize is GitHub's limit for the job summary written by a single step
+const MaxSize = 1024 * 1024
+
+// truncatedNotice is appended when the diff doesn't fit in the summary
+const truncatedNotice = "\n\n> [!NOTE]\n> The diff is too large for the job summary: %d more part(s) were omitted.\n"
+
+// noticeMaxSize is the space reserved for the truncation notice
+var noticeMaxSize = len(fmt.Sprintf(truncatedNotice, 9999))
+
+// Write appends the diff file to the step summary file at path. The step's
+// summary is kept within MaxSize, counting anything previous commands in the
+// same step already wrote: when the diff doesn't fit, only its first part is
+// written, followed by a notice. In dry-run mode nothing is written
+func Write(path, diffFile string, dryRun bool) error {
+	return write(path, diffFile, MaxSize, dryRun)
+}
+
+// write implements Write with a configurable size limit
+func write(path, diffFile string, maxSize int, dryRun bool) error {
+	log := logger.GetLogger()
+
+	existing := 0
+	if info, err := os.Stat(path); err == nil {
+		existing = int(info.Size())
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read step summary file: %w", err)
+	}
+
+	// Reserve room for the notice and the trailing newline
+	available := maxSize - existing - noticeMaxSize - 1
+	if available <= 0 {
+		return fmt.Errorf("step summary is full: %d of %d bytes already used", existing, maxSize)
+	}
+
+	results, err := splitter.SplitDiffFile(diffFile, available)
+	if err != nil {
+		return fmt.Errorf("failed to split diff file for the step summary: %w", err)
+	}
+
+	// Nothing updates the summary in place, so it needs no part marker
+	content := strings.TrimSuffix(results[0].Body(), "\n")
+	if omitted := results[0].TotalParts - 1; omitted > 0 {
+		log.Warnf("Diff exceeds the step summary limit, writing part 1 of %d", results[0].TotalParts)
+		content += fmt.Sprintf(truncatedNotice, omitted)
+	}
+
+	if dryRun {
+		log.Infof("[DRY RUN] Would write %d bytes to the step summary %s", len(content), path)
+		log.Debugf("[DRY RUN] Step summary content:\n%s", content)
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open step summary file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(content + "\n"); err != nil {
+		return fmt.Errorf("failed to write step summary: %w", err)
+	}
+
+	log.Infof("Successfully wrote %d bytes to the step summary", len(content)+1)
+	return nil
+}
@@ -0,0 +1,131 @@
+package summary
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/logger"
+	"github.com/belitre/argocd-diff-preview-pr-comment/pkg/splitter"
+)
+
+func init() {
+	// Initialize logger for tests
+	logger.Initialize(logger.ErrorLevel)
+}
+
+// writeDiffFile creates a diff file with the given number of applications
+func writeDiffFile(t *testing.T, dir string, apps int) string {
+	t.Helper()
+
+	var b strings.Builder
+	b.WriteString("## Argo CD Diff Preview\n\nSummary:\n```yaml\nTotal: changes\n```\n\n")
+	for i := 1; i <= apps; i++ {
+		fmt.Fprintf(&b, "<details>\n<summary>app-%d (apps/app-%d.yaml)</summary>\n<br>\n\n```diff\n", i, i)
+		for j := 0; j < 50; j++ {
+			fmt.Fprintf(&b, "+  key-%d: value for application %d\n", j, i)
+		}
+		b.WriteString("```\n\n</details>\n\n")
+	}
+	b.WriteString("_Stats_:\n[Applications: many]\n")
+
+	path := filepath.Join(dir, "diff.md")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatalf("Failed to create diff file: %v", err)
+	}
+	return path
+}
+
+func TestWrite_FitsInSummary(t *testing.T) {
+	tmpDir := t.TempDir()
+	diffFile := writeDiffFile(t, tmpDir, 2)
+	summaryFile := filepath.Join(tmpDir, "summary.md")
+
+	if err := os.WriteFile(summaryFile, []byte("# Previous step output\n"), 0644); err != nil {
+		t.Fatalf("Failed to create summary file: %v", err)
+	}
+
+	if err := Write(summaryFile, diffFile, false); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(summaryFile)
+	if !strings.HasPrefix(string(content), "# Previous step output\n") {
+		t.Error("Existing summary content should be kept")
+	}
+
+	if !strings.Contains(string(content), "app-2 (apps/app-2.yaml)") {
+		t.Error("Summary should contain the whole diff")
+	}
+
+	if strings.Contains(string(content), "omitted") {
+		t.Error("Summary should not be truncated")
+	}
+
+	if _, ok := splitter.ParsePartMarker(string(content)); ok {
+		t.Error("Summary should not contain a part marker")
+	}
+}
+
+func TestWrite_Truncated(t *testing.T) {
+	tmpDir := t.TempDir()
+	diffFile := writeDiffFile(t, tmpDir, 20)
+	summaryFile := filepath.Join(tmpDir, "summary.md")
+
+	existing := strings.Repeat("x", 1000) + "\n"
+	if err := os.WriteFile(summaryFile, []byte(existing), 0644); err != nil {
+		t.Fatalf("Failed to create summary file: %v", err)
+	}
+
+	maxSize := 10000
+	if err := write(summaryFile, diffFile, maxSize, false); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(summaryFile)
+	if len(content) > maxSize {
+		t.Errorf("Summary is %d bytes, exceeding the %d byte limit", len(content), maxSize)
+	}
+
+	if !strings.Contains(string(content), "more part(s) were omitted") {
+		t.Error("Truncated summary should end with a notice")
+	}
+
+	if !strings.Contains(string(content), "app-1 (apps/app-1.yaml)") {
+		t.Error("Summary should start with the first applications")
+	}
+
+	if _, ok := splitter.ParsePartMarker(string(content)); ok || strings.Contains(string(content), "**Part 1 of") {
+		t.Errorf("Summary should contain neither a part marker nor a part indicator:\n%s", content)
+	}
+}
+
+func TestWrite_SummaryFull(t *testing.T) {
+	tmpDir := t.TempDir()
+	diffFile := writeDiffFile(t, tmpDir, 1)
+	summaryFile := filepath.Join(tmpDir, "summary.md")
+
+	if err := os.WriteFile(summaryFile, []byte(strings.Repeat("x", 1000)), 0644); err != nil {
+		t.Fatalf("Failed to create summary file: %v", err)
+	}
+
+	if err := write(summaryFile, diffFile, 1000, false); err == nil {
+		t.Error("Expected error for a full summary but got none")
+	}
+}
+
+func TestWrite_DryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	diffFile := writeDiffFile(t, tmpDir, 1)
+	summaryFile := filepath.Join(tmpDir, "summary.md")
+
+	if err := Write(summaryFile, diffFile, true); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if _, err := os.Stat(summaryFile); !os.IsNotExist(err) {
+		t.Error("Dry run should not create the summary file")
+	}
+}